@@ -3,18 +3,28 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/tonkeeper/opentonapi/pkg/addressbook"
+	"github.com/tonkeeper/opentonapi/pkg/admin"
 	"github.com/tonkeeper/opentonapi/pkg/api"
 	"github.com/tonkeeper/opentonapi/pkg/app"
+	"github.com/tonkeeper/opentonapi/pkg/bath"
 	"github.com/tonkeeper/opentonapi/pkg/blockchain"
 	"github.com/tonkeeper/opentonapi/pkg/blockchain/indexer"
 	"github.com/tonkeeper/opentonapi/pkg/config"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
 	"github.com/tonkeeper/opentonapi/pkg/litestorage"
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sources"
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sse"
+	"github.com/tonkeeper/opentonapi/pkg/pusher/websocket"
 	"github.com/tonkeeper/opentonapi/pkg/pyth"
 	"github.com/tonkeeper/opentonapi/pkg/spam"
+	"github.com/tonkeeper/opentonapi/pkg/webhooks"
 	"github.com/tonkeeper/tongo"
 	ton "github.com/tonkeeper/tongo/config"
 	"github.com/tonkeeper/tongo/liteapi"
@@ -26,9 +36,12 @@ func main() {
 	cfg := config.Load()
 	log := app.Logger(cfg.App.LogLevel)
 
-	storageBlockCh := make(chan indexer.IDandBlock)
+	broadcaster := indexer.NewBroadcaster(log)
+	storageBlocks, err := broadcaster.Subscribe("litestorage", 100, indexer.OverflowBlock)
+	if err != nil {
+		log.Fatal("failed to subscribe to indexer", zap.Error(err))
+	}
 
-	var err error
 	var client *liteapi.Client
 	if len(cfg.App.LiteServers) == 0 {
 		log.Warn("USING PUBLIC CONFIG for NewLiteStorage! BE CAREFUL!")
@@ -56,7 +69,23 @@ func main() {
 		archiveLiteServers = opt.LiteServers
 	}
 
+	store, err := kv.Open(cfg.App.StorageBackend, cfg.App.StoragePath)
+	if err != nil {
+		log.Fatal("failed to open kv store", zap.Error(err))
+	}
+
+	var trackingRules []litestorage.TrackingRule
+	for _, r := range cfg.App.TrackingRules {
+		rule, err := litestorage.ParseTrackingRule(r)
+		if err != nil {
+			log.Fatal("failed to parse tracking rule", zap.String("rule", r), zap.Error(err))
+		}
+		trackingRules = append(trackingRules, rule)
+	}
+
 	pythFeeds := pyth.GetUpdatedWithFallback(context.Background(), log)
+	sentMessagesCh := make(chan blockchain.ExtInMsgCopy, 1000)
+	sentMessageStatuses := make(chan core.SentMessage, 100)
 	storage, err := litestorage.NewLiteStorage(
 		log,
 		client,
@@ -64,8 +93,20 @@ func main() {
 			tongo.MustParseBlockID("(0,8000000000000000,72945279)"),
 		}),
 		litestorage.WithPreloadAccounts(cfg.App.Accounts),
-		litestorage.WithBlockChannel(storageBlockCh),
+		litestorage.WithTrackingRules(trackingRules),
+		litestorage.WithTraceExtractor(bath.FindJettonOperations),
+		litestorage.WithTraceExtractor(bath.FindAuctionBids),
+		litestorage.WithTraceExtractor(bath.FindInvoicePayments),
+		litestorage.WithTraceExtractor(bath.FindSubscriptionEvents),
+		litestorage.WithTraceExtractor(bath.FindNftTransfers),
+		litestorage.WithReducedBlocksRetention(cfg.App.ReducedBlocksRetention),
+		litestorage.WithEmulatedTracesMaxSize(cfg.App.EmulatedTracesMaxSize),
+		litestorage.WithPersistentEmulatedTraces(cfg.App.EmulatedTracesPersistent),
+		litestorage.WithBlockChannel(storageBlocks.C()),
 		litestorage.WithPythPriceFeeds(pythFeeds),
+		litestorage.WithKVStore(store),
+		litestorage.WithSentMessages(sentMessagesCh),
+		litestorage.WithSentMessageStatusReceiver(sentMessageStatuses),
 	)
 	book := addressbook.NewAddressBook(log, config.AddressPath, config.JettonPath, config.CollectionPath, storage)
 	// The executor is used to resolve DNS records.
@@ -74,7 +115,13 @@ func main() {
 	if err != nil {
 		log.Fatal("storage init", zap.Error(err))
 	}
-	msgSender, err := blockchain.NewMsgSender(log, cfg.App.LiteServers, map[string]chan<- blockchain.ExtInMsgCopy{})
+	mempoolCh := make(chan blockchain.ExtInMsgCopy, 100)
+	emulationCh := make(chan blockchain.ExtInMsgCopy, 100)
+	msgSender, err := blockchain.NewMsgSender(log, cfg.App.LiteServers, map[string]chan<- blockchain.ExtInMsgCopy{
+		"pusher":    mempoolCh,
+		"emulation": emulationCh,
+		"tracker":   sentMessagesCh,
+	})
 	if err != nil {
 		log.Fatal("failed to create msg sender", zap.Error(err))
 	}
@@ -88,16 +135,55 @@ func main() {
 		api.WithTonConnectSecret(cfg.TonConnect.Secret),
 		api.WithArchiveLiteServers(archiveLiteServers),
 		api.WithPublicAPIURL(cfg.PublicAPIURL),
+		api.WithEmulatedMessagesReceiver(mempoolCh),
 	)
 	if err != nil {
 		log.Fatal("failed to create api handler", zap.Error(err))
 	}
-	idx := indexer.New(log, client)
-	go idx.Run(context.TODO(), []chan indexer.IDandBlock{
-		storageBlockCh,
-	})
+	storage.SetAccountEventBuilder(h.AccountEvent)
+	webhookDispatcher, err := webhooks.New(log, store, storage,
+		webhooks.WithAccountEventBuilder(h.AccountEvent),
+		webhooks.WithMaxAttempts(cfg.App.WebhookMaxAttempts),
+	)
+	if err != nil {
+		log.Fatal("failed to create webhook dispatcher", zap.Error(err))
+	}
+	storage.SetTraceListener(webhookDispatcher.OnTrace)
+	go webhookDispatcher.Run(context.TODO())
+	go h.RunEmulation(context.TODO(), emulationCh)
+	// streaming isn't critical, so it shouldn't slow down the indexer.
+	pusherBlocks, err := broadcaster.Subscribe("pusher", 100, indexer.OverflowDropOldest)
+	if err != nil {
+		log.Fatal("failed to subscribe to indexer", zap.Error(err))
+	}
+	blockchainSource := sources.NewBlockchainSource(log,
+		sources.WithAccountEventBuilder(h.AccountEvent),
+		sources.WithTraceHistory(storage),
+	)
+	// traces are assembled once by the storage and shared with the pusher.
+	storage.SetChainTraceListener(blockchainSource.OnTrace)
+	go blockchainSource.Run(context.TODO(), pusherBlocks)
+	mempool := sources.NewMemPool(log, sources.WithPreviewBuilder(h.MempoolAccountEvent))
+	go mempool.Run(context.TODO(), mempoolCh, sentMessageStatuses)
+	websocketHandler := websocket.NewHandler(log, blockchainSource, mempool,
+		websocket.WithMaxSubscriptions(cfg.App.WebsocketMaxSubscriptions),
+	)
+	sseHandler := sse.NewHandler(log, blockchainSource,
+		sse.WithHeartbeatInterval(cfg.App.SSEHeartbeatInterval),
+		sse.WithMaxAccounts(cfg.App.SSEMaxAccounts),
+		sse.WithMemPool(mempool),
+	)
+	// the indexer resumes from the last block processed by LiteStorage, it's the only consumer that can't lose blocks.
+	idx := indexer.New(log, client,
+		indexer.WithCursor(indexer.CursorFunc(storage.LastIndexedMasterchainSeqno)),
+		indexer.WithStartSeqno(cfg.App.IndexerStartSeqno),
+	)
+	go idx.Run(context.TODO(), broadcaster)
 
-	server, err := api.NewServer(log, h)
+	server, err := api.NewServer(log, h,
+		api.WithSSEHandler(sseHandler),
+		api.WithWebsocketHandler(websocketHandler),
+	)
 	if err != nil {
 		log.Fatal("failed to create api handler", zap.Error(err))
 	}
@@ -112,6 +198,18 @@ func main() {
 		}
 	}()
 
+	if cfg.App.AdminPort > 0 {
+		// admin endpoints change what the node tracks and where it sends webhooks, so they are never open.
+		if cfg.App.AdminToken == "" {
+			log.Fatal("ADMIN_TOKEN is required to enable admin endpoints")
+		}
+		adminServer := admin.NewServer(log, cfg.App.AdminToken)
+		adminServer.RegisterAccountTracker(storage)
+		adminServer.RegisterTrackingRules(storage)
+		adminServer.RegisterWebhooks(webhookDispatcher)
+		adminServer.Run(net.JoinHostPort(cfg.App.AdminHost, strconv.Itoa(cfg.App.AdminPort)))
+	}
+
 	log.Warn("start server", zap.Int("port", cfg.API.Port))
 	server.Run(fmt.Sprintf(":%d", cfg.API.Port), cfg.API.UnixSockets)
 	select {}
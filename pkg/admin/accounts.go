@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/litestorage"
+	internalErrors "github.com/tonkeeper/opentonapi/pkg/pusher/errors"
+)
+
+// accountTracker manages a list of accounts whose history is indexed locally.
+type accountTracker interface {
+	TrackedAccounts() []litestorage.TrackedAccount
+	TrackAccount(a tongo.AccountID) error
+	UntrackAccount(a tongo.AccountID) error
+}
+
+type trackedAccount struct {
+	Address   string `json:"address"`
+	Preloaded bool   `json:"preloaded"`
+}
+
+type trackedAccounts struct {
+	Accounts []trackedAccount `json:"accounts"`
+}
+
+// RegisterAccountTracker exposes endpoints to manage tracked accounts:
+//
+//	GET    /admin/accounts              lists tracked accounts
+//	POST   /admin/accounts/{account_id} starts tracking an account and loads its history in the background
+//	DELETE /admin/accounts/{account_id} stops tracking an account
+func (s *Server) RegisterAccountTracker(tracker accountTracker) {
+	s.Handle("GET /admin/accounts", func(w http.ResponseWriter, r *http.Request) error {
+		accounts := tracker.TrackedAccounts()
+		resp := trackedAccounts{Accounts: make([]trackedAccount, 0, len(accounts))}
+		for _, a := range accounts {
+			resp.Accounts = append(resp.Accounts, trackedAccount{
+				Address:   a.AccountID.ToRaw(),
+				Preloaded: a.Preloaded,
+			})
+		}
+		return writeJSON(w, http.StatusOK, resp)
+	})
+	s.Handle("POST /admin/accounts/{account_id}", func(w http.ResponseWriter, r *http.Request) error {
+		account, err := tongo.ParseAddress(r.PathValue("account_id"))
+		if err != nil {
+			return internalErrors.BadRequest(err.Error())
+		}
+		if err := tracker.TrackAccount(account.ID); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	s.Handle("DELETE /admin/accounts/{account_id}", func(w http.ResponseWriter, r *http.Request) error {
+		account, err := tongo.ParseAddress(r.PathValue("account_id"))
+		if err != nil {
+			return internalErrors.BadRequest(err.Error())
+		}
+		if err := tracker.UntrackAccount(account.ID); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/litestorage"
+)
+
+type mockTracker struct {
+	accounts map[tongo.AccountID]struct{}
+}
+
+func (m *mockTracker) TrackedAccounts() []litestorage.TrackedAccount {
+	var res []litestorage.TrackedAccount
+	for a := range m.accounts {
+		res = append(res, litestorage.TrackedAccount{AccountID: a, Preloaded: true})
+	}
+	return res
+}
+
+func (m *mockTracker) TrackAccount(a tongo.AccountID) error {
+	m.accounts[a] = struct{}{}
+	return nil
+}
+
+func (m *mockTracker) UntrackAccount(a tongo.AccountID) error {
+	delete(m.accounts, a)
+	return nil
+}
+
+func TestServer_RegisterAccountTracker(t *testing.T) {
+	tracker := &mockTracker{accounts: map[tongo.AccountID]struct{}{}}
+	s := NewServer(zap.L(), "secret")
+	s.RegisterAccountTracker(tracker)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, req)
+		return rec
+	}
+	account := "0:6ccd325a858c379693fae2bcaab1c2906831a4e10a6c3bb44ee8b615bca1d220"
+
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/admin/accounts", "").Code)
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/admin/accounts", "wrong").Code)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/admin/accounts/invalid", "secret").Code)
+
+	require.Equal(t, http.StatusNoContent, do("POST", "/admin/accounts/"+account, "secret").Code)
+	rec := do("GET", "/admin/accounts", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"accounts":[{"address":"`+account+`","preloaded":true}]}`, rec.Body.String())
+
+	require.Equal(t, http.StatusNoContent, do("DELETE", "/admin/accounts/"+account, "secret").Code)
+	rec = do("GET", "/admin/accounts", "secret")
+	require.JSONEq(t, `{"accounts":[]}`, rec.Body.String())
+}
+
+func TestServer_withoutToken(t *testing.T) {
+	s := NewServer(zap.L(), "")
+	s.RegisterAccountTracker(&mockTracker{accounts: map[tongo.AccountID]struct{}{}})
+	// a server without a token rejects everything, even requests with an empty bearer token.
+	req := httptest.NewRequest("GET", "/admin/accounts", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
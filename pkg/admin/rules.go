@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/tonkeeper/opentonapi/pkg/litestorage"
+	internalErrors "github.com/tonkeeper/opentonapi/pkg/pusher/errors"
+)
+
+// trackingRules manages rules to find accounts to track automatically.
+type trackingRules interface {
+	TrackingRules() []litestorage.TrackingRule
+	AddTrackingRule(rule litestorage.TrackingRule) error
+	RemoveTrackingRule(rule litestorage.TrackingRule) error
+}
+
+type trackingRulesList struct {
+	Rules []string `json:"rules"`
+}
+
+// RegisterTrackingRules exposes endpoints to manage tracking rules:
+//
+//	GET    /admin/rules        lists tracking rules
+//	POST   /admin/rules/{rule} adds a rule, e.g. /admin/rules/jetton_master:0:1234...
+//	DELETE /admin/rules/{rule} removes a rule, accounts that have already matched it stay tracked
+func (s *Server) RegisterTrackingRules(rules trackingRules) {
+	s.Handle("GET /admin/rules", func(w http.ResponseWriter, r *http.Request) error {
+		list := rules.TrackingRules()
+		resp := trackingRulesList{Rules: make([]string, 0, len(list))}
+		for _, rule := range list {
+			resp.Rules = append(resp.Rules, rule.String())
+		}
+		return writeJSON(w, http.StatusOK, resp)
+	})
+	s.Handle("POST /admin/rules/{rule}", func(w http.ResponseWriter, r *http.Request) error {
+		rule, err := litestorage.ParseTrackingRule(r.PathValue("rule"))
+		if err != nil {
+			return internalErrors.BadRequest(err.Error())
+		}
+		if err := rules.AddTrackingRule(rule); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	s.Handle("DELETE /admin/rules/{rule}", func(w http.ResponseWriter, r *http.Request) error {
+		rule, err := litestorage.ParseTrackingRule(r.PathValue("rule"))
+		if err != nil {
+			return internalErrors.BadRequest(err.Error())
+		}
+		if err := rules.RemoveTrackingRule(rule); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
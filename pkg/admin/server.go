@@ -0,0 +1,87 @@
+// Package admin exposes endpoints to manage a running opentonapi instance.
+//
+// The endpoints are served on a separate port and are protected with a static bearer token,
+// they are not supposed to be exposed to the internet and listen on the loopback interface by default.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	internalErrors "github.com/tonkeeper/opentonapi/pkg/pusher/errors"
+)
+
+// Server serves admin endpoints.
+type Server struct {
+	logger     *zap.Logger
+	token      string
+	mux        *http.ServeMux
+	httpServer *http.Server
+}
+
+// NewServer creates an admin server.
+// Every request must contain "Authorization: Bearer <token>" header, all requests are rejected if token is empty.
+func NewServer(logger *zap.Logger, token string) *Server {
+	mux := http.NewServeMux()
+	return &Server{
+		logger:     logger,
+		token:      token,
+		mux:        mux,
+		httpServer: &http.Server{Handler: mux},
+	}
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handle registers a handler for the given pattern.
+// A handler returns internalErrors.HTTPError to respond with a specific status code.
+func (s *Server) Handle(pattern string, handler handlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			writeError(w, internalErrors.HTTPError{Code: http.StatusUnauthorized, Message: "unauthorized"})
+			return
+		}
+		if err := handler(w, r); err != nil {
+			var httpErr internalErrors.HTTPError
+			if !errors.As(err, &httpErr) {
+				httpErr = internalErrors.InternalServerError(err.Error())
+			}
+			if httpErr.Code >= http.StatusInternalServerError {
+				s.logger.Error("admin request failed", zap.String("path", r.URL.Path), zap.Error(err))
+			}
+			writeError(w, httpErr)
+		}
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return false
+	}
+	expected := "Bearer " + s.token
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) == 1
+}
+
+// Run starts listening on the given address in the background.
+func (s *Server) Run(address string) {
+	s.httpServer.Addr = address
+	go func() {
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Fatal("admin server failed", zap.Error(err))
+		}
+	}()
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, err internalErrors.HTTPError) {
+	_ = writeJSON(w, err.Code, err)
+}
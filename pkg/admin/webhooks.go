@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	internalErrors "github.com/tonkeeper/opentonapi/pkg/pusher/errors"
+	"github.com/tonkeeper/opentonapi/pkg/webhooks"
+)
+
+// defaultFailedDeliveriesLimit is a number of failed deliveries returned if no limit is given.
+const defaultFailedDeliveriesLimit = 100
+
+// webhookManager manages webhooks and their failed deliveries.
+type webhookManager interface {
+	Webhooks() []webhooks.Webhook
+	AddWebhook(w webhooks.Webhook) (webhooks.Webhook, error)
+	RemoveWebhook(id string) error
+	FailedDeliveries(webhookID string, limit int) ([]webhooks.Delivery, error)
+	ReplayDelivery(webhookID, deliveryID string) error
+	ReplayFailedDeliveries(webhookID string) (int, error)
+}
+
+type webhooksList struct {
+	Webhooks []webhooks.Webhook `json:"webhooks"`
+}
+
+type failedDeliveries struct {
+	Deliveries []webhooks.Delivery `json:"deliveries"`
+}
+
+type replayedDeliveries struct {
+	Replayed int `json:"replayed"`
+}
+
+// webhookError converts errors of the webhook manager to http errors.
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, webhooks.ErrInvalidWebhook):
+		return internalErrors.BadRequest(err.Error())
+	case errors.Is(err, core.ErrEntityNotFound):
+		return internalErrors.HTTPError{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// RegisterWebhooks exposes endpoints to manage webhooks:
+//
+//	GET    /admin/webhooks                                   lists webhooks
+//	POST   /admin/webhooks                                   registers a webhook, the response contains its id and secret
+//	DELETE /admin/webhooks/{id}                              removes a webhook
+//	GET    /admin/webhooks/{id}/failed?limit=N               lists deliveries that ran out of attempts
+//	POST   /admin/webhooks/{id}/failed/replay                replays all failed deliveries
+//	POST   /admin/webhooks/{id}/failed/{delivery_id}/replay  replays a failed delivery
+func (s *Server) RegisterWebhooks(manager webhookManager) {
+	s.Handle("GET /admin/webhooks", func(w http.ResponseWriter, r *http.Request) error {
+		return writeJSON(w, http.StatusOK, webhooksList{Webhooks: manager.Webhooks()})
+	})
+	s.Handle("POST /admin/webhooks", func(w http.ResponseWriter, r *http.Request) error {
+		var hook webhooks.Webhook
+		if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+			return internalErrors.BadRequest(err.Error())
+		}
+		hook, err := manager.AddWebhook(hook)
+		if err != nil {
+			return webhookError(err)
+		}
+		return writeJSON(w, http.StatusCreated, hook)
+	})
+	s.Handle("DELETE /admin/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) error {
+		if err := manager.RemoveWebhook(r.PathValue("id")); err != nil {
+			return webhookError(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	s.Handle("GET /admin/webhooks/{id}/failed", func(w http.ResponseWriter, r *http.Request) error {
+		limit := defaultFailedDeliveriesLimit
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				return internalErrors.BadRequest("limit must be a positive number")
+			}
+		}
+		deliveries, err := manager.FailedDeliveries(r.PathValue("id"), limit)
+		if err != nil {
+			return webhookError(err)
+		}
+		return writeJSON(w, http.StatusOK, failedDeliveries{Deliveries: deliveries})
+	})
+	s.Handle("POST /admin/webhooks/{id}/failed/replay", func(w http.ResponseWriter, r *http.Request) error {
+		n, err := manager.ReplayFailedDeliveries(r.PathValue("id"))
+		if err != nil {
+			return webhookError(err)
+		}
+		return writeJSON(w, http.StatusOK, replayedDeliveries{Replayed: n})
+	})
+	s.Handle("POST /admin/webhooks/{id}/failed/{delivery_id}/replay", func(w http.ResponseWriter, r *http.Request) error {
+		if err := manager.ReplayDelivery(r.PathValue("id"), r.PathValue("delivery_id")); err != nil {
+			return webhookError(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+}
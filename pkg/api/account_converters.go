@@ -155,6 +155,12 @@ func convertToWallet(
 		},
 		LastLt: int64(account.LastTransactionLt),
 	}
+	if stats.TxCount > 0 {
+		// only storages with a local index of transactions know these counters.
+		wallet.Stats.TxCount.SetTo(stats.TxCount)
+		wallet.Stats.FirstActivity.SetTo(stats.FirstActivity)
+		wallet.Stats.LastActivity.SetTo(stats.LastActivity)
+	}
 	for i, iface := range account.Interfaces {
 		wallet.Interfaces[i] = iface.String()
 	}
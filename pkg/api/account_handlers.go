@@ -523,12 +523,52 @@ func (h *Handler) GetAccountDiff(ctx context.Context, params oas.GetAccountDiffP
 		return nil, toError(http.StatusBadRequest, err)
 	}
 	balanceChange, err := h.storage.GetAccountDiff(ctx, account.ID, params.StartDate, params.EndDate)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		return nil, toError(http.StatusNotFound, err)
+	}
 	if err != nil {
 		return nil, toError(http.StatusInternalServerError, err)
 	}
 	return &oas.GetAccountDiffOK{BalanceChange: balanceChange}, nil
 }
 
+// maxBalanceHistoryPoints limits the number of points in a balance chart.
+const maxBalanceHistoryPoints = 1000
+
+func (h *Handler) GetAccountBalanceHistory(ctx context.Context, params oas.GetAccountBalanceHistoryParams) (*oas.GetAccountBalanceHistoryOK, error) {
+	account, err := tongo.ParseAddress(params.AccountID)
+	if err != nil {
+		return nil, toError(http.StatusBadRequest, err)
+	}
+	var jetton *tongo.AccountID
+	if params.Jetton.IsSet() {
+		master, err := tongo.ParseAddress(params.Jetton.Value)
+		if err != nil {
+			return nil, toError(http.StatusBadRequest, err)
+		}
+		jetton = &master.ID
+	}
+	resolution := params.Resolution.Or(86400)
+	if params.EndDate < params.StartDate {
+		return nil, toError(http.StatusBadRequest, fmt.Errorf("end_date is before start_date"))
+	}
+	if (params.EndDate-params.StartDate)/resolution >= maxBalanceHistoryPoints {
+		return nil, toError(http.StatusBadRequest, fmt.Errorf("too many points, max is %d", maxBalanceHistoryPoints))
+	}
+	points, err := h.storage.GetAccountBalanceHistory(ctx, account.ID, jetton, params.StartDate, params.EndDate, resolution)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		return nil, toError(http.StatusNotFound, err)
+	}
+	if err != nil {
+		return nil, toError(http.StatusInternalServerError, err)
+	}
+	result := oas.GetAccountBalanceHistoryOK{Points: make([]oas.BalanceHistoryPoint, 0, len(points))}
+	for _, p := range points {
+		result.Points = append(result.Points, oas.BalanceHistoryPoint{Utime: p.Utime, Balance: p.Balance.String()})
+	}
+	return &result, nil
+}
+
 func (h *Handler) GetAccountNftHistory(ctx context.Context, params oas.GetAccountNftHistoryParams) (*oas.NftOperations, error) {
 	account, err := tongo.ParseAddress(params.AccountID)
 	if err != nil {
@@ -130,6 +130,23 @@ func convertReducedBlock(block core.ReducedBlock) oas.ReducedBlock {
 	return converted
 }
 
+func convertSentMessage(m core.SentMessage) oas.SentMessageStatus {
+	converted := oas.SentMessageStatus{
+		Hash:        m.Hash.Hex(),
+		Status:      oas.SentMessageStatusStatus(m.Status),
+		Destination: m.Destination.ToRaw(),
+		ValidUntil:  m.ValidUntil,
+		SentAt:      m.SentAt,
+	}
+	if m.TraceID != nil {
+		converted.TraceID.SetTo(m.TraceID.Hash.Hex())
+	}
+	if m.ExitCode != nil {
+		converted.ExitCode.SetTo(*m.ExitCode)
+	}
+	return converted
+}
+
 func (h *Handler) convertTransaction(t core.Transaction, accountInterfaces []abi.ContractInterface, book addressBook) oas.Transaction {
 	tx := oas.Transaction{
 		Hash:            t.Hash.Hex(),
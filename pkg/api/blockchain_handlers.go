@@ -244,6 +244,22 @@ func (h *Handler) GetBlockchainTransactionByMessageHash(ctx context.Context, par
 	return &transaction, nil
 }
 
+func (h *Handler) GetBlockchainMessageStatus(ctx context.Context, params oas.GetBlockchainMessageStatusParams) (*oas.SentMessageStatus, error) {
+	hash, err := tongo.ParseHash(params.MsgID)
+	if err != nil {
+		return nil, toError(http.StatusBadRequest, err)
+	}
+	m, err := h.storage.GetSentMessage(ctx, hash)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		return nil, toError(http.StatusNotFound, fmt.Errorf("message not found"))
+	}
+	if err != nil {
+		return nil, toError(http.StatusInternalServerError, err)
+	}
+	status := convertSentMessage(*m)
+	return &status, nil
+}
+
 func (h *Handler) GetBlockchainMasterchainHead(ctx context.Context) (*oas.BlockchainBlock, error) {
 	header, err := h.storage.LastMasterchainBlockHeader(ctx)
 	if err != nil {
@@ -62,9 +62,19 @@ func (h *Handler) RunEmulation(ctx context.Context, msgCh <-chan blockchain.ExtI
 				defer cancel()
 
 				// TODO: find a way to emulate when tonapi receives a batch of messages in a single request to SendBlockchainMessage endpoint.
-				_, err := h.addToMempool(ctx, msgCopy.Payload, nil)
+				_, accounts, err := h.addToMempool(ctx, msgCopy.Payload, nil)
 				if err != nil {
 					sentry.Send("addToMempool", sentry.SentryInfoData{"payload": msgCopy.Payload}, sentry.LevelError)
+					return
+				}
+				if len(accounts) == 0 || h.emulatedMessages == nil {
+					return
+				}
+				msgCopy.Accounts = accounts
+				select {
+				case h.emulatedMessages <- msgCopy:
+				default:
+					h.logger.Warn("emulated messages receiver is too slow")
 				}
 			}()
 		}
@@ -93,13 +103,13 @@ func (h *Handler) isEmulationAllowed(accountID ton.AccountID, state tlb.ShardAcc
 	return true, nil
 }
 
-func (h *Handler) addToMempool(ctx context.Context, bytesBoc []byte, shardAccount map[tongo.AccountID]tlb.ShardAccount) (map[tongo.AccountID]tlb.ShardAccount, error) {
+func (h *Handler) addToMempool(ctx context.Context, bytesBoc []byte, shardAccount map[tongo.AccountID]tlb.ShardAccount) (map[tongo.AccountID]tlb.ShardAccount, map[tongo.AccountID]struct{}, error) {
 	if shardAccount == nil {
 		shardAccount = map[tongo.AccountID]tlb.ShardAccount{}
 	}
 	msgCell, err := boc.DeserializeBoc(bytesBoc)
 	if err != nil {
-		return shardAccount, err
+		return shardAccount, nil, err
 	}
 
 	ttl := int64(30)
@@ -113,27 +123,27 @@ func (h *Handler) addToMempool(ctx context.Context, bytesBoc []byte, shardAccoun
 	var message tlb.Message
 	err = tlb.Unmarshal(msgCell[0], &message)
 	if err != nil {
-		return shardAccount, err
+		return shardAccount, nil, err
 	}
 	hash := message.Hash(true)
 	walletAddress, err := extractDestinationWallet(message)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	state, err := h.storage.GetAccountState(ctx, *walletAddress)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	allowed, err := h.isEmulationAllowed(*walletAddress, state, message)
 	if err != nil {
-		return shardAccount, err
+		return shardAccount, nil, err
 	}
 	if !allowed {
-		return shardAccount, nil
+		return shardAccount, nil, nil
 	}
 	config, err := h.storage.TrimmedConfigBase64()
 	if err != nil {
-		return shardAccount, err
+		return shardAccount, nil, err
 	}
 	emulator, err := txemulator.NewTraceBuilder(
 		txemulator.WithAccountsSource(h.storage),
@@ -142,16 +152,16 @@ func (h *Handler) addToMempool(ctx context.Context, bytesBoc []byte, shardAccoun
 		txemulator.WithIgnoreSignatureDepth(1),
 	)
 	if err != nil {
-		return shardAccount, err
+		return shardAccount, nil, err
 	}
 	tree, emulationErr := emulator.Run(ctx, message)
 	if emulationErr != nil {
-		return shardAccount, emulationErr
+		return shardAccount, nil, emulationErr
 	}
 	newShardAccount := emulator.FinalStates()
 	trace, err := EmulatedTreeToTrace(ctx, h.executor, h.storage, tree, newShardAccount, nil, h.configPool, true)
 	if err != nil {
-		return shardAccount, err
+		return shardAccount, nil, err
 	}
 	accounts := make(map[tongo.AccountID]struct{})
 	core.Visit(trace, func(node *core.Trace) {
@@ -176,20 +186,11 @@ func (h *Handler) addToMempool(ctx context.Context, bytesBoc []byte, shardAccoun
 		newMemHashes = append(newMemHashes, ton.Bits256(hash)) // it's important to make it last
 		h.mempoolEmulate.accountsTraces.Set(account, newMemHashes, cache.WithExpiration(time.Second*time.Duration(ttl)))
 	}
-	return newShardAccount, nil
+	return newShardAccount, accounts, nil
 }
 
 func (h *Handler) saveTraceWithState(ctx context.Context, trace *core.Trace, msg *boc.Cell, hash string) {
-	var validUntil uint32
-	if v5, err := tongoWallet.DecodeMessageV5(msg); err == nil {
-		if v5.SumType == "SignedExternal" {
-			validUntil = v5.SignedExternal.ValidUntil
-		}
-	} else if v4, err := tongoWallet.DecodeMessageV4(msg); err == nil {
-		validUntil = v4.ValidUntil
-	} else if v3, err := tongoWallet.DecodeMessageV3(msg); err == nil {
-		validUntil = v3.ValidUntil
-	}
+	validUntil, _ := blockchain.ValidUntil(msg)
 	var ttl time.Duration
 	if validUntil == 0 {
 		ttl = 24 * time.Hour
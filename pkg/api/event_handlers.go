@@ -405,19 +405,43 @@ func (h *Handler) GetAccountEvents(ctx context.Context, params oas.GetAccountEve
 		//	lastLT = uint64(events[len(events)-1].Lt)
 		//}
 	}
-	for i, e := range events {
-		if e.InProgress {
-			for j, _ := range e.Actions {
-				events[i].Actions[j].Status = oas.ActionStatusOk
-			}
-		}
-	}
 	for i := range events {
 		events[i].IsScam = h.applyTraceBan(events[i].IsScam, isBannedTraces[events[i].EventID], initiatorByEvent[events[i].EventID])
 	}
 	return &oas.AccountEvents{Events: events, NextFrom: int64(lastLT)}, nil
 }
 
+// AccountEvent returns an event of the account built from the trace the same way GetAccountEvents builds it.
+func (h *Handler) AccountEvent(ctx context.Context, account tongo.AccountID, tid core.TraceID) oas.AccountEvent {
+	event, _ := h.processTrace(ctx, account, tid, oas.OptString{}, false)
+	return event
+}
+
+// MempoolAccountEvent returns a preview of an event of the account built from the emulated trace of a pending message,
+// the same way GetAccountEvents shows events in progress.
+func (h *Handler) MempoolAccountEvent(ctx context.Context, account tongo.AccountID, msgHash string) (oas.AccountEvent, error) {
+	trace, _, _, err := h.storage.GetTraceWithState(ctx, msgHash)
+	if err != nil {
+		return oas.AccountEvent{}, err
+	}
+	if trace == nil {
+		return oas.AccountEvent{}, core.ErrEntityNotFound
+	}
+	actions, err := bath.FindActions(ctx, trace, bath.ForAccount(account), bath.WithInformationSource(h.storage), bath.WithAddressBook(h.addressBook))
+	if err != nil {
+		return oas.AccountEvent{}, err
+	}
+	result := bath.EnrichWithIntentions(trace, actions)
+	event, err := h.toAccountEvent(ctx, account, trace, result, oas.OptString{}, false)
+	if err != nil {
+		return oas.AccountEvent{}, err
+	}
+	event.InProgress = true
+	// actions keep their emulated statuses, so a client can see that a pending message is going to fail.
+	event.EventID = msgHash
+	return event, nil
+}
+
 // processTrace returns the account event and the trace initiator (root account),
 // which the caller needs to let a whitelisted initiator override a DB trace ban.
 // On fallbacks the trace isn't available, so a zero AccountID is returned, which
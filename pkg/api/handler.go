@@ -10,6 +10,7 @@ import (
 	"log/slog"
 
 	"github.com/go-faster/errors"
+	"github.com/tonkeeper/opentonapi/pkg/blockchain"
 	"github.com/tonkeeper/opentonapi/pkg/chainstate"
 	"github.com/tonkeeper/opentonapi/pkg/core"
 	"github.com/tonkeeper/opentonapi/pkg/rates"
@@ -62,6 +63,8 @@ type Handler struct {
 	parallelTraceProcessing bool
 	// mempoolEmulate contains results of emulation of messages that are in the mempool.
 	mempoolEmulate mempoolEmulate
+	// emulatedMessages receives copies of messages with accounts found by emulation.
+	emulatedMessages chan<- blockchain.ExtInMsgCopy
 	// ctxToDetails converts a request context to a details instance.
 	ctxToDetails ctxToDetails
 	tongoVersion int
@@ -108,6 +111,7 @@ type Options struct {
 	parallelTraceProcessing bool
 	archiveLiteServers      []config.LiteServer
 	publicAPIURL            string
+	emulatedMessages        chan<- blockchain.ExtInMsgCopy
 }
 
 type Option func(o *Options)
@@ -216,6 +220,14 @@ func WithPublicAPIURL(publicAPIURL string) Option {
 	}
 }
 
+// WithEmulatedMessagesReceiver configures a channel to receive copies of messages emulated by RunEmulation,
+// a copy has Accounts set to all accounts of the emulated trace.
+func WithEmulatedMessagesReceiver(ch chan<- blockchain.ExtInMsgCopy) Option {
+	return func(o *Options) {
+		o.emulatedMessages = ch
+	}
+}
+
 func NewHandler(logger *zap.Logger, opts ...Option) (*Handler, error) {
 	options := &Options{}
 	for _, o := range opts {
@@ -308,6 +320,7 @@ func NewHandler(logger *zap.Logger, opts ...Option) (*Handler, error) {
 		mempoolEmulate: mempoolEmulate{
 			accountsTraces: cache.NewLRUCache[tongo.AccountID, []ton.Bits256](10000, "accounts_traces_cache"),
 		},
+		emulatedMessages: options.emulatedMessages,
 		mempoolEmulateIgnoreAccounts: map[tongo.AccountID]struct{}{
 			tongo.MustParseAddress("0:0000000000000000000000000000000000000000000000000000000000000000").ID: {},
 		},
@@ -47,12 +47,17 @@ type storage interface {
 	GetBlockIDsForMasterchain(ctx context.Context, masterSeqno uint32) ([]ton.BlockID, error)
 	GetTransaction(ctx context.Context, hash tongo.Bits256) (*core.Transaction, error)
 	SearchTransactionByMessageHash(ctx context.Context, hash tongo.Bits256) (*tongo.Bits256, error)
+	// GetSentMessage returns the status of an external message sent with SendBlockchainMessage by its normalized hash.
+	GetSentMessage(ctx context.Context, hash tongo.Bits256) (*core.SentMessage, error)
 	// GetBlockTransactions returns low-level information about transactions in a particular block.
 	GetBlockTransactions(ctx context.Context, id tongo.BlockID) ([]*core.Transaction, error)
 	GetAccountTransactions(ctx context.Context, id tongo.AccountID, limit int, beforeLt, afterLt uint64, descendingOrder bool) ([]*core.Transaction, error)
 	GetDnsExpiring(ctx context.Context, id tongo.AccountID, period *int) ([]core.DnsExpiring, error)
 	GetLogs(ctx context.Context, account tongo.AccountID, destination *tlb.MsgAddress, limit int, beforeLT uint64) ([]core.Message, error)
 	GetAccountDiff(ctx context.Context, account tongo.AccountID, startTime int64, endTime int64) (int64, error)
+	// GetAccountBalanceHistory returns balances of the account in TON or in the given jetton
+	// at startTime, startTime+step, ... up to endTime.
+	GetAccountBalanceHistory(ctx context.Context, account tongo.AccountID, jetton *tongo.AccountID, startTime, endTime, step int64) ([]core.BalancePoint, error)
 	GetLatencyAndLastMasterchainSeqno(ctx context.Context) (int64, uint32, error)
 	GetTrace(ctx context.Context, hash tongo.Bits256) (*core.Trace, error)
 	SearchTraces(ctx context.Context, a tongo.AccountID, limit int, beforeLT, afterLT, startTime, endTime *int64, initiator bool, descendingOrder bool) ([]core.TraceID, error)
@@ -14,6 +14,8 @@ import (
 
 	"github.com/tonkeeper/opentonapi/pkg/defi"
 	"github.com/tonkeeper/opentonapi/pkg/oas"
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sse"
+	"github.com/tonkeeper/opentonapi/pkg/pusher/websocket"
 )
 
 // Server opens a port and exposes REST-ish API.
@@ -46,6 +48,8 @@ type ServerOptions struct {
 	asyncMiddlewares []AsyncMiddleware
 	httpMiddleware   func(http.Handler) http.Handler
 	liteServers      []config.LiteServer
+	sseHandler       *sse.Handler
+	websocketHandler *websocket.Handler
 }
 
 type ServerOption func(options *ServerOptions)
@@ -68,6 +72,20 @@ func WithHTTPMiddleware(m func(http.Handler) http.Handler) ServerOption {
 	}
 }
 
+// WithSSEHandler enables SSE endpoints streaming transactions, traces and events of accounts.
+func WithSSEHandler(h *sse.Handler) ServerOption {
+	return func(options *ServerOptions) {
+		options.sseHandler = h
+	}
+}
+
+// WithWebsocketHandler enables the websocket endpoint with JSON-RPC subscriptions to streams of blockchain events.
+func WithWebsocketHandler(h *websocket.Handler) ServerOption {
+	return func(options *ServerOptions) {
+		options.websocketHandler = h
+	}
+}
+
 func NewServer(log *zap.Logger, handler *Handler, opts ...ServerOption) (*Server, error) {
 	options := &ServerOptions{}
 	for _, o := range opts {
@@ -97,6 +115,17 @@ func NewServer(log *zap.Logger, handler *Handler, opts ...ServerOption) (*Server
 	asyncMiddlewares = append(asyncMiddlewares, options.asyncMiddlewares...)
 
 	mux.Handle("/v2/assets/defi/", defi.AssetsHandler())
+	if options.sseHandler != nil {
+		// EventSource in browsers can't set headers, so a token is allowed in the query.
+		mux.Handle("/v2/sse/accounts/transactions", wrapAsync(LongLivedConnection, true, chainMiddlewares(options.sseHandler.SubscribeToTransactions, asyncMiddlewares...)))
+		mux.Handle("/v2/sse/accounts/traces", wrapAsync(LongLivedConnection, true, chainMiddlewares(options.sseHandler.SubscribeToTraces, asyncMiddlewares...)))
+		mux.Handle("/v2/sse/accounts/events", wrapAsync(LongLivedConnection, true, chainMiddlewares(options.sseHandler.SubscribeToAccountEvents, asyncMiddlewares...)))
+		mux.Handle("/v2/sse/mempool", wrapAsync(LongLivedConnection, true, chainMiddlewares(options.sseHandler.SubscribeToMempool, asyncMiddlewares...)))
+	}
+	if options.websocketHandler != nil {
+		// browsers can't set headers of a websocket handshake either.
+		mux.Handle("/v2/websocket", wrapAsync(LongLivedConnection, true, chainMiddlewares(options.websocketHandler.Handle, asyncMiddlewares...)))
+	}
 	mux.Handle("/", ogenServer)
 
 	var h http.Handler = mux
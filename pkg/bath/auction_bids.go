@@ -0,0 +1,31 @@
+package bath
+
+import (
+	"context"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// FindAuctionBids returns bids on .ton domains and Telegram usernames and numbers placed in the given trace.
+func FindAuctionBids(ctx context.Context, source core.InformationSource, trace *core.Trace) ([]core.DomainBid, error) {
+	result, err := FindActions(ctx, trace, WithInformationSource(source), WithStraws(DNSAuctionBids))
+	if err != nil {
+		return nil, err
+	}
+	var bids []core.DomainBid
+	for _, action := range result.Actions {
+		if action.AuctionBid == nil || action.AuctionBid.Amount.Currency.Type != core.CurrencyNative {
+			continue
+		}
+		bid := core.DomainBid{
+			Bidder:  action.AuctionBid.Bidder,
+			Success: action.Success,
+			Value:   action.AuctionBid.Amount.Amount.Uint64(),
+			Auction: action.AuctionBid.Auction,
+		}
+		tx := actionTransaction(trace, action)
+		bid.TxHash, bid.TxTime = tx.Hash, tx.Utime
+		bids = append(bids, bid)
+	}
+	return bids, nil
+}
@@ -62,6 +62,23 @@ var StrawFindAuctionBidFragmentSimple = Straw[AuctionBidBubble]{
 	},
 }
 
+// StrawFindAuctionBidTonDns finds bids on .ton domains, a bid is an empty message to a DNS item.
+// It isn't a part of DefaultStraws because a DNS item accepts empty messages outside auctions as well,
+// so a found bubble must be checked against get_auction_info of the item.
+var StrawFindAuctionBidTonDns = Straw[AuctionBidBubble]{
+	CheckFuncs: []bubbleCheck{IsTx, HasInterface(abi.Dns), HasEmptyBody, AmountInterval(1, 1<<62)},
+	Builder: func(newAction *AuctionBidBubble, bubble *Bubble) error {
+		tx := bubble.Info.(BubbleTx)
+		newAction.Type = DnsTonAuction
+		newAction.Amount = tx.inputAmount
+		newAction.Bidder = tx.inputFrom.Address
+		newAction.Success = tx.success
+		newAction.Auction = tx.account.Address
+		newAction.NftAddress = &tx.account.Address
+		return nil
+	},
+}
+
 var TgAuctionV1InitialBidStraw = Straw[AuctionBidBubble]{
 	CheckFuncs: []bubbleCheck{IsTx, HasOperation(abi.TelemintDeployMsgOp)},
 	Builder: func(newAction *AuctionBidBubble, bubble *Bubble) error {
@@ -0,0 +1,39 @@
+package bath
+
+import (
+	"context"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// FindInvoicePayments returns successful invoice payments in TON, extra currencies and jettons of the given trace.
+// Invoice payloads don't carry any metadata, so the metadata of found payments is always empty.
+func FindInvoicePayments(ctx context.Context, source core.InformationSource, trace *core.Trace) ([]core.InvoicePayment, error) {
+	result, err := FindActions(ctx, trace, WithInformationSource(source), WithStraws(InvoicePayments))
+	if err != nil {
+		return nil, err
+	}
+	traceID := core.TraceID{Hash: trace.Hash, Lt: trace.Lt, UTime: trace.Utime}
+	var payments []core.InvoicePayment
+	for _, action := range result.Actions {
+		if !action.Success || action.Purchase == nil {
+			continue
+		}
+		tx := actionTransaction(trace, action)
+		payment := core.InvoicePayment{
+			Source:      action.Purchase.Source,
+			Destination: action.Purchase.Destination,
+			TraceID:     traceID,
+			InMsgLt:     tx.Lt,
+			Utime:       tx.Utime,
+			InvoiceID:   action.Purchase.InvoiceID,
+			Amount:      action.Purchase.Price,
+			Metadata:    core.PurchaseMetadata{Type: core.NoneMetadataType},
+		}
+		if tx.InMsg != nil {
+			payment.InMsgLt = tx.InMsg.CreatedLt
+		}
+		payments = append(payments, payment)
+	}
+	return payments, nil
+}
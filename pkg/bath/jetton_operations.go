@@ -0,0 +1,70 @@
+package bath
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/shopspring/decimal"
+	"github.com/tonkeeper/tongo/tlb"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// FindJettonOperations returns successful jetton transfers, mints and burns of the given trace.
+func FindJettonOperations(ctx context.Context, source core.InformationSource, trace *core.Trace) ([]core.JettonOperation, error) {
+	result, err := FindActions(ctx, trace, WithInformationSource(source), WithStraws(JettonTransfersBurnsMints))
+	if err != nil {
+		return nil, err
+	}
+	var operations []core.JettonOperation
+	for _, action := range result.Actions {
+		if !action.Success {
+			continue
+		}
+		var op core.JettonOperation
+		switch {
+		case action.JettonTransfer != nil:
+			op = core.JettonOperation{
+				Operation:    core.TransferJettonOperation,
+				Source:       action.JettonTransfer.Sender,
+				Destination:  action.JettonTransfer.Recipient,
+				JettonMaster: action.JettonTransfer.Jetton,
+				Amount:       amountToDecimal(action.JettonTransfer.Amount),
+			}
+		case action.FlawedJettonTransfer != nil:
+			op = core.JettonOperation{
+				Operation:    core.TransferJettonOperation,
+				Source:       action.FlawedJettonTransfer.Sender,
+				Destination:  action.FlawedJettonTransfer.Recipient,
+				JettonMaster: action.FlawedJettonTransfer.Jetton,
+				Amount:       amountToDecimal(action.FlawedJettonTransfer.SentAmount),
+			}
+		case action.JettonMint != nil:
+			op = core.JettonOperation{
+				Operation:    core.MintJettonOperation,
+				Destination:  &action.JettonMint.Recipient,
+				JettonMaster: action.JettonMint.Jetton,
+				Amount:       amountToDecimal(action.JettonMint.Amount),
+			}
+		case action.JettonBurn != nil:
+			op = core.JettonOperation{
+				Operation:    core.BurnJettonOperation,
+				Source:       &action.JettonBurn.Sender,
+				JettonMaster: action.JettonBurn.Jetton,
+				Amount:       amountToDecimal(action.JettonBurn.Amount),
+			}
+		default:
+			continue
+		}
+		op.TraceID = trace.Hash
+		tx := actionTransaction(trace, action)
+		op.TxID, op.Lt, op.Utime = tx.Hash, tx.Lt, tx.Utime
+		operations = append(operations, op)
+	}
+	return operations, nil
+}
+
+func amountToDecimal(amount tlb.VarUInteger16) decimal.Decimal {
+	value := big.Int(amount)
+	return decimal.NewFromBigInt(&value, 0)
+}
@@ -0,0 +1,29 @@
+package bath
+
+import (
+	"context"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// FindNftTransfers returns transfers of NFT items in the given trace,
+// including failed and bounced ones, so that a caller can refresh every item touched by the trace.
+func FindNftTransfers(ctx context.Context, source core.InformationSource, trace *core.Trace) ([]core.NftTransfer, error) {
+	result, err := FindActions(ctx, trace, WithInformationSource(source), WithStraws(NFTStraws))
+	if err != nil {
+		return nil, err
+	}
+	var transfers []core.NftTransfer
+	for _, action := range result.Actions {
+		if action.NftItemTransfer == nil {
+			continue
+		}
+		transfers = append(transfers, core.NftTransfer{
+			Nft:       action.NftItemTransfer.Nft,
+			Sender:    action.NftItemTransfer.Sender,
+			Recipient: action.NftItemTransfer.Recipient,
+			Success:   action.Success,
+		})
+	}
+	return transfers, nil
+}
@@ -29,6 +29,27 @@ var JettonTransfersBurnsMints = []Merger{
 	WtonMintStraw,
 }
 
+var DNSAuctionBids = []Merger{
+	StrawFindAuctionBidFragmentSimple,
+	TgAuctionV1InitialBidStraw,
+	StrawFindAuctionBidTonDns,
+}
+
+var InvoicePayments = []Merger{
+	JettonTransferClassicStraw,
+	JettonTransferMinimalStraw,
+	InvoicePaymentStrawNative,
+	InvoicePaymentStrawJetton,
+}
+
+var Subscriptions = []Merger{
+	UnSubscriptionBySubscriberStraw,
+	UnSubscriptionByBeneficiaryOrExpiredStraw,
+	SubscriptionDeployStraw,
+	SubscriptionPaymentStraw,
+	SubscriptionPaymentWithRequestFundsStraw,
+}
+
 var NFTStraws = []Merger{
 	NftTransferStraw,
 	NftTransferNotifyStraw,
@@ -0,0 +1,46 @@
+package bath
+
+import (
+	"context"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// FindSubscriptionEvents returns successful subscriptions, payments for subscriptions and cancellations of subscriptions
+// of the given trace. A payment is reported as a subscription, so subscriptions created before we started
+// watching a wallet are discovered too.
+func FindSubscriptionEvents(ctx context.Context, source core.InformationSource, trace *core.Trace) ([]core.SubscriptionEvent, error) {
+	result, err := FindActions(ctx, trace, WithInformationSource(source), WithStraws(Subscriptions))
+	if err != nil {
+		return nil, err
+	}
+	var events []core.SubscriptionEvent
+	for _, action := range result.Actions {
+		if !action.Success {
+			continue
+		}
+		var event core.SubscriptionEvent
+		switch {
+		case action.Subscribe != nil:
+			event = core.SubscriptionEvent{
+				Subscription: action.Subscribe.Subscription,
+				Subscriber:   action.Subscribe.Subscriber,
+				Admin:        action.Subscribe.Admin,
+				WithdrawTo:   action.Subscribe.WithdrawTo,
+			}
+		case action.UnSubscribe != nil:
+			event = core.SubscriptionEvent{
+				Subscription: action.UnSubscribe.Subscription,
+				Subscriber:   action.UnSubscribe.Subscriber,
+				Admin:        action.UnSubscribe.Admin,
+				WithdrawTo:   action.UnSubscribe.WithdrawTo,
+				Cancelled:    true,
+			}
+		default:
+			continue
+		}
+		event.Lt = actionTransaction(trace, action).Lt
+		events = append(events, event)
+	}
+	return events, nil
+}
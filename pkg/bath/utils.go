@@ -1,8 +1,12 @@
 package bath
 
 import (
+	"slices"
+
 	"github.com/tonkeeper/tongo"
 	"github.com/tonkeeper/tongo/tlb"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
 )
 
 func parseAccount(a tlb.MsgAddress) *Account {
@@ -16,3 +20,16 @@ func parseAccount(a tlb.MsgAddress) *Account {
 type Merge struct {
 	children []*Bubble
 }
+
+// actionTransaction returns the transaction an entity extracted from the given action is attributed to:
+// the last of the action's base transactions, or the root transaction of the trace
+// if the action doesn't point to any transaction of the trace.
+func actionTransaction(trace *core.Trace, action Action) *core.Transaction {
+	tx := &trace.Transaction
+	core.Visit(trace, func(t *core.Trace) {
+		if t.Lt >= tx.Lt && slices.Contains(action.BaseTransactions, t.Hash) {
+			tx = &t.Transaction
+		}
+	})
+	return tx
+}
@@ -0,0 +1,261 @@
+package indexer
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	subscriberBufferLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "indexer_subscriber_buffer_length",
+		Help: "Number of blocks waiting in a subscriber's buffer",
+	}, []string{"subscriber"})
+	subscriberBufferCapacity = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "indexer_subscriber_buffer_capacity",
+		Help: "Capacity of a subscriber's buffer",
+	}, []string{"subscriber"})
+	subscriberDroppedBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "indexer_subscriber_dropped_blocks_total",
+		Help: "Number of blocks dropped because a subscriber's buffer was full",
+	}, []string{"subscriber"})
+	subscriberDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "indexer_subscriber_disconnects_total",
+		Help: "Number of times a subscriber was disconnected because its buffer was full",
+	}, []string{"subscriber"})
+)
+
+// OverflowPolicy defines what happens when a subscriber's buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the subscriber reads a block from its buffer.
+	// Use it for consumers that must not lose a single block,
+	// keeping in mind that such a consumer slows down delivery to all others.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest removes the oldest block from the buffer to make room for a new one.
+	OverflowDropOldest
+	// OverflowDisconnect closes the subscription.
+	OverflowDisconnect
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDisconnect:
+		return "disconnect"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(p))
+	}
+}
+
+// Subscription is a bounded stream of blocks delivered to a single consumer.
+type Subscription struct {
+	name   string
+	policy OverflowPolicy
+	ch     chan IDandBlock
+	// done is closed when the subscription is cancelled,
+	// it unblocks a pending send with the OverflowBlock policy.
+	done      chan struct{}
+	closeOnce sync.Once
+	// mu serializes sending to ch with closing it.
+	mu     sync.Mutex
+	closed bool
+	// dropped is a number of blocks removed from the buffer because of the OverflowDropOldest policy.
+	dropped atomic.Uint64
+}
+
+// Name returns a name of the subscription that is used as a label in metrics.
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// C returns a channel to read blocks from.
+// The channel is closed once the subscription is cancelled or disconnected because of an overflow.
+func (s *Subscription) C() <-chan IDandBlock {
+	return s.ch
+}
+
+// Dropped returns a number of blocks the subscriber has lost because of the OverflowDropOldest policy.
+// A consumer can compare it between reads to find out that the stream has a gap.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) cancel() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// close cancels the subscription and closes its channel once a pending send is over.
+func (s *Subscription) close() {
+	s.cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// Broadcaster delivers blocks to a dynamic set of subscribers.
+// Each subscriber has its own buffer, so a slow consumer affects others only if it uses OverflowBlock.
+type Broadcaster struct {
+	logger *zap.Logger
+
+	mu          sync.RWMutex
+	subscribers map[string]*Subscription
+}
+
+func NewBroadcaster(logger *zap.Logger) *Broadcaster {
+	return &Broadcaster{
+		logger:      logger,
+		subscribers: map[string]*Subscription{},
+	}
+}
+
+// Subscribe registers a new subscriber with the given buffer size and overflow policy.
+// The name must be unique among active subscriptions.
+func (b *Broadcaster) Subscribe(name string, bufferSize int, policy OverflowPolicy) (*Subscription, error) {
+	if bufferSize < 0 {
+		return nil, fmt.Errorf("invalid buffer size: %v", bufferSize)
+	}
+	if bufferSize == 0 && policy != OverflowBlock {
+		return nil, fmt.Errorf("policy %v requires a buffer", policy)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[name]; ok {
+		return nil, fmt.Errorf("subscriber %v already exists", name)
+	}
+	sub := &Subscription{
+		name:   name,
+		policy: policy,
+		ch:     make(chan IDandBlock, bufferSize),
+		done:   make(chan struct{}),
+	}
+	b.subscribers[name] = sub
+	subscriberBufferCapacity.WithLabelValues(name).Set(float64(bufferSize))
+	b.logger.Info("new indexer subscriber",
+		zap.String("name", name),
+		zap.Int("buffer", bufferSize),
+		zap.Stringer("policy", policy))
+	return sub, nil
+}
+
+// Unsubscribe cancels the subscription and closes its channel.
+func (b *Broadcaster) Unsubscribe(sub *Subscription) {
+	if b.remove(sub) {
+		sub.close()
+	}
+}
+
+// remove deletes the subscription and returns false if it has been removed already.
+func (b *Broadcaster) remove(sub *Subscription) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if current, ok := b.subscribers[sub.name]; !ok || current != sub {
+		return false
+	}
+	delete(b.subscribers, sub.name)
+	subscriberBufferLength.DeleteLabelValues(sub.name)
+	subscriberBufferCapacity.DeleteLabelValues(sub.name)
+	return true
+}
+
+// Subscribers returns names of active subscriptions.
+func (b *Broadcaster) Subscribers() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	names := make([]string, 0, len(b.subscribers))
+	for name := range b.subscribers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Publish delivers the block to all subscribers according to their overflow policies.
+// Subscribers are copied under the lock, so a slow consumer doesn't block Subscribe and Unsubscribe.
+func (b *Broadcaster) Publish(block IDandBlock) {
+	b.mu.RLock()
+	subscribers := make([]*Subscription, 0, len(b.subscribers))
+	for _, sub := range b.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	b.mu.RUnlock()
+
+	var overflowed []*Subscription
+	var blocking []*Subscription
+	// deliver to non-blocking subscribers first,
+	// so they don't wait for slow consumers with the OverflowBlock policy.
+	for _, sub := range subscribers {
+		if sub.policy == OverflowBlock {
+			blocking = append(blocking, sub)
+			continue
+		}
+		if !b.send(sub, block) {
+			overflowed = append(overflowed, sub)
+		}
+		subscriberBufferLength.WithLabelValues(sub.name).Set(float64(len(sub.ch)))
+	}
+	for _, sub := range blocking {
+		b.send(sub, block)
+		subscriberBufferLength.WithLabelValues(sub.name).Set(float64(len(sub.ch)))
+	}
+
+	for _, sub := range overflowed {
+		if !b.remove(sub) {
+			continue
+		}
+		b.logger.Warn("disconnecting slow indexer subscriber", zap.String("name", sub.name))
+		subscriberDisconnects.WithLabelValues(sub.name).Inc()
+		sub.close()
+	}
+}
+
+// send returns false if the subscriber has to be disconnected.
+func (b *Broadcaster) send(sub *Subscription, block IDandBlock) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		// the subscription has been removed after Publish took the list of subscribers.
+		return true
+	}
+	switch sub.policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case sub.ch <- block:
+				return true
+			default:
+			}
+			select {
+			case <-sub.ch:
+				sub.dropped.Add(1)
+				subscriberDroppedBlocks.WithLabelValues(sub.name).Inc()
+			default:
+			}
+		}
+	case OverflowDisconnect:
+		select {
+		case sub.ch <- block:
+			return true
+		default:
+			return false
+		}
+	default:
+		select {
+		case sub.ch <- block:
+		case <-sub.done:
+		}
+		return true
+	}
+}
@@ -0,0 +1,114 @@
+package indexer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+)
+
+func block(seqno uint32) IDandBlock {
+	return IDandBlock{ID: tongo.BlockIDExt{BlockID: tongo.BlockID{Workchain: -1, Seqno: seqno}}}
+}
+
+func readAll(sub *Subscription) []uint32 {
+	var seqnos []uint32
+	for {
+		select {
+		case b, ok := <-sub.C():
+			if !ok {
+				return seqnos
+			}
+			seqnos = append(seqnos, b.ID.Seqno)
+		default:
+			return seqnos
+		}
+	}
+}
+
+func TestBroadcaster_Publish(t *testing.T) {
+	b := NewBroadcaster(zap.L())
+	dropOldest, err := b.Subscribe("drop-oldest", 2, OverflowDropOldest)
+	require.Nil(t, err)
+	disconnect, err := b.Subscribe("disconnect", 2, OverflowDisconnect)
+	require.Nil(t, err)
+	_, err = b.Subscribe("disconnect", 2, OverflowDisconnect)
+	require.NotNil(t, err)
+	_, err = b.Subscribe("unbuffered", 0, OverflowDropOldest)
+	require.NotNil(t, err)
+
+	for i := uint32(1); i <= 3; i++ {
+		b.Publish(block(i))
+	}
+	require.Equal(t, []uint32{2, 3}, readAll(dropOldest))
+	require.Equal(t, uint64(1), dropOldest.Dropped())
+
+	require.Equal(t, []uint32{1, 2}, readAll(disconnect))
+	_, ok := <-disconnect.C()
+	require.False(t, ok)
+	require.Equal(t, []string{"drop-oldest"}, b.Subscribers())
+}
+
+func TestBroadcaster_SlowBlockingSubscriber(t *testing.T) {
+	b := NewBroadcaster(zap.L())
+	slow, err := b.Subscribe("slow", 0, OverflowBlock)
+	require.Nil(t, err)
+	fast, err := b.Subscribe("fast", 10, OverflowDropOldest)
+	require.Nil(t, err)
+
+	published := make(chan struct{})
+	go func() {
+		b.Publish(block(1))
+		close(published)
+	}()
+	// the fast subscriber gets the block even though the slow one doesn't read.
+	select {
+	case got := <-fast.C():
+		require.Equal(t, uint32(1), got.ID.Seqno)
+	case <-time.After(time.Second):
+		t.Fatal("fast subscriber didn't get a block")
+	}
+	// unsubscribing unblocks the publisher.
+	b.Unsubscribe(slow)
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish is still blocked")
+	}
+	_, ok := <-slow.C()
+	require.False(t, ok)
+}
+
+func TestBroadcaster_SubscribeWhilePublishIsBlocked(t *testing.T) {
+	b := NewBroadcaster(zap.L())
+	slow, err := b.Subscribe("slow", 0, OverflowBlock)
+	require.Nil(t, err)
+
+	published := make(chan struct{})
+	go func() {
+		b.Publish(block(1))
+		close(published)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// a slow consumer doesn't prevent others from subscribing and unsubscribing.
+	done := make(chan struct{})
+	go func() {
+		other, err := b.Subscribe("other", 1, OverflowDropOldest)
+		if err == nil {
+			b.Unsubscribe(other)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("subscribe is blocked by a slow subscriber")
+	}
+
+	got := <-slow.C()
+	require.Equal(t, uint32(1), got.ID.Seqno)
+	<-published
+}
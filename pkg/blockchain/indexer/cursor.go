@@ -0,0 +1,17 @@
+package indexer
+
+// Cursor reports the seqno of the last masterchain block processed by a consumer that must not lose blocks.
+// The indexer doesn't advance the cursor itself: a block published to the broadcaster can still sit
+// in a subscriber's buffer, so only the consumer knows which blocks survive a crash.
+type Cursor interface {
+	LastSeqno() (uint32, bool)
+}
+
+// CursorFunc is an adapter to use a function as a Cursor.
+type CursorFunc func() (uint32, bool)
+
+var _ Cursor = CursorFunc(nil)
+
+func (f CursorFunc) LastSeqno() (uint32, bool) {
+	return f()
+}
@@ -6,29 +6,90 @@ import (
 	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sourcegraph/conc/iter"
 	"github.com/tonkeeper/tongo"
 	"github.com/tonkeeper/tongo/liteapi"
+	"github.com/tonkeeper/tongo/liteclient"
 	"github.com/tonkeeper/tongo/tlb"
 	"go.uber.org/zap"
 )
 
+var (
+	lastPublishedSeqno = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "indexer_last_published_masterchain_seqno",
+		Help: "Seqno of the last masterchain block whose chunk was published to subscribers",
+	})
+	lagSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "indexer_lag_seconds",
+		Help: "Difference between now and the generation time of the last published masterchain block",
+	})
+	backfillRemaining = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "indexer_backfill_remaining_chunks",
+		Help: "Number of masterchain chunks left to backfill after a restart",
+	})
+	backfilledChunks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "indexer_backfilled_chunks_total",
+		Help: "Number of masterchain chunks delivered while catching up with the blockchain",
+	})
+)
+
 type chunk struct {
 	masterID tongo.BlockID
 	ids      map[tongo.BlockIDExt]struct{}
 	blocks   []IDandBlock
 }
 
+// blockchainClient is a part of liteapi.Client used by the indexer.
+type blockchainClient interface {
+	GetMasterchainInfo(ctx context.Context) (liteclient.LiteServerMasterchainInfoC, error)
+	LookupBlock(ctx context.Context, blockID tongo.BlockID, mode uint32, lt *uint64, utime *uint32) (tongo.BlockIDExt, tlb.BlockInfo, error)
+	GetBlock(ctx context.Context, blockID tongo.BlockIDExt) (tlb.Block, error)
+}
+
 // Indexer tracks the blockchain and notifies subscribers about new blocks.
 type Indexer struct {
-	logger *zap.Logger
-	cli    *liteapi.Client
+	logger     *zap.Logger
+	cli        blockchainClient
+	cursor     Cursor
+	startSeqno uint32
+}
+
+type Options struct {
+	cursor     Cursor
+	startSeqno uint32
+}
+
+type Option func(o *Options)
+
+// WithCursor configures a cursor of the consumer the indexer follows.
+// On startup, the indexer continues right after the last masterchain block processed by the consumer
+// and backfills all blocks produced while it was down.
+func WithCursor(c Cursor) Option {
+	return func(o *Options) {
+		o.cursor = c
+	}
+}
+
+// WithStartSeqno configures a masterchain seqno to start indexing from.
+// It is used only if the cursor is not configured or has no position yet.
+func WithStartSeqno(seqno uint32) Option {
+	return func(o *Options) {
+		o.startSeqno = seqno
+	}
 }
 
-func New(logger *zap.Logger, cli *liteapi.Client) *Indexer {
+func New(logger *zap.Logger, cli *liteapi.Client, opts ...Option) *Indexer {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return &Indexer{
-		cli:    cli,
-		logger: logger,
+		cli:        cli,
+		logger:     logger,
+		cursor:     o.cursor,
+		startSeqno: o.startSeqno,
 	}
 }
 
@@ -37,41 +98,86 @@ type IDandBlock struct {
 	Block *tlb.Block
 }
 
-func (idx *Indexer) Run(ctx context.Context, channels []chan IDandBlock) {
+// firstSeqno returns the first masterchain seqno to deliver to subscribers.
+func (idx *Indexer) firstSeqno(last uint32) uint32 {
+	if idx.cursor != nil {
+		if seqno, ok := idx.cursor.LastSeqno(); ok {
+			return seqno + 1
+		}
+	}
+	if idx.startSeqno > 0 {
+		return idx.startSeqno
+	}
+	return last
+}
+
+// Run indexes the blockchain and publishes every new block to the broadcaster.
+func (idx *Indexer) Run(ctx context.Context, broadcaster *Broadcaster) {
 	var chunk *chunk
+	var latest uint32
 	for {
 		time.Sleep(200 * time.Millisecond)
+		if ctx.Err() != nil {
+			return
+		}
 		info, err := idx.cli.GetMasterchainInfo(ctx)
 		if err != nil {
 			idx.logger.Error("failed to get masterchain info", zap.Error(err))
 			continue
 		}
-		chunk, err = idx.initChunk(info.Last.Seqno)
+		latest = info.Last.Seqno
+		first := min(idx.firstSeqno(latest), latest)
+		chunk, err = idx.initChunk(first)
 		if err != nil {
 			idx.logger.Error("failed to get init chunk", zap.Error(err))
 			continue
 		}
+		if first < latest {
+			idx.logger.Info("backfilling masterchain blocks",
+				zap.Uint32("from", first),
+				zap.Uint32("to", latest))
+		}
 		break
 	}
 
 	for {
-		time.Sleep(500 * time.Millisecond)
+		if ctx.Err() != nil {
+			return
+		}
+		if chunk.masterID.Seqno >= latest {
+			// we have caught up with the blockchain, so let's wait for a new block.
+			time.Sleep(500 * time.Millisecond)
+			if info, err := idx.cli.GetMasterchainInfo(ctx); err == nil {
+				latest = info.Last.Seqno
+			}
+		}
 		next, err := idx.next(chunk)
 		if err != nil {
 			if isBlockNotReadyError(err) {
 				continue
 			}
 			idx.logger.Error("failed to get next chunk", zap.Error(err))
+			time.Sleep(500 * time.Millisecond)
 			continue
 		}
 		for _, block := range next.blocks {
-			for _, ch := range channels {
-				ch <- block
-			}
+			broadcaster.Publish(block)
 		}
 		chunk = next
+		seqno := chunk.masterID.Seqno
+		lastPublishedSeqno.Set(float64(seqno))
+		for _, block := range chunk.blocks {
+			if block.ID.Workchain == -1 {
+				lagSeconds.Set(float64(time.Now().Unix() - int64(block.Block.Info.GenUtime)))
+			}
+		}
+		remaining := 0
+		if latest > seqno {
+			remaining = int(latest - seqno)
+			backfilledChunks.Inc()
+		}
+		backfillRemaining.Set(float64(remaining))
 	}
-
 }
 
 func (idx *Indexer) next(prevChunk *chunk) (*chunk, error) {
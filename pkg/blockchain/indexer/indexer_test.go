@@ -0,0 +1,119 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/liteclient"
+	"github.com/tonkeeper/tongo/tlb"
+	"go.uber.org/zap"
+)
+
+// mockClient is a masterchain without shards, its last block is latest.
+type mockClient struct {
+	latest atomic.Uint32
+}
+
+func (c *mockClient) GetMasterchainInfo(ctx context.Context) (liteclient.LiteServerMasterchainInfoC, error) {
+	info := liteclient.LiteServerMasterchainInfoC{}
+	info.Last.Seqno = c.latest.Load()
+	return info, nil
+}
+
+func (c *mockClient) LookupBlock(ctx context.Context, blockID tongo.BlockID, mode uint32, lt *uint64, utime *uint32) (tongo.BlockIDExt, tlb.BlockInfo, error) {
+	if blockID.Seqno > c.latest.Load() {
+		return tongo.BlockIDExt{}, tlb.BlockInfo{}, fmt.Errorf("ltdb: block not found")
+	}
+	return tongo.BlockIDExt{BlockID: blockID}, tlb.BlockInfo{}, nil
+}
+
+func (c *mockClient) GetBlock(ctx context.Context, blockID tongo.BlockIDExt) (tlb.Block, error) {
+	block := tlb.Block{}
+	block.Info.StartLt = uint64(blockID.Seqno)
+	block.Info.GenUtime = uint32(time.Now().Unix())
+	return block, nil
+}
+
+func TestIndexer_firstSeqno(t *testing.T) {
+	tests := []struct {
+		name       string
+		cursor     Cursor
+		startSeqno uint32
+		want       uint32
+	}{
+		{
+			name: "no cursor and start seqno",
+			want: 100,
+		},
+		{
+			name:       "start seqno",
+			startSeqno: 50,
+			want:       50,
+		},
+		{
+			name:       "empty cursor",
+			cursor:     CursorFunc(func() (uint32, bool) { return 0, false }),
+			startSeqno: 50,
+			want:       50,
+		},
+		{
+			name:       "cursor takes precedence",
+			cursor:     CursorFunc(func() (uint32, bool) { return 70, true }),
+			startSeqno: 50,
+			want:       71,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithStartSeqno(tt.startSeqno)}
+			if tt.cursor != nil {
+				opts = append(opts, WithCursor(tt.cursor))
+			}
+			idx := New(zap.NewNop(), nil, opts...)
+			require.Equal(t, tt.want, idx.firstSeqno(100))
+		})
+	}
+}
+
+func TestIndexer_Run_backfill(t *testing.T) {
+	cli := &mockClient{}
+	cli.latest.Store(8)
+	idx := &Indexer{
+		logger: zap.NewNop(),
+		cli:    cli,
+		cursor: CursorFunc(func() (uint32, bool) { return 5, true }),
+	}
+	b := NewBroadcaster(zap.NewNop())
+	sub, err := b.Subscribe("test", 0, OverflowBlock)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go idx.Run(ctx, b)
+
+	var seqnos []uint32
+	receive := func(n int) {
+		for len(seqnos) < n {
+			select {
+			case block := <-sub.C():
+				require.Equal(t, int32(-1), block.ID.Workchain)
+				seqnos = append(seqnos, block.ID.Seqno)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("got only %v blocks", seqnos)
+			}
+		}
+	}
+	// blocks produced after the consumer's last block are backfilled.
+	receive(3)
+	require.Equal(t, []uint32{6, 7, 8}, seqnos)
+
+	// then the indexer follows new blocks.
+	cli.latest.Store(9)
+	receive(4)
+	require.Equal(t, []uint32{6, 7, 8, 9}, seqnos)
+}
@@ -188,8 +188,12 @@ func (ms *MsgSender) sendMessageFromBatch(msgCopy ExtInMsgCopy) error {
 	if err := liteapi.VerifySendMessagePayload(msgCopy.Payload); err != nil {
 		return err
 	}
-	for _, ch := range ms.receivers {
-		ch <- msgCopy
+	for name, ch := range ms.receivers {
+		select {
+		case ch <- msgCopy:
+		default:
+			ms.logger.Warn("receiver is too slow", zap.String("name", name))
+		}
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancel()
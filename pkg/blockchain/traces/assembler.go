@@ -0,0 +1,316 @@
+// Package traces assembles traces from a stream of blocks.
+package traces
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/blockchain/indexer"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+var (
+	tracesInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "trace_assembler_traces_in_progress",
+		Help: "Number of traces waiting for some of their messages to be delivered",
+	})
+	tracesCompleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "trace_assembler_traces_completed_total",
+		Help: "Number of fully assembled traces",
+	})
+	tracesExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "trace_assembler_traces_expired_total",
+		Help: "Number of traces dropped because they weren't completed in time",
+	}, []string{"kind"})
+)
+
+const (
+	// DefaultTTL is how long a trace can wait for its messages to be delivered.
+	DefaultTTL = 10 * time.Minute
+	// DefaultOrphanTTL is how long a transaction can wait for the transaction that sent its inbound message.
+	// The parent transaction usually lives in a block of another shard delivered in the same masterchain chunk.
+	DefaultOrphanTTL = time.Minute
+	// sweepInterval defines how often we look for expired traces.
+	sweepInterval = 10 * time.Second
+)
+
+// msgKey identifies an internal message by its source and creation lt.
+type msgKey struct {
+	source tongo.AccountID
+	lt     uint64
+}
+
+// traceState is a trace being assembled.
+type traceState struct {
+	root *core.Trace
+	// pending contains internal messages sent but not yet delivered.
+	pending map[msgKey]struct{}
+	// parentMsg is set if the root's inbound message was sent by a transaction we haven't seen yet.
+	parentMsg *msgKey
+	// updatedAt is the unix time of the latest transaction added to the trace.
+	updatedAt int64
+	// txs contains hashes of all transactions of the trace.
+	txs []tongo.Bits256
+}
+
+func (s *traceState) completed() bool {
+	return len(s.pending) == 0 && s.parentMsg == nil
+}
+
+// pendingMsg is an internal message waiting for a transaction on the destination account.
+type pendingMsg struct {
+	node  *core.Trace
+	state *traceState
+}
+
+// Assembler links inbound messages of transactions to outbound messages of other transactions
+// and builds complete traces as blocks arrive.
+//
+// A trace is in progress while some of its internal messages haven't been delivered,
+// such messages stay in OutMsgs of the sending transaction, so core.Trace.InProgress and
+// core.Trace.CalculateProgress work as usual for a trace returned by Assembler.Trace.
+type Assembler struct {
+	logger    *zap.Logger
+	ttl       time.Duration
+	orphanTTL time.Duration
+
+	mu sync.Mutex
+	// states contains traces in progress.
+	states map[*traceState]struct{}
+	// pending maps a not yet delivered message to the transaction that sent it.
+	pending map[msgKey]pendingMsg
+	// orphans maps an inbound message to a trace whose root received that message,
+	// while the transaction that sent the message is unknown.
+	orphans map[msgKey]*traceState
+	// byTx maps a transaction hash to a trace in progress containing the transaction.
+	byTx map[tongo.Bits256]*traceState
+	// now is the unix time of the latest block seen by the assembler.
+	now       int64
+	lastSweep int64
+}
+
+type Options struct {
+	ttl       time.Duration
+	orphanTTL time.Duration
+}
+
+type Option func(o *Options)
+
+// WithTTL configures how long a trace can stay in progress before it is dropped.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.ttl = ttl
+	}
+}
+
+// WithOrphanTTL configures how long a transaction can wait for its parent.
+func WithOrphanTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.orphanTTL = ttl
+	}
+}
+
+func NewAssembler(logger *zap.Logger, opts ...Option) *Assembler {
+	o := &Options{
+		ttl:       DefaultTTL,
+		orphanTTL: DefaultOrphanTTL,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Assembler{
+		logger:    logger,
+		ttl:       o.ttl,
+		orphanTTL: o.orphanTTL,
+		states:    map[*traceState]struct{}{},
+		pending:   map[msgKey]pendingMsg{},
+		orphans:   map[msgKey]*traceState{},
+		byTx:      map[tongo.Bits256]*traceState{},
+	}
+}
+
+// Add processes transactions of the given block and returns traces completed by them.
+// Use AddTransactions if the transactions of the block have been converted already.
+func (a *Assembler) Add(block indexer.IDandBlock) []*core.Trace {
+	var txs []*core.Transaction
+	for _, tx := range block.Block.AllTransactions() {
+		transaction, err := core.ConvertTransaction(block.ID.Workchain, tongo.Transaction{Transaction: *tx, BlockID: block.ID}, nil)
+		if err != nil {
+			a.logger.Error("failed to convert transaction",
+				zap.String("block", block.ID.String()),
+				zap.Error(err))
+			continue
+		}
+		txs = append(txs, transaction)
+	}
+	return a.AddTransactions(txs)
+}
+
+// AddTransactions processes transactions of a block and returns traces completed by them.
+// The assembler keeps the transactions, so the caller must not modify them afterwards.
+func (a *Assembler) AddTransactions(txs []*core.Transaction) []*core.Trace {
+	// a transaction can receive a message sent by another transaction of the same block,
+	// processing them in the order of lt guarantees the sender goes first.
+	txs = slices.Clone(txs)
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Lt < txs[j].Lt
+	})
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var completed []*core.Trace
+	for _, tx := range txs {
+		if state := a.add(tx); state != nil && state.completed() {
+			a.remove(state)
+			tracesCompleted.Inc()
+			completed = append(completed, state.root)
+		}
+	}
+	if a.now-a.lastSweep >= int64(sweepInterval.Seconds()) {
+		a.sweep()
+		a.lastSweep = a.now
+	}
+	tracesInProgress.Set(float64(len(a.states)))
+	return completed
+}
+
+// add links the transaction to a trace and returns the trace.
+func (a *Assembler) add(tx *core.Transaction) *traceState {
+	a.now = max(a.now, tx.Utime)
+	node := &core.Trace{Transaction: *tx}
+
+	var state *traceState
+	if tx.InMsg == nil || tx.InMsg.IsExternal() || tx.InMsg.IsEmission() {
+		state = newTraceState(node)
+		a.states[state] = struct{}{}
+	} else {
+		key := msgKey{source: *tx.InMsg.Source, lt: tx.InMsg.CreatedLt}
+		if parent, ok := a.pending[key]; ok {
+			delete(a.pending, key)
+			state = parent.state
+			delete(state.pending, key)
+			deliver(parent.node, node)
+		} else {
+			// the sender is in a block we haven't seen yet or will never see.
+			state = newTraceState(node)
+			state.parentMsg = &key
+			a.states[state] = struct{}{}
+			a.orphans[key] = state
+		}
+	}
+	state.txs = append(state.txs, tx.Hash)
+	a.byTx[tx.Hash] = state
+	state.updatedAt = a.now
+
+	for _, m := range tx.OutMsgs {
+		if m.Destination == nil {
+			continue
+		}
+		key := msgKey{source: tx.Account, lt: m.CreatedLt}
+		if orphan, ok := a.orphans[key]; ok {
+			// the receiver has arrived before the sender, so we attach its whole subtree.
+			delete(a.orphans, key)
+			deliver(node, orphan.root)
+			a.merge(state, orphan)
+			continue
+		}
+		a.pending[key] = pendingMsg{node: node, state: state}
+		state.pending[key] = struct{}{}
+	}
+	return state
+}
+
+func newTraceState(root *core.Trace) *traceState {
+	return &traceState{root: root, pending: map[msgKey]struct{}{}}
+}
+
+// deliver attaches the child to the parent and removes the message received by the child from the parent's OutMsgs.
+func deliver(parent, child *core.Trace) {
+	outMsgs := make([]core.Message, 0, len(parent.OutMsgs))
+	for _, m := range parent.OutMsgs {
+		if m.Destination != nil && m.CreatedLt == child.InMsg.CreatedLt {
+			continue
+		}
+		outMsgs = append(outMsgs, m)
+	}
+	parent.OutMsgs = outMsgs
+	parent.Children = append(parent.Children, child)
+}
+
+// merge moves everything from the orphan trace into the given trace.
+func (a *Assembler) merge(state, orphan *traceState) {
+	delete(a.states, orphan)
+	state.txs = append(state.txs, orphan.txs...)
+	for _, hash := range orphan.txs {
+		a.byTx[hash] = state
+	}
+	for key := range orphan.pending {
+		msg := a.pending[key]
+		a.pending[key] = pendingMsg{node: msg.node, state: state}
+		state.pending[key] = struct{}{}
+	}
+}
+
+// remove forgets the trace.
+func (a *Assembler) remove(state *traceState) {
+	delete(a.states, state)
+	for _, hash := range state.txs {
+		delete(a.byTx, hash)
+	}
+	if state.parentMsg != nil {
+		delete(a.orphans, *state.parentMsg)
+	}
+	for key := range state.pending {
+		delete(a.pending, key)
+	}
+}
+
+// sweep drops traces that haven't been updated for too long.
+func (a *Assembler) sweep() {
+	for state := range a.states {
+		ttl, kind := a.ttl, "in_progress"
+		if state.parentMsg != nil {
+			ttl, kind = a.orphanTTL, "orphan"
+		}
+		if a.now-state.updatedAt < int64(ttl.Seconds()) {
+			continue
+		}
+		a.remove(state)
+		tracesExpired.WithLabelValues(kind).Inc()
+	}
+}
+
+// Trace returns a copy of a trace in progress containing the given transaction.
+// The trace is returned only if its root is known.
+func (a *Assembler) Trace(hash tongo.Bits256) (*core.Trace, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.byTx[hash]
+	if !ok || state.parentMsg != nil {
+		return nil, false
+	}
+	return Clone(state.root), true
+}
+
+// Clone copies the tree of the trace, so that its nodes can be modified without affecting the original.
+// Transactions are copied shallowly.
+func Clone(t *core.Trace) *core.Trace {
+	c := &core.Trace{
+		Transaction:       t.Transaction,
+		AccountInterfaces: t.AccountInterfaces,
+	}
+	if len(t.Children) > 0 {
+		c.Children = make([]*core.Trace, 0, len(t.Children))
+		for _, child := range t.Children {
+			c.Children = append(c.Children, Clone(child))
+		}
+	}
+	return c
+}
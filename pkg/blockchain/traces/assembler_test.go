@@ -0,0 +1,118 @@
+package traces
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+func account(i byte) tongo.AccountID {
+	return tongo.AccountID{Workchain: 0, Address: tongo.Bits256{i}}
+}
+
+type txBuilder struct {
+	utime int64
+}
+
+// tx creates a transaction on the given account at the given lt.
+// The transaction receives a message created at inLt by "from" or an external message if from is nil,
+// and sends one internal message to each of the "to" accounts.
+func (b *txBuilder) tx(a tongo.AccountID, lt uint64, from *tongo.AccountID, inLt uint64, to ...tongo.AccountID) *core.Transaction {
+	in := &core.Message{MessageID: core.MessageID{Source: from, Destination: &a, CreatedLt: inLt}}
+	tx := &core.Transaction{
+		TransactionID: core.TransactionID{Hash: tongo.Bits256{byte(lt)}, Lt: lt, Account: a},
+		Utime:         b.utime,
+		InMsg:         in,
+	}
+	for i := range to {
+		tx.OutMsgs = append(tx.OutMsgs, core.Message{MessageID: core.MessageID{Source: &a, Destination: &to[i], CreatedLt: lt + uint64(i) + 1}})
+	}
+	// an external out message must be kept in the assembled trace.
+	tx.OutMsgs = append(tx.OutMsgs, core.Message{MessageID: core.MessageID{Source: &a, CreatedLt: lt + uint64(len(to)) + 1}})
+	return tx
+}
+
+func TestAssembler(t *testing.T) {
+	wallet, jettonWallet, receiverWallet, receiver := account(1), account(2), account(3), account(4)
+	b := &txBuilder{utime: 1000}
+	a := NewAssembler(zap.L())
+
+	// wallet -> jetton wallet -> (receiver's jetton wallet -> receiver, wallet)
+	root := b.tx(wallet, 10, nil, 0, jettonWallet)
+	require.Empty(t, a.AddTransactions([]*core.Transaction{root}))
+
+	trace, ok := a.Trace(root.Hash)
+	require.True(t, ok)
+	require.True(t, trace.InProgress())
+
+	jetton := b.tx(jettonWallet, 20, &wallet, 11, receiverWallet, wallet)
+	require.Empty(t, a.AddTransactions([]*core.Transaction{jetton}))
+	// the receiver's transaction arrives before its parent, e.g. from a block of another shard.
+	notification := b.tx(receiver, 40, &receiverWallet, 31)
+	require.Empty(t, a.AddTransactions([]*core.Transaction{notification}))
+	_, ok = a.Trace(notification.Hash)
+	require.False(t, ok)
+
+	trace, ok = a.Trace(jetton.Hash)
+	require.True(t, ok)
+	require.Equal(t, root.Hash, trace.Hash)
+	require.InDelta(t, 2.0/4.0, trace.CalculateProgress(), 0.001)
+
+	excess := b.tx(wallet, 35, &jettonWallet, 22)
+	transfer := b.tx(receiverWallet, 30, &jettonWallet, 21, receiver)
+	completed := a.AddTransactions([]*core.Transaction{transfer, excess})
+	require.Len(t, completed, 1)
+
+	trace = completed[0]
+	require.False(t, trace.InProgress())
+	require.Equal(t, root.Hash, trace.Hash)
+	require.Len(t, trace.OutMsgs, 1)
+	require.Len(t, trace.Children, 1)
+	require.Len(t, trace.Children[0].Children, 2)
+	require.Len(t, core.DistinctAccounts(trace), 4)
+	var transferNode *core.Trace
+	for _, child := range trace.Children[0].Children {
+		if child.Account == receiverWallet {
+			transferNode = child
+		}
+	}
+	require.NotNil(t, transferNode)
+	require.Len(t, transferNode.Children, 1)
+	require.Equal(t, notification.Hash, transferNode.Children[0].Hash)
+
+	_, ok = a.Trace(root.Hash)
+	require.False(t, ok)
+	require.Empty(t, a.states)
+	require.Empty(t, a.pending)
+	require.Empty(t, a.orphans)
+	require.Empty(t, a.byTx)
+}
+
+func TestAssembler_expiration(t *testing.T) {
+	wallet, other := account(1), account(2)
+	b := &txBuilder{utime: 1000}
+	a := NewAssembler(zap.L(), WithTTL(time.Minute), WithOrphanTTL(30*time.Second))
+
+	a.AddTransactions([]*core.Transaction{
+		b.tx(wallet, 10, nil, 0, other),
+		b.tx(other, 50, &wallet, 41),
+	})
+	require.Len(t, a.states, 2)
+
+	b.utime += 40
+	a.AddTransactions([]*core.Transaction{b.tx(wallet, 100, nil, 0)})
+	// the orphan has expired, the trace in progress is still waiting.
+	require.Len(t, a.states, 1)
+	require.Empty(t, a.orphans)
+
+	b.utime += 60
+	a.AddTransactions([]*core.Transaction{b.tx(wallet, 200, nil, 0)})
+	require.Empty(t, a.states)
+	require.Empty(t, a.pending)
+	require.Empty(t, a.byTx)
+}
@@ -0,0 +1,21 @@
+package blockchain
+
+import (
+	"github.com/tonkeeper/tongo/boc"
+	tongoWallet "github.com/tonkeeper/tongo/wallet"
+)
+
+// ValidUntil returns the unix time after which a wallet rejects the external message.
+// It returns false if the message isn't recognized as a message to a wallet v3, v4 or v5.
+func ValidUntil(msg *boc.Cell) (uint32, bool) {
+	if v5, err := tongoWallet.DecodeMessageV5(msg); err == nil {
+		if v5.SumType == "SignedExternal" {
+			return v5.SignedExternal.ValidUntil, true
+		}
+	} else if v4, err := tongoWallet.DecodeMessageV4(msg); err == nil {
+		return v4.ValidUntil, true
+	} else if v3, err := tongoWallet.DecodeMessageV3(msg); err == nil {
+		return v3.ValidUntil, true
+	}
+	return 0, false
+}
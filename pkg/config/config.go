@@ -5,6 +5,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/tonkeeper/tongo"
@@ -24,6 +25,33 @@ type Config struct {
 		ArchiveLiteServers []config.LiteServer `env:"ARCHIVE_LITE_SERVERS"`
 		SendingLiteservers []config.LiteServer `env:"SENDING_LITE_SERVERS"`
 		IsTestnet          bool                `env:"IS_TESTNET" envDefault:"false"`
+		// TrackingRules is a list of rules to find accounts to watch for, see litestorage.ParseTrackingRule.
+		TrackingRules []string `env:"TRACKING_RULES" envSeparator:","`
+		// StorageBackend defines where the index of tracked accounts is kept: "memory" or "bbolt".
+		StorageBackend string `env:"STORAGE_BACKEND" envDefault:"memory"`
+		StoragePath    string `env:"STORAGE_PATH" envDefault:"opentonapi.db"`
+		// IndexerStartSeqno is a masterchain seqno to start indexing from when there is no saved progress.
+		IndexerStartSeqno uint32 `env:"INDEXER_START_SEQNO"`
+		// ReducedBlocksRetention is how long summaries of blocks are kept in the storage backend,
+		// only the latest blocks are kept in memory if it is 0.
+		ReducedBlocksRetention time.Duration `env:"REDUCED_BLOCKS_RETENTION" envDefault:"0"`
+		// EmulatedTracesMaxSize limits the total size in bytes of emulated traces kept in memory.
+		EmulatedTracesMaxSize int64 `env:"EMULATED_TRACES_MAX_SIZE" envDefault:"268435456"`
+		// EmulatedTracesPersistent enables keeping emulated traces in the storage backend as well.
+		EmulatedTracesPersistent bool `env:"EMULATED_TRACES_PERSISTENT" envDefault:"false"`
+		// SSEHeartbeatInterval is how often a heartbeat is written to an idle SSE connection.
+		SSEHeartbeatInterval time.Duration `env:"SSE_HEARTBEAT_INTERVAL" envDefault:"15s"`
+		// SSEMaxAccounts limits the number of accounts a single SSE connection can subscribe to.
+		SSEMaxAccounts int `env:"SSE_MAX_ACCOUNTS" envDefault:"100"`
+		// WebsocketMaxSubscriptions limits the number of subscriptions of a single websocket connection.
+		WebsocketMaxSubscriptions int `env:"WEBSOCKET_MAX_SUBSCRIPTIONS" envDefault:"1000"`
+		// WebhookMaxAttempts is a number of attempts to deliver an event to a webhook before it goes to the dead-letter log.
+		WebhookMaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"10"`
+		// AdminPort is a port to serve admin endpoints on, admin endpoints are disabled if it is 0.
+		AdminPort int `env:"ADMIN_PORT" envDefault:"0"`
+		// AdminHost is an address admin endpoints listen on, only local clients can reach them by default.
+		AdminHost  string `env:"ADMIN_HOST" envDefault:"127.0.0.1"`
+		AdminToken string `env:"ADMIN_TOKEN"`
 	}
 	TonConnect struct {
 		Secret string `env:"TON_CONNECT_SECRET"`
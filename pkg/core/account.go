@@ -3,6 +3,7 @@ package core
 import (
 	"math/big"
 
+	"github.com/shopspring/decimal"
 	"github.com/tonkeeper/tongo/abi"
 	"github.com/tonkeeper/tongo/ton"
 
@@ -62,4 +63,15 @@ type AccountStat struct {
 	JettonsCount  int32
 	MultisigCount int32
 	StakingCount  int32
+	// TxCount is a number of transactions of the account known to the storage.
+	TxCount int64
+	// FirstActivity and LastActivity are unix times of the first and the last known transactions.
+	FirstActivity int64
+	LastActivity  int64
+}
+
+// BalancePoint is a balance of an account in TON or jettons at the given time.
+type BalancePoint struct {
+	Utime   int64
+	Balance decimal.Decimal
 }
@@ -16,4 +16,6 @@ type DomainBid struct {
 	TxTime  int64
 	Value   uint64
 	TxHash  tongo.Bits256
+	// Auction is a contract running the auction, for DNS auctions it is the domain's NFT item.
+	Auction tongo.AccountID
 }
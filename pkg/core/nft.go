@@ -37,6 +37,14 @@ type NftCollection struct {
 	LastOffchainMetaRefreshSuccess time.Time
 }
 
+// NftTransfer is an attempt to transfer an NFT item, it can fail or bounce.
+type NftTransfer struct {
+	Nft       tongo.AccountID
+	Sender    *tongo.AccountID
+	Recipient *tongo.AccountID
+	Success   bool
+}
+
 type NftSaleInfo struct {
 	Contract       tongo.AccountID
 	Marketplace    tongo.AccountID
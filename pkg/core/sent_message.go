@@ -0,0 +1,39 @@
+package core
+
+import (
+	"github.com/tonkeeper/tongo"
+)
+
+// SentMessageStatus describes a stage of the lifecycle of an external message sent to the blockchain.
+type SentMessageStatus string
+
+const (
+	// SentMessagePending means the message has been accepted by a lite server but hasn't landed in a block yet.
+	SentMessagePending SentMessageStatus = "pending"
+	// SentMessageIncluded means a transaction processing the message has been found in a block.
+	SentMessageIncluded SentMessageStatus = "included"
+	// SentMessageFailed means a transaction processing the message has been found, but its compute phase failed.
+	SentMessageFailed SentMessageStatus = "failed"
+	// SentMessageExpired means the message's valid_until has passed and no transaction has processed it.
+	SentMessageExpired SentMessageStatus = "expired"
+)
+
+// SentMessage is an external message sent through opentonapi.
+type SentMessage struct {
+	// Hash is a normalized hash of the message.
+	Hash        tongo.Bits256
+	Destination tongo.AccountID
+	// ValidUntil is taken from the message if the destination is a known wallet, otherwise it is an estimate.
+	ValidUntil int64
+	SentAt     int64
+	Status     SentMessageStatus
+	// TraceID is set once the message is included, it is the ID of the transaction that processed the message.
+	TraceID *TraceID
+	// ExitCode is a compute phase exit code of the transaction that processed the message.
+	ExitCode *int32
+}
+
+// Final returns true if the status of the message isn't going to change.
+func (m SentMessage) Final() bool {
+	return m.Status != SentMessagePending
+}
@@ -36,3 +36,14 @@ type SubscriptionV1 struct {
 	SubscriptionID       int64
 	IndexerLastUpdateLt  int64
 }
+
+// SubscriptionEvent is a subscription or its cancellation seen in a trace.
+type SubscriptionEvent struct {
+	Subscription tongo.AccountID
+	Subscriber   tongo.AccountID
+	Admin        tongo.AccountID
+	WithdrawTo   tongo.AccountID
+	Cancelled    bool
+	// Lt is a logical time of the last transaction of the corresponding action.
+	Lt uint64
+}
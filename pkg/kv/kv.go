@@ -0,0 +1,79 @@
+// Package kv provides a tiny ordered key-value storage abstraction.
+//
+// opentonapi uses it to keep local indexes (transactions, block headers, cursors and so on)
+// either in memory or in an embedded on-disk database, so that a node can survive restarts
+// without re-downloading everything from lite servers.
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by Store.Get when a key doesn't exist.
+var ErrNotFound = errors.New("kv: key not found")
+
+const (
+	// BackendMemory keeps all data in RAM, nothing survives a restart.
+	BackendMemory = "memory"
+	// BackendBolt keeps data in a single bbolt file on disk.
+	BackendBolt = "bbolt"
+)
+
+// Store is an ordered key-value storage with named buckets.
+// All implementations are safe for concurrent use.
+type Store interface {
+	Get(bucket string, key []byte) ([]byte, error)
+	Put(bucket string, key, value []byte) error
+	Delete(bucket string, key []byte) error
+	// Range iterates over keys of the bucket within [from, to) in lexicographical order,
+	// or in the reverse order if reverse is true.
+	// A nil bound means the range is unbounded from that side.
+	// The iteration stops as soon as fn returns false.
+	// Key and value passed to fn are valid only during the call, fn must not modify the store.
+	Range(bucket string, from, to []byte, reverse bool, fn func(key, value []byte) bool) error
+	Close() error
+}
+
+// Open creates a store for the given backend.
+// path is ignored by the memory backend.
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendBolt:
+		return OpenBolt(path)
+	default:
+		return nil, fmt.Errorf("unknown kv backend: %v", backend)
+	}
+}
+
+// PrefixEnd returns the smallest key that is greater than all keys with the given prefix.
+// It can be used as an upper bound of Range to iterate over a prefix.
+func PrefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	// the prefix consists of 0xff bytes only, so there is no upper bound.
+	return nil
+}
+
+// Prefix iterates over all keys starting with prefix.
+func Prefix(s Store, bucket string, prefix []byte, reverse bool, fn func(key, value []byte) bool) error {
+	return s.Range(bucket, prefix, PrefixEnd(prefix), reverse, fn)
+}
+
+func inRange(key, from, to []byte) bool {
+	if from != nil && bytes.Compare(key, from) < 0 {
+		return false
+	}
+	if to != nil && bytes.Compare(key, to) >= 0 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package kv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	bolt, err := OpenBolt(filepath.Join(t.TempDir(), "test.db"))
+	require.Nil(t, err)
+	defer bolt.Close()
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"bbolt":  bolt,
+	}
+	collect := func(t *testing.T, s Store, from, to []byte, reverse bool) []string {
+		var keys []string
+		err := s.Range("bucket", from, to, reverse, func(key, value []byte) bool {
+			require.Equal(t, "v"+string(key), string(value))
+			keys = append(keys, string(key))
+			return true
+		})
+		require.Nil(t, err)
+		return keys
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.Get("bucket", []byte("a"))
+			require.ErrorIs(t, err, ErrNotFound)
+			require.Nil(t, s.Range("bucket", nil, nil, false, func(key, value []byte) bool {
+				t.Fatal("empty bucket")
+				return true
+			}))
+
+			for _, k := range []string{"c", "a", "ba", "b", "d"} {
+				require.Nil(t, s.Put("bucket", []byte(k), []byte("v"+k)))
+			}
+			value, err := s.Get("bucket", []byte("ba"))
+			require.Nil(t, err)
+			require.Equal(t, "vba", string(value))
+
+			require.Equal(t, []string{"a", "b", "ba", "c", "d"}, collect(t, s, nil, nil, false))
+			require.Equal(t, []string{"d", "c", "ba", "b", "a"}, collect(t, s, nil, nil, true))
+			require.Equal(t, []string{"b", "ba"}, collect(t, s, []byte("b"), []byte("c"), false))
+			require.Equal(t, []string{"ba", "b"}, collect(t, s, []byte("b"), []byte("c"), true))
+			require.Equal(t, []string{"c", "ba"}, collect(t, s, []byte("b0"), []byte("c0"), true))
+			require.Equal(t, []string{"d"}, collect(t, s, []byte("c0"), nil, true))
+
+			var keys []string
+			require.Nil(t, Prefix(s, "bucket", []byte("b"), false, func(key, value []byte) bool {
+				keys = append(keys, string(key))
+				return true
+			}))
+			require.Equal(t, []string{"b", "ba"}, keys)
+
+			require.Nil(t, s.Delete("bucket", []byte("b")))
+			require.Nil(t, s.Delete("bucket", []byte("unknown")))
+			require.Equal(t, []string{"a", "ba", "c", "d"}, collect(t, s, nil, nil, false))
+		})
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	require.Equal(t, []byte{1, 3}, PrefixEnd([]byte{1, 2}))
+	require.Equal(t, []byte{2}, PrefixEnd([]byte{1, 0xff}))
+	require.Nil(t, PrefixEnd([]byte{0xff, 0xff}))
+}
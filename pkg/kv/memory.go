@@ -0,0 +1,117 @@
+package kv
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+	"sync"
+)
+
+type memoryBucket struct {
+	// keys are kept sorted to support ordered iteration.
+	keys   []string
+	values map[string][]byte
+}
+
+// MemoryStore is a Store that keeps everything in RAM.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]*memoryBucket
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}}
+}
+
+func (m *MemoryStore) Get(bucket string, key []byte) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.buckets[bucket]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	value, ok := b.values[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(value), nil
+}
+
+func (m *MemoryStore) Put(bucket string, key, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[bucket]
+	if !ok {
+		b = &memoryBucket{values: map[string][]byte{}}
+		m.buckets[bucket] = b
+	}
+	k := string(key)
+	if _, ok := b.values[k]; !ok {
+		i := sort.SearchStrings(b.keys, k)
+		b.keys = slices.Insert(b.keys, i, k)
+	}
+	b.values[k] = bytes.Clone(value)
+	return nil
+}
+
+func (m *MemoryStore) Delete(bucket string, key []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	k := string(key)
+	if _, ok := b.values[k]; !ok {
+		return nil
+	}
+	delete(b.values, k)
+	i := sort.SearchStrings(b.keys, k)
+	b.keys = slices.Delete(b.keys, i, i+1)
+	return nil
+}
+
+func (m *MemoryStore) Range(bucket string, from, to []byte, reverse bool, fn func(key, value []byte) bool) error {
+	m.mu.RLock()
+	b, ok := m.buckets[bucket]
+	if !ok {
+		m.mu.RUnlock()
+		return nil
+	}
+	// copy the matching part to release the lock before calling fn.
+	start := 0
+	if from != nil {
+		start = sort.SearchStrings(b.keys, string(from))
+	}
+	end := len(b.keys)
+	if to != nil {
+		end = sort.SearchStrings(b.keys, string(to))
+	}
+	var keys []string
+	var values [][]byte
+	if start < end {
+		keys = slices.Clone(b.keys[start:end])
+		values = make([][]byte, len(keys))
+		for i, k := range keys {
+			values[i] = b.values[k]
+		}
+	}
+	m.mu.RUnlock()
+
+	for i := range keys {
+		idx := i
+		if reverse {
+			idx = len(keys) - 1 - i
+		}
+		if !fn([]byte(keys[idx]), values[idx]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
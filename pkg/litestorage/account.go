@@ -3,6 +3,8 @@ package litestorage
 import (
 	"context"
 	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tonkeeper/tongo/abi"
 	"github.com/tonkeeper/tongo/ton"
 	"time"
@@ -12,14 +14,6 @@ import (
 	"github.com/tonkeeper/tongo/tlb"
 )
 
-func (s *LiteStorage) GetSubscriptionsV2(ctx context.Context, address tongo.AccountID) ([]core.SubscriptionV2, error) {
-	return []core.SubscriptionV2{}, nil
-}
-
-func (s *LiteStorage) GetSubscriptionsV1(ctx context.Context, address tongo.AccountID) ([]core.SubscriptionV1, error) {
-	return []core.SubscriptionV1{}, nil
-}
-
 func (s *LiteStorage) GetSeqno(ctx context.Context, account tongo.AccountID) (uint32, error) {
 	return s.client.GetSeqno(ctx, account)
 }
@@ -42,10 +36,6 @@ func (s *LiteStorage) AccountStatusAndInterfaces(addr tongo.AccountID) (tlb.Acco
 	return account.Status, account.Interfaces, err
 }
 
-func (s *LiteStorage) GetAccountDiff(ctx context.Context, account tongo.AccountID, startTime int64, endTime int64) (int64, error) {
-	return 0, errors.New("not implemented")
-}
-
 func (s *LiteStorage) GetLatencyAndLastMasterchainSeqno(ctx context.Context) (int64, uint32, error) {
 	blockHeader, err := s.LastMasterchainBlockHeader(ctx)
 	if err != nil {
@@ -55,12 +45,22 @@ func (s *LiteStorage) GetLatencyAndLastMasterchainSeqno(ctx context.Context) (in
 	return latency, blockHeader.Seqno, nil
 }
 
+// GetAccountsStats returns statistics of the given accounts computed from the local index,
+// so they are complete for tracked accounts only.
 func (s *LiteStorage) GetAccountsStats(ctx context.Context, accounts []ton.AccountID) ([]core.AccountStat, error) {
-	return nil, nil
-}
-
-func (s *LiteStorage) GetAccountPlugins(ctx context.Context, accountID ton.AccountID, walletVersion abi.ContractInterface) ([]core.Plugin, error) {
-	return nil, nil
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_accounts_stats").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	stats := make([]core.AccountStat, 0, len(accounts))
+	for _, a := range accounts {
+		stat, err := s.index.accountStat(a)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
 }
 
 func (s *LiteStorage) GetWalletSignatureAllowed(ctx context.Context, accountID ton.AccountID) (bool, error) {
@@ -0,0 +1,35 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestLiteStorage_GetAccountsStats(t *testing.T) {
+	wallet := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	unknown := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	for _, tx := range []core.Transaction{
+		{TransactionID: core.TransactionID{Hash: tongo.Bits256{1}, Lt: 1, Account: wallet}, Utime: 100, EndBalance: 500},
+		{TransactionID: core.TransactionID{Hash: tongo.Bits256{2}, Lt: 2, Account: wallet}, Utime: 200, EndBalance: 300},
+		{TransactionID: core.TransactionID{Hash: tongo.Bits256{3}, Lt: 3, Account: wallet}, Utime: 300, EndBalance: 350},
+	} {
+		require.Nil(t, storage.index.storeTransaction(&tx))
+	}
+	// storing a transaction again doesn't change the counters, an older transaction from a preload does.
+	require.Nil(t, storage.index.storeTransaction(&core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{3}, Lt: 3, Account: wallet}, Utime: 300, EndBalance: 350}))
+	require.Nil(t, storage.index.storeTransaction(&core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{4}, Lt: 0, Account: wallet}, Utime: 50, EndBalance: 1000}))
+	want := []core.AccountStat{
+		{AccountID: wallet, GramBalance: 350, TxCount: 4, FirstActivity: 50, LastActivity: 300},
+		{AccountID: unknown},
+	}
+	stats, err := storage.GetAccountsStats(context.Background(), []tongo.AccountID{wallet, unknown})
+	require.Nil(t, err)
+	require.Equal(t, want, stats)
+}
@@ -2,14 +2,235 @@ package litestorage
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/ton"
 
 	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+	"github.com/tonkeeper/opentonapi/pkg/references"
 )
 
+// indexAuctionBids stores the given bids in the history of their domains and refreshes the state of their auctions.
+// A bid is ignored if its contract doesn't run an auction,
+// because .ton DNS items accept empty messages outside auctions as well.
+func (s *LiteStorage) indexAuctionBids(ctx context.Context, bids []core.DomainBid) error {
+	for _, bid := range bids {
+		stored, err := s.index.auction(bid.Auction)
+		if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+			return err
+		}
+		auction, err := s.auctionState(ctx, bid.Auction, stored)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if stored != nil && stored.Date >= bid.TxTime {
+			// the stored auction was still running when the bid was placed,
+			// its end time may have been extended by the bid.
+			auction.Bids = stored.Bids
+		}
+		added, err := s.index.addDomainBid(auction.Domain, bid)
+		if err != nil {
+			return err
+		}
+		if added && bid.Success {
+			auction.Bids++
+		}
+		if err := s.index.putAuction(bid.Auction, *auction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auctionState reads the current state of the auction with get-methods.
+// The domain name is taken from the previously stored state if it's available.
+func (s *LiteStorage) auctionState(ctx context.Context, a tongo.AccountID, stored *core.Auction) (*core.Auction, error) {
+	var auction core.Auction
+	_, value, err := abi.GetTelemintAuctionState(ctx, s.executor, a)
+	if err != nil && !isGetMethodFailure(err) {
+		return nil, err
+	}
+	if state, ok := value.(abi.GetTelemintAuctionStateResult); err == nil && ok {
+		auction.Price = state.Bid
+		auction.Date = state.EndTime
+		if bidder, err := ton.AccountIDFromTlb(state.Bidder); err == nil && bidder != nil {
+			auction.Owner = *bidder
+		}
+	} else {
+		_, value, err = abi.GetAuctionInfo(ctx, s.executor, a)
+		if err != nil && isGetMethodFailure(err) {
+			return nil, core.ErrEntityNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		info, ok := value.(abi.GetAuctionInfoResult)
+		if !ok || info.AuctionEndTime == 0 {
+			return nil, core.ErrEntityNotFound
+		}
+		auction.Price = int64(info.MaxBidAmount)
+		auction.Date = int64(info.AuctionEndTime)
+		if bidder, err := ton.AccountIDFromTlb(info.MaxBidAddress); err == nil && bidder != nil {
+			auction.Owner = *bidder
+		}
+	}
+	if stored != nil && stored.Domain != "" {
+		auction.Domain = stored.Domain
+		return &auction, nil
+	}
+	auction.Domain, err = s.auctionDomain(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	return &auction, nil
+}
+
+// auctionDomain returns the name sold by the auction: "name.ton" for .ton DNS items,
+// "username.t.me" for Telegram usernames and the number itself for Telegram numbers.
+func (s *LiteStorage) auctionDomain(ctx context.Context, a tongo.AccountID) (string, error) {
+	_, value, err := abi.GetTelemintTokenName(ctx, s.executor, a)
+	if err != nil && !isGetMethodFailure(err) {
+		return "", err
+	}
+	if result, ok := value.(abi.GetTelemintTokenNameResult); err == nil && ok {
+		name := string(result.Username)
+		_, value, err := abi.GetNftData(ctx, s.executor, a)
+		if err != nil {
+			return "", err
+		}
+		if data, ok := value.(abi.GetNftDataResult); ok {
+			collection, err := ton.AccountIDFromTlb(data.CollectionAddress)
+			if err == nil && collection != nil && *collection == references.RootTelegram {
+				name += ".t.me"
+			}
+		}
+		return name, nil
+	}
+	_, value, err = abi.GetDomain(ctx, s.executor, a)
+	if err != nil && isGetMethodFailure(err) {
+		return "", core.ErrEntityNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	result, ok := value.(abi.GetDomainResult)
+	if !ok {
+		return "", core.ErrEntityNotFound
+	}
+	return result.Domain + references.DomainSuffixes[references.RootDotTon], nil
+}
+
 func (s *LiteStorage) GetAllAuctions(ctx context.Context) ([]core.Auction, error) {
-	return nil, nil
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_all_auctions").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	return s.index.liveAuctions(time.Now().Unix())
 }
 
 func (s *LiteStorage) GetDomainBids(ctx context.Context, domain string) ([]core.DomainBid, error) {
-	return nil, nil
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_domain_bids").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	return s.index.domainBids(domain)
+}
+
+// domainBidsPrefix returns a prefix of bids on the domain.
+// .ton domains are stored without the suffix, the way the API passes them.
+func domainBidsPrefix(domain string) []byte {
+	return append([]byte(strings.TrimSuffix(domain, ".ton")), 0)
+}
+
+func domainBidKey(domain string, bid core.DomainBid) []byte {
+	key := binary.BigEndian.AppendUint64(domainBidsPrefix(domain), uint64(bid.TxTime))
+	return append(key, bid.TxHash[:]...)
+}
+
+// addDomainBid stores the bid and reports whether it hasn't been stored before.
+func (i *index) addDomainBid(domain string, bid core.DomainBid) (bool, error) {
+	key := domainBidKey(domain, bid)
+	if _, err := i.store.Get(domainBidsBucket, key); err == nil {
+		return false, nil
+	} else if !errors.Is(err, kv.ErrNotFound) {
+		return false, err
+	}
+	value, err := json.Marshal(bid)
+	if err != nil {
+		return false, err
+	}
+	return true, i.store.Put(domainBidsBucket, key, value)
+}
+
+// domainBids returns bids on the domain, the latest first.
+func (i *index) domainBids(domain string) ([]core.DomainBid, error) {
+	prefix := domainBidsPrefix(domain)
+	var bids []core.DomainBid
+	var decodeErr error
+	err := i.store.Range(domainBidsBucket, prefix, kv.PrefixEnd(prefix), true, func(key, value []byte) bool {
+		var bid core.DomainBid
+		if decodeErr = json.Unmarshal(value, &bid); decodeErr != nil {
+			return false
+		}
+		bids = append(bids, bid)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bids, decodeErr
+}
+
+func (i *index) putAuction(a tongo.AccountID, auction core.Auction) error {
+	value, err := json.Marshal(auction)
+	if err != nil {
+		return err
+	}
+	return i.store.Put(auctionsBucket, accountKey(a), value)
+}
+
+func (i *index) auction(a tongo.AccountID) (*core.Auction, error) {
+	value, err := i.store.Get(auctionsBucket, accountKey(a))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, core.ErrEntityNotFound
+		}
+		return nil, err
+	}
+	var auction core.Auction
+	if err := json.Unmarshal(value, &auction); err != nil {
+		return nil, err
+	}
+	return &auction, nil
+}
+
+// liveAuctions returns auctions which end after the given time.
+func (i *index) liveAuctions(now int64) ([]core.Auction, error) {
+	var auctions []core.Auction
+	var decodeErr error
+	err := i.store.Range(auctionsBucket, nil, nil, false, func(key, value []byte) bool {
+		var auction core.Auction
+		if decodeErr = json.Unmarshal(value, &auction); decodeErr != nil {
+			return false
+		}
+		if auction.Date > now {
+			auctions = append(auctions, auction)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return auctions, decodeErr
 }
@@ -0,0 +1,51 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestLiteStorage_auctions(t *testing.T) {
+	account := func(i byte) tongo.AccountID { return tongo.AccountID{Workchain: 0, Address: tongo.Bits256{i}} }
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	bid := func(i byte, utime int64) core.DomainBid {
+		return core.DomainBid{Bidder: account(i), Success: true, TxTime: utime, Value: uint64(i), TxHash: tongo.Bits256{i}, Auction: account(100)}
+	}
+	for _, b := range []struct {
+		domain string
+		bid    core.DomainBid
+		added  bool
+	}{
+		{domain: "foo.ton", bid: bid(1, 100), added: true},
+		{domain: "foo.ton", bid: bid(2, 200), added: true},
+		{domain: "foo.ton", bid: bid(2, 200), added: false},
+		{domain: "foobar.ton", bid: bid(3, 300), added: true},
+		{domain: "foo.t.me", bid: bid(4, 400), added: true},
+	} {
+		added, err := storage.index.addDomainBid(b.domain, b.bid)
+		require.Nil(t, err)
+		require.Equal(t, b.added, added)
+	}
+	ctx := context.Background()
+	bids, err := storage.GetDomainBids(ctx, "foo")
+	require.Nil(t, err)
+	require.Equal(t, []core.DomainBid{bid(2, 200), bid(1, 100)}, bids)
+	bids, err = storage.GetDomainBids(ctx, "foo.t.me")
+	require.Nil(t, err)
+	require.Equal(t, []core.DomainBid{bid(4, 400)}, bids)
+
+	now := time.Now().Unix()
+	live := core.Auction{Bids: 2, Date: now + 3600, Domain: "foo.ton", Owner: account(2), Price: 2}
+	require.Nil(t, storage.index.putAuction(account(100), live))
+	require.Nil(t, storage.index.putAuction(account(101), core.Auction{Bids: 1, Date: now - 3600, Domain: "bar.ton", Owner: account(5), Price: 5}))
+	auctions, err := storage.GetAllAuctions(ctx)
+	require.Nil(t, err)
+	require.Equal(t, []core.Auction{live}, auctions)
+}
@@ -0,0 +1,193 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/liteapi"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func balanceSnapshotKey(a tongo.AccountID, utime int64, lt uint64) []byte {
+	key := binary.BigEndian.AppendUint64(accountKey(a), uint64(utime))
+	return binary.BigEndian.AppendUint64(key, lt)
+}
+
+// balanceSnapshotValue contains the TON balances of the account after and before the transaction.
+func balanceSnapshotValue(tx *core.Transaction) []byte {
+	value := binary.BigEndian.AppendUint64(nil, uint64(tx.EndBalance))
+	return binary.BigEndian.AppendUint64(value, uint64(tx.EndBalance-balanceChange(tx)))
+}
+
+// balanceChange returns how much the transaction has changed the TON balance of its account:
+// the credited value of the inbound message minus fees and the value of outbound internal messages with their forwarding fees.
+func balanceChange(tx *core.Transaction) int64 {
+	change := -tx.TotalFee
+	if tx.CreditPhase != nil {
+		change += int64(tx.CreditPhase.CreditGrams)
+	}
+	for _, m := range tx.OutMsgs {
+		if m.MsgType == core.IntMsg {
+			change -= m.Value + m.FwdFee + m.IhrFee
+		}
+	}
+	return change
+}
+
+// balanceAt returns the TON balance of the account after its last transaction at or before the given time.
+// If the account has no indexed transactions before that time, it returns the balance before its first indexed transaction.
+func (i *index) balanceAt(a tongo.AccountID, utime int64) (int64, error) {
+	prefix := accountKey(a)
+	var balance *int64
+	if err := i.store.Range(balanceSnapshotsBucket, prefix, balanceSnapshotKey(a, utime+1, 0), true, func(key, value []byte) bool {
+		b := int64(binary.BigEndian.Uint64(value))
+		balance = &b
+		return false
+	}); err != nil {
+		return 0, err
+	}
+	if balance != nil {
+		return *balance, nil
+	}
+	err := i.store.Range(balanceSnapshotsBucket, prefix, kv.PrefixEnd(prefix), false, func(key, value []byte) bool {
+		b := int64(binary.BigEndian.Uint64(value[8:]))
+		balance = &b
+		return false
+	})
+	if err != nil {
+		return 0, err
+	}
+	if balance == nil {
+		return 0, core.ErrEntityNotFound
+	}
+	return *balance, nil
+}
+
+// GetAccountDiff returns the change of the TON balance of a tracked account between the given times.
+func (s *LiteStorage) GetAccountDiff(ctx context.Context, account tongo.AccountID, startTime int64, endTime int64) (int64, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_account_diff").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	start, err := s.index.balanceAt(account, startTime)
+	if err != nil {
+		return 0, err
+	}
+	end, err := s.index.balanceAt(account, endTime)
+	if err != nil {
+		return 0, err
+	}
+	return end - start, nil
+}
+
+// GetAccountBalanceHistory returns balances of a tracked account at startTime, startTime+step, ... up to endTime.
+// TON balances are taken from transactions of the account,
+// jetton balances are restored from the current balance and the history of jetton operations.
+func (s *LiteStorage) GetAccountBalanceHistory(ctx context.Context, account tongo.AccountID, jetton *tongo.AccountID, startTime, endTime, step int64) ([]core.BalancePoint, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_account_balance_history").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if step <= 0 || endTime < startTime {
+		return nil, nil
+	}
+	var points []core.BalancePoint
+	for t := startTime; t <= endTime; t += step {
+		points = append(points, core.BalancePoint{Utime: t})
+	}
+	if jetton != nil {
+		return s.jettonBalanceHistory(ctx, account, *jetton, points)
+	}
+	for n := range points {
+		balance, err := s.index.balanceAt(account, points[n].Utime)
+		if err != nil {
+			return nil, err
+		}
+		points[n].Balance = decimal.NewFromInt(balance)
+	}
+	return points, nil
+}
+
+// jettonBalanceHistory fills the points with jetton balances of the owner.
+func (s *LiteStorage) jettonBalanceHistory(ctx context.Context, owner, master tongo.AccountID, points []core.BalancePoint) ([]core.BalancePoint, error) {
+	balance, err := s.jettonBalance(ctx, owner, master)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.index.jettonBalances(owner, master, balance, points); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// jettonBalances fills the points, sorted by time, with jetton balances of the owner.
+// It walks from the current balance back in time and reverts indexed jetton operations on the way.
+func (i *index) jettonBalances(owner, master tongo.AccountID, balance decimal.Decimal, points []core.BalancePoint) error {
+	n := len(points) - 1
+	err := i.jettonOperations(jettonMasterOperationsBucket, jettonMasterPrefix(owner, master), nil, func(op core.JettonOperation) bool {
+		for ; n >= 0 && points[n].Utime >= op.Utime; n-- {
+			points[n].Balance = balance
+		}
+		if n < 0 {
+			return false
+		}
+		if op.Destination != nil && *op.Destination == owner {
+			balance = balance.Sub(op.Amount)
+		}
+		if op.Source != nil && *op.Source == owner {
+			balance = balance.Add(op.Amount)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for ; n >= 0; n-- {
+		points[n].Balance = balance
+	}
+	return nil
+}
+
+// jettonBalance returns the current balance of the owner's jetton wallet.
+func (s *LiteStorage) jettonBalance(ctx context.Context, owner, master tongo.AccountID) (decimal.Decimal, error) {
+	_, value, err := abi.GetWalletAddress(ctx, s.executor, master, owner.ToMsgAddress())
+	if err != nil && isGetMethodFailure(err) {
+		return decimal.Decimal{}, core.ErrEntityNotFound
+	}
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	result, ok := value.(abi.GetWalletAddressResult)
+	if !ok {
+		return decimal.Decimal{}, core.ErrEntityNotFound
+	}
+	wallet, err := tongo.AccountIDFromTlb(result.JettonWalletAddress)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	if wallet == nil {
+		return decimal.Zero, nil
+	}
+	_, value, err = abi.GetWalletData(ctx, s.executor, *wallet)
+	if err != nil && (errors.Is(err, liteapi.ErrAccountNotFound) || isGetMethodFailure(err)) {
+		// the wallet hasn't been deployed yet.
+		return decimal.Zero, nil
+	}
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	data, ok := value.(abi.GetWalletDataResult)
+	if !ok {
+		return decimal.Zero, nil
+	}
+	amount := big.Int(data.Balance)
+	return decimal.NewFromBigInt(&amount, 0), nil
+}
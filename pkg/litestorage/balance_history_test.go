@@ -0,0 +1,69 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestLiteStorage_balanceHistory(t *testing.T) {
+	owner := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	other := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	usdt := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{10}}
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	for _, tx := range []core.Transaction{
+		// the account receives 600 and pays 100 in fees.
+		{TransactionID: core.TransactionID{Hash: tongo.Bits256{1}, Lt: 1, Account: owner}, Utime: 100, EndBalance: 500, CreditPhase: &core.TxCreditPhase{CreditGrams: 600}, TotalFee: 100},
+		{TransactionID: core.TransactionID{Hash: tongo.Bits256{2}, Lt: 2, Account: owner}, Utime: 200, EndBalance: 300},
+		{TransactionID: core.TransactionID{Hash: tongo.Bits256{3}, Lt: 3, Account: owner}, Utime: 200, EndBalance: 350},
+		{TransactionID: core.TransactionID{Hash: tongo.Bits256{4}, Lt: 4, Account: other}, Utime: 250, EndBalance: 1000},
+	} {
+		require.Nil(t, storage.index.storeTransaction(&tx))
+	}
+	ctx := context.Background()
+	diff, err := storage.GetAccountDiff(ctx, owner, 150, 300)
+	require.Nil(t, err)
+	require.Equal(t, int64(-150), diff)
+	// the balance before the first transaction is restored from its balance change.
+	diff, err = storage.GetAccountDiff(ctx, owner, 0, 300)
+	require.Nil(t, err)
+	require.Equal(t, int64(350), diff)
+	_, err = storage.GetAccountDiff(ctx, usdt, 0, 300)
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+
+	balances := func(points []core.BalancePoint) []int64 {
+		var res []int64
+		for _, p := range points {
+			res = append(res, p.Balance.IntPart())
+		}
+		return res
+	}
+	points, err := storage.GetAccountBalanceHistory(ctx, owner, nil, 50, 250, 50)
+	require.Nil(t, err)
+	require.Equal(t, []int64{0, 500, 500, 350, 350}, balances(points))
+	require.Equal(t, int64(250), points[4].Utime)
+
+	change := balanceChange(&core.Transaction{
+		CreditPhase: &core.TxCreditPhase{CreditGrams: 1000},
+		TotalFee:    10,
+		OutMsgs: []core.Message{
+			{MsgType: core.IntMsg, Value: 300, FwdFee: 5},
+			{MsgType: core.ExtOutMsg},
+		},
+	})
+	require.Equal(t, int64(685), change)
+
+	require.Nil(t, storage.index.storeJettonOperations([]core.JettonOperation{
+		{Operation: core.MintJettonOperation, Destination: &owner, JettonMaster: usdt, Amount: decimal.NewFromInt(100), Lt: 10, Utime: 100},
+		{Operation: core.TransferJettonOperation, Source: &owner, Destination: &other, JettonMaster: usdt, Amount: decimal.NewFromInt(30), Lt: 20, Utime: 200},
+	}))
+	points = []core.BalancePoint{{Utime: 50}, {Utime: 100}, {Utime: 150}, {Utime: 250}}
+	require.Nil(t, storage.index.jettonBalances(owner, usdt, decimal.NewFromInt(70), points))
+	require.Equal(t, []int64{0, 100, 100, 70}, balances(points))
+}
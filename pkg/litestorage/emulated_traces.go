@@ -0,0 +1,285 @@
+package litestorage
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/boc"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+const (
+	// defaultEmulatedTracesMaxSize limits the total size of json-encoded emulated traces kept in memory.
+	defaultEmulatedTracesMaxSize = 256 << 20
+	// emulationErrorTTL is how long we remember that emulation of a message failed.
+	emulationErrorTTL = time.Hour
+)
+
+// emulatedTrace is a trace of a message emulated before the message landed in the blockchain.
+type emulatedTrace struct {
+	// Trace is a json-encoded core.Trace, it is empty if the emulation failed.
+	Trace      json.RawMessage `json:",omitempty"`
+	Version    int
+	GetMethods []abi.MethodInvocation
+	// Error is set if the emulation failed.
+	Error     string `json:",omitempty"`
+	Message   []byte `json:",omitempty"`
+	ExpiresAt time.Time
+}
+
+func (t emulatedTrace) size() int64 {
+	return int64(len(t.Trace) + len(t.Message) + len(t.Error))
+}
+
+// emulatedTraces keeps emulated traces in memory until they expire.
+// When the total size of traces exceeds maxSize, the oldest ones are evicted.
+type emulatedTraces struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	// order contains message hashes, the oldest saved trace first.
+	order   *list.List
+	entries map[string]*list.Element
+	traces  map[string]emulatedTrace
+}
+
+func newEmulatedTraces(maxSize int64) *emulatedTraces {
+	return &emulatedTraces{
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		traces:  make(map[string]emulatedTrace),
+	}
+}
+
+func (e *emulatedTraces) put(msgHash string, trace emulatedTrace, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.remove(msgHash)
+	if trace.size() > e.maxSize {
+		return
+	}
+	for el := e.order.Front(); el != nil; {
+		next := el.Next()
+		if hash := el.Value.(string); !e.traces[hash].ExpiresAt.After(now) {
+			e.remove(hash)
+		}
+		el = next
+	}
+	for e.size+trace.size() > e.maxSize && e.order.Len() > 0 {
+		e.remove(e.order.Front().Value.(string))
+	}
+	e.entries[msgHash] = e.order.PushBack(msgHash)
+	e.traces[msgHash] = trace
+	e.size += trace.size()
+}
+
+func (e *emulatedTraces) get(msgHash string, now time.Time) (emulatedTrace, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	trace, ok := e.traces[msgHash]
+	if !ok {
+		return emulatedTrace{}, false
+	}
+	if !trace.ExpiresAt.After(now) {
+		e.remove(msgHash)
+		return emulatedTrace{}, false
+	}
+	return trace, true
+}
+
+func (e *emulatedTraces) remove(msgHash string) {
+	el, ok := e.entries[msgHash]
+	if !ok {
+		return
+	}
+	e.size -= e.traces[msgHash].size()
+	e.order.Remove(el)
+	delete(e.entries, msgHash)
+	delete(e.traces, msgHash)
+}
+
+// storedMethodInvocation is abi.MethodInvocation as it is kept in the kv store.
+// A result of a get-method is decoded from the kv store as json.RawMessage,
+// because its go type is lost in json.
+type storedMethodInvocation struct {
+	Result   json.RawMessage
+	TypeHint string
+}
+
+type storedEmulatedTrace struct {
+	emulatedTrace
+	GetMethods []storedMethodInvocation
+}
+
+func emulatedTraceExpirationKey(expiresAt time.Time, msgHash string) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, uint64(expiresAt.Unix())), msgHash...)
+}
+
+func (i *index) putEmulatedTrace(msgHash string, trace emulatedTrace) error {
+	stored := storedEmulatedTrace{emulatedTrace: trace}
+	for _, m := range trace.GetMethods {
+		result, err := json.Marshal(m.Result)
+		if err != nil {
+			return err
+		}
+		stored.GetMethods = append(stored.GetMethods, storedMethodInvocation{Result: result, TypeHint: m.TypeHint})
+	}
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if err := i.store.Put(emulatedTracesBucket, []byte(msgHash), value); err != nil {
+		return err
+	}
+	return i.store.Put(emulatedTracesExpirationBucket, emulatedTraceExpirationKey(trace.ExpiresAt, msgHash), []byte(msgHash))
+}
+
+func (i *index) emulatedTrace(msgHash string) (emulatedTrace, error) {
+	value, err := i.store.Get(emulatedTracesBucket, []byte(msgHash))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return emulatedTrace{}, core.ErrEntityNotFound
+		}
+		return emulatedTrace{}, err
+	}
+	var stored storedEmulatedTrace
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return emulatedTrace{}, err
+	}
+	trace := stored.emulatedTrace
+	trace.GetMethods = nil
+	for _, m := range stored.GetMethods {
+		trace.GetMethods = append(trace.GetMethods, abi.MethodInvocation{Result: m.Result, TypeHint: m.TypeHint})
+	}
+	return trace, nil
+}
+
+// pruneEmulatedTraces removes traces expired before the given time.
+func (i *index) pruneEmulatedTraces(before time.Time) error {
+	var keys, hashes [][]byte
+	err := i.store.Range(emulatedTracesExpirationBucket, nil, binary.BigEndian.AppendUint64(nil, uint64(before.Unix())), false, func(key, value []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		hashes = append(hashes, append([]byte{}, value...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for n := range keys {
+		if err := i.store.Delete(emulatedTracesExpirationBucket, keys[n]); err != nil {
+			return err
+		}
+		stored, err := i.emulatedTrace(string(hashes[n]))
+		if errors.Is(err, core.ErrEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// the trace could have been saved again with a later expiration time.
+		if stored.ExpiresAt.Before(before) {
+			if err := i.store.Delete(emulatedTracesBucket, hashes[n]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *LiteStorage) saveEmulatedTrace(msgHash string, trace emulatedTrace) error {
+	now := time.Now()
+	s.emulatedTraces.put(msgHash, trace, now)
+	if !s.persistEmulatedTraces {
+		return nil
+	}
+	if err := s.index.putEmulatedTrace(msgHash, trace); err != nil {
+		return err
+	}
+	return s.index.pruneEmulatedTraces(now)
+}
+
+// SaveTraceWithState keeps an emulated trace of a message for the given period,
+// so the trace can be served by the message hash until the real one lands in the blockchain.
+func (s *LiteStorage) SaveTraceWithState(ctx context.Context, msgHash string, trace *core.Trace, version int, getMethods []abi.MethodInvocation, ttl time.Duration) error {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("save_trace_with_state").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	return s.saveEmulatedTrace(msgHash, emulatedTrace{
+		Trace:      value,
+		Version:    version,
+		GetMethods: getMethods,
+		ExpiresAt:  time.Now().Add(ttl),
+	})
+}
+
+// GetTraceWithState returns an emulated trace of a message saved with SaveTraceWithState.
+// It returns a nil trace without an error if there is no such trace or it has expired.
+func (s *LiteStorage) GetTraceWithState(ctx context.Context, msgHash string) (*core.Trace, int, []abi.MethodInvocation, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_trace_with_state").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	now := time.Now()
+	stored, ok := s.emulatedTraces.get(msgHash, now)
+	if !ok && s.persistEmulatedTraces {
+		var err error
+		stored, err = s.index.emulatedTrace(msgHash)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			return nil, 0, nil, nil
+		}
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if !stored.ExpiresAt.After(now) {
+			return nil, 0, nil, nil
+		}
+		s.emulatedTraces.put(msgHash, stored, now)
+		ok = true
+	}
+	if !ok || len(stored.Trace) == 0 {
+		return nil, 0, nil, nil
+	}
+	var trace core.Trace
+	if err := json.Unmarshal(stored.Trace, &trace); err != nil {
+		return nil, 0, nil, err
+	}
+	return &trace, stored.Version, stored.GetMethods, nil
+}
+
+// SaveEmulationError remembers that emulation of the message failed.
+func (s *LiteStorage) SaveEmulationError(ctx context.Context, msg *boc.Cell, msgHash string, err error) error {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("save_emulation_error").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	trace := emulatedTrace{ExpiresAt: time.Now().Add(emulationErrorTTL)}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	if msg != nil {
+		value, err := msg.ToBoc()
+		if err != nil {
+			return err
+		}
+		trace.Message = value
+	}
+	return s.saveEmulatedTrace(msgHash, trace)
+}
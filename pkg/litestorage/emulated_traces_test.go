@@ -0,0 +1,81 @@
+package litestorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func Test_emulatedTraces(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	traces := newEmulatedTraces(10)
+	traces.put("a", emulatedTrace{Trace: []byte("1234"), ExpiresAt: now.Add(time.Minute)}, now)
+	traces.put("b", emulatedTrace{Trace: []byte("1234"), ExpiresAt: now.Add(time.Hour)}, now)
+	// "a" is the oldest one and is evicted to fit "c".
+	traces.put("c", emulatedTrace{Trace: []byte("1234"), ExpiresAt: now.Add(time.Hour)}, now)
+	_, ok := traces.get("a", now)
+	require.False(t, ok)
+	_, ok = traces.get("b", now)
+	require.True(t, ok)
+	require.Equal(t, int64(8), traces.size)
+
+	// a trace larger than the limit is not kept.
+	traces.put("d", emulatedTrace{Trace: []byte("12345678901"), ExpiresAt: now.Add(time.Hour)}, now)
+	_, ok = traces.get("d", now)
+	require.False(t, ok)
+
+	_, ok = traces.get("b", now.Add(2*time.Hour))
+	require.False(t, ok)
+	require.Equal(t, int64(4), traces.size)
+}
+
+func TestLiteStorage_TraceWithState(t *testing.T) {
+	store := kv.NewMemoryStore()
+	storage := &LiteStorage{
+		index:                 newIndex(store),
+		emulatedTraces:        newEmulatedTraces(defaultEmulatedTracesMaxSize),
+		persistEmulatedTraces: true,
+	}
+	ctx := context.Background()
+	trace := &core.Trace{Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{1}, Lt: 10}}}
+	methods := []abi.MethodInvocation{{TypeHint: "SeqnoResult", Result: abi.SeqnoResult{State: 5}}}
+	require.Nil(t, storage.SaveTraceWithState(ctx, "msg", trace, 3, methods, time.Hour))
+	require.Nil(t, storage.SaveTraceWithState(ctx, "expired", trace, 3, methods, 0))
+	require.Nil(t, storage.SaveEmulationError(ctx, nil, "failed", errors.New("out of gas")))
+
+	got, version, getMethods, err := storage.GetTraceWithState(ctx, "msg")
+	require.Nil(t, err)
+	require.Equal(t, trace.Hash, got.Hash)
+	require.Equal(t, 3, version)
+	require.Equal(t, methods, getMethods)
+
+	for _, hash := range []string{"expired", "failed", "unknown"} {
+		got, _, _, err = storage.GetTraceWithState(ctx, hash)
+		require.Nil(t, err)
+		require.Nil(t, got)
+	}
+
+	// after a restart, the trace is read from the kv store.
+	restarted := &LiteStorage{
+		index:                 newIndex(store),
+		emulatedTraces:        newEmulatedTraces(defaultEmulatedTracesMaxSize),
+		persistEmulatedTraces: true,
+	}
+	got, version, getMethods, err = restarted.GetTraceWithState(ctx, "msg")
+	require.Nil(t, err)
+	require.Equal(t, trace.Hash, got.Hash)
+	require.Equal(t, 3, version)
+	require.Equal(t, "SeqnoResult", getMethods[0].TypeHint)
+
+	require.Nil(t, storage.index.pruneEmulatedTraces(time.Now().Add(2*time.Hour)))
+	_, err = storage.index.emulatedTrace("msg")
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+}
@@ -0,0 +1,489 @@
+package litestorage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/cache"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+// Buckets of the kv store used by LiteStorage.
+const (
+	// transactionsBucket maps a transaction hash to a json-encoded core.Transaction.
+	transactionsBucket = "transactions"
+	// accountTransactionsBucket maps account+lt to a transaction hash, so we can walk over an account's history.
+	accountTransactionsBucket = "account_transactions"
+	// inMsgLTBucket maps account+created_lt of an inbound message to a transaction hash.
+	inMsgLTBucket = "in_msg_lt"
+	// accountStatsBucket maps an account to a json-encoded storedAccountStat.
+	accountStatsBucket = "account_stats"
+	// blockHeadersBucket maps a block ID to a json-encoded core.BlockHeader.
+	blockHeadersBucket = "block_headers"
+	// metaBucket contains cursors and other bookkeeping information.
+	metaBucket = "meta"
+	// trackedAccountsBucket contains accounts added to tracking at runtime.
+	trackedAccountsBucket = "tracked_accounts"
+	// tracesBucket maps a hash of a root transaction to a json-encoded core.Trace assembled from the block stream.
+	tracesBucket = "traces"
+	// traceRootsBucket maps a transaction hash to the root transaction of its trace.
+	traceRootsBucket = "trace_roots"
+	// accountTracesBucket maps account+root_lt+root_hash to utime+account of the root transaction
+	// for every trace the account took part in.
+	accountTracesBucket = "account_traces"
+	// traceIndexingQueueBucket contains hashes of transactions whose traces are waiting to be indexed.
+	traceIndexingQueueBucket = "trace_indexing_queue"
+	// jettonOperationsBucket maps account+lt+tx_hash+index to a json-encoded core.JettonOperation.
+	jettonOperationsBucket = "jetton_operations"
+	// jettonMasterOperationsBucket maps account+jetton_master+lt+tx_hash+index to a json-encoded core.JettonOperation.
+	jettonMasterOperationsBucket = "jetton_master_operations"
+	// jettonMastersBucket maps a jetton master to a json-encoded core.JettonMaster registered by the jetton registry.
+	jettonMastersBucket = "jetton_masters"
+	// jettonHoldersBucket maps jetton_master+wallet to a json-encoded core.JettonHolder.
+	jettonHoldersBucket = "jetton_holders"
+	// jettonHoldersByBalanceBucket contains jetton_master+balance+wallet keys to walk over holders ordered by balance.
+	jettonHoldersByBalanceBucket = "jetton_holders_by_balance"
+	// jettonHoldersCountBucket maps a jetton master to the number of its holders.
+	jettonHoldersCountBucket = "jetton_holders_count"
+	// nftItemsBucket maps an NFT item to its json-encoded state kept by the NFT index.
+	nftItemsBucket = "nft_items"
+	// nftOwnerItemsBucket maps owner+item to the kind of ownership, either direct or via a sale contract.
+	nftOwnerItemsBucket = "nft_owner_items"
+	// nftCollectionItemsBucket contains collection+item keys.
+	nftCollectionItemsBucket = "nft_collection_items"
+	// nftCollectionsBucket maps a collection to a json-encoded core.NftCollection.
+	nftCollectionsBucket = "nft_collections"
+	// multisigMembersBucket contains signer+multisig keys for multisigs found in traces of tracked accounts.
+	multisigMembersBucket = "multisig_members"
+	// multisigOrdersBucket contains multisig+order keys for orders found in traces of tracked accounts.
+	multisigOrdersBucket = "multisig_orders"
+	// auctionsBucket maps an auction contract to a json-encoded core.Auction with its latest state.
+	auctionsBucket = "auctions"
+	// domainBidsBucket maps domain+0x00+utime+tx_hash to a json-encoded core.DomainBid.
+	domainBidsBucket = "domain_bids"
+	// invoicePaymentsBucket maps source+in_msg_lt+invoice_id to a json-encoded core.InvoicePayment.
+	invoicePaymentsBucket = "invoice_payments"
+	// invoicesBucket maps source+destination+invoice_id+currency to a json-encoded core.InvoicePayment.
+	invoicesBucket = "invoices"
+	// subscriptionsBucket maps subscriber+subscription to a json-encoded storedSubscription.
+	subscriptionsBucket = "subscriptions"
+	// liquidPoolsBucket maps a discovered liquid staking pool to its jetton minter.
+	liquidPoolsBucket = "liquid_pools"
+	// liquidPayoutsBucket maps member+pool+payout_nft to an amount of pool jettons waiting for withdrawal.
+	liquidPayoutsBucket = "liquid_payouts"
+	// liquidPayoutItemsBucket maps a payout NFT to member+pool, so a burnt NFT can be removed from liquidPayoutsBucket.
+	liquidPayoutItemsBucket = "liquid_payout_items"
+	// liquidMembersBucket contains member+pool keys for accounts seen holding pool jettons or payout NFTs.
+	liquidMembersBucket = "liquid_members"
+	// emulatedTracesBucket maps a message hash to a json-encoded storedEmulatedTrace.
+	emulatedTracesBucket = "emulated_traces"
+	// emulatedTracesExpirationBucket maps expiration_time+message_hash to the message hash.
+	emulatedTracesExpirationBucket = "emulated_traces_expiration"
+	// balanceSnapshotsBucket maps account+utime+lt to the account's TON balances after and before the transaction.
+	balanceSnapshotsBucket = "balance_snapshots"
+	// reducedBlocksBucket maps utime+block_id to a json-encoded core.ReducedBlock.
+	reducedBlocksBucket = "reduced_blocks"
+	// trackingRulesBucket contains tracking rules in the format of TrackingRule.String().
+	trackingRulesBucket = "tracking_rules"
+	// matchedRulesBucket contains account+rule keys for accounts that started being tracked by a tracking rule.
+	matchedRulesBucket = "matched_rules"
+	// sentMessagesBucket maps a normalized hash of an external message sent through opentonapi to a json-encoded core.SentMessage.
+	sentMessagesBucket = "sent_messages"
+	// sentMessagesDeadlinesBucket contains deadline+message_hash keys, a deadline is valid_until of a pending message
+	// or the time the status of a finished message is removed.
+	sentMessagesDeadlinesBucket = "sent_messages_deadlines"
+)
+
+var (
+	lastMasterchainSeqnoKey = []byte("last_masterchain_seqno")
+	preloadedAccountPrefix  = []byte("preloaded:")
+)
+
+// index is a persistent index of transactions and blocks of tracked accounts.
+// It is backed by a kv.Store and keeps recently used transactions in an LRU cache
+// to avoid decoding them over and over again.
+type index struct {
+	store        kv.Store
+	transactions cache.Cache[tongo.Bits256, *core.Transaction]
+	// mu serializes storeTransaction, so that account stats count every transaction once.
+	mu sync.Mutex
+}
+
+func newIndex(store kv.Store) *index {
+	return &index{
+		store:        store,
+		transactions: cache.NewLRUCache[tongo.Bits256, *core.Transaction](100_000, "lite_storage_transactions"),
+	}
+}
+
+func accountKey(a tongo.AccountID) []byte {
+	key := make([]byte, 36)
+	binary.BigEndian.PutUint32(key, uint32(a.Workchain))
+	copy(key[4:], a.Address[:])
+	return key
+}
+
+func accountLtKey(a tongo.AccountID, lt uint64) []byte {
+	return binary.BigEndian.AppendUint64(accountKey(a), lt)
+}
+
+func blockIDKey(id tongo.BlockID) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint32(key, uint32(id.Workchain))
+	binary.BigEndian.PutUint64(key[4:], id.Shard)
+	binary.BigEndian.PutUint32(key[12:], id.Seqno)
+	return key
+}
+
+func (i *index) storeTransaction(tx *core.Transaction) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	known := i.hasTransaction(tx.Hash)
+	value, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	if err := i.store.Put(transactionsBucket, tx.Hash[:], value); err != nil {
+		return err
+	}
+	if err := i.store.Put(accountTransactionsBucket, accountLtKey(tx.Account, tx.Lt), tx.Hash[:]); err != nil {
+		return err
+	}
+	if err := i.store.Put(balanceSnapshotsBucket, balanceSnapshotKey(tx.Account, tx.Utime, tx.Lt), balanceSnapshotValue(tx)); err != nil {
+		return err
+	}
+	if tx.InMsg != nil && !tx.InMsg.IsExternal() {
+		if err := i.store.Put(inMsgLTBucket, accountLtKey(tx.Account, tx.InMsg.CreatedLt), tx.Hash[:]); err != nil {
+			return err
+		}
+	}
+	i.transactions.Set(tx.Hash, tx)
+	if known {
+		return nil
+	}
+	return i.updateAccountStat(tx)
+}
+
+func (i *index) getTransaction(hash tongo.Bits256) (*core.Transaction, error) {
+	if tx, ok := i.transactions.Get(hash); ok {
+		return tx, nil
+	}
+	value, err := i.store.Get(transactionsBucket, hash[:])
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, core.ErrEntityNotFound
+		}
+		return nil, err
+	}
+	var tx core.Transaction
+	if err := json.Unmarshal(value, &tx); err != nil {
+		return nil, err
+	}
+	i.transactions.Set(hash, &tx)
+	return &tx, nil
+}
+
+func (i *index) hasTransaction(hash tongo.Bits256) bool {
+	if _, ok := i.transactions.Get(hash); ok {
+		return true
+	}
+	_, err := i.store.Get(transactionsBucket, hash[:])
+	return err == nil
+}
+
+// transactionByInMsgLT returns a transaction of the given account
+// whose inbound message was created at the given lt.
+func (i *index) transactionByInMsgLT(a tongo.AccountID, lt uint64) (*core.Transaction, error) {
+	value, err := i.store.Get(inMsgLTBucket, accountLtKey(a, lt))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, core.ErrEntityNotFound
+		}
+		return nil, err
+	}
+	hash, err := bits256FromBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	return i.getTransaction(hash)
+}
+
+// accountTransactionRef points to a transaction in an account's history.
+type accountTransactionRef struct {
+	Lt   uint64
+	Hash tongo.Bits256
+}
+
+// accountTransactions returns up to limit transactions of the account with lt in [fromLt, toLt).
+// toLt equal to 0 means there is no upper bound.
+func (i *index) accountTransactions(a tongo.AccountID, fromLt, toLt uint64, reverse bool, limit int) ([]accountTransactionRef, error) {
+	to := kv.PrefixEnd(accountKey(a))
+	if toLt > 0 {
+		to = accountLtKey(a, toLt)
+	}
+	var refs []accountTransactionRef
+	var parseErr error
+	err := i.store.Range(accountTransactionsBucket, accountLtKey(a, fromLt), to, reverse, func(key, value []byte) bool {
+		hash, err := bits256FromBytes(value)
+		if err != nil {
+			parseErr = err
+			return false
+		}
+		refs = append(refs, accountTransactionRef{Lt: binary.BigEndian.Uint64(key[36:]), Hash: hash})
+		return len(refs) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, parseErr
+}
+
+// traceRoot returns the root of a trace the given transaction belongs to.
+func (i *index) traceRoot(hash tongo.Bits256) (core.TraceID, bool) {
+	value, err := i.store.Get(traceRootsBucket, hash[:])
+	if err != nil || len(value) != 48 {
+		return core.TraceID{}, false
+	}
+	root := core.TraceID{
+		Lt:    binary.BigEndian.Uint64(value[32:]),
+		UTime: int64(binary.BigEndian.Uint64(value[40:])),
+	}
+	copy(root.Hash[:], value[:32])
+	return root, true
+}
+
+// setTraceRoot records the root of a trace the given transaction belongs to,
+// and adds the trace to the traces of the transaction's account.
+func (i *index) setTraceRoot(tx core.TransactionID, root core.TraceID, rootAccount tongo.AccountID) error {
+	value := make([]byte, 0, 48)
+	value = append(value, root.Hash[:]...)
+	value = binary.BigEndian.AppendUint64(value, root.Lt)
+	value = binary.BigEndian.AppendUint64(value, uint64(root.UTime))
+	if err := i.store.Put(traceRootsBucket, tx.Hash[:], value); err != nil {
+		return err
+	}
+	key := append(accountLtKey(tx.Account, root.Lt), root.Hash[:]...)
+	value = binary.BigEndian.AppendUint64(nil, uint64(root.UTime))
+	value = append(value, accountKey(rootAccount)...)
+	return i.store.Put(accountTracesBucket, key, value)
+}
+
+// accountTrace is a trace an account took part in.
+type accountTrace struct {
+	ID core.TraceID
+	// Initiator is the account of the root transaction.
+	Initiator tongo.AccountID
+}
+
+// walkAccountTraces calls fn for traces of the account started at lt in [fromLt, toLt) ordered by lt,
+// until fn returns false. toLt equal to 0 means there is no upper bound.
+func (i *index) walkAccountTraces(a tongo.AccountID, fromLt, toLt uint64, reverse bool, fn func(accountTrace) bool) error {
+	to := kv.PrefixEnd(accountKey(a))
+	if toLt > 0 {
+		to = accountLtKey(a, toLt)
+	}
+	var parseErr error
+	err := i.store.Range(accountTracesBucket, accountLtKey(a, fromLt), to, reverse, func(key, value []byte) bool {
+		if len(key) != 76 || len(value) != 44 {
+			parseErr = fmt.Errorf("invalid account trace %x", key)
+			return false
+		}
+		t := accountTrace{
+			ID: core.TraceID{
+				Lt:    binary.BigEndian.Uint64(key[36:]),
+				UTime: int64(binary.BigEndian.Uint64(value)),
+			},
+			Initiator: tongo.AccountID{Workchain: int32(binary.BigEndian.Uint32(value[8:]))},
+		}
+		copy(t.ID.Hash[:], key[44:])
+		copy(t.Initiator.Address[:], value[12:])
+		return fn(t)
+	})
+	if err != nil {
+		return err
+	}
+	return parseErr
+}
+
+// storeTrace keeps the trace along with all its transactions.
+func (i *index) storeTrace(trace *core.Trace) error {
+	value, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+	if err := i.store.Put(tracesBucket, trace.Hash[:], value); err != nil {
+		return err
+	}
+	root := core.TraceID{Hash: trace.Hash, Lt: trace.Lt, UTime: trace.Utime}
+	var errs []error
+	core.Visit(trace, func(node *core.Trace) {
+		if !i.hasTransaction(node.Hash) {
+			errs = append(errs, i.storeTransaction(&node.Transaction))
+		}
+		errs = append(errs, i.setTraceRoot(node.TransactionID, root, trace.Account))
+	})
+	return errors.Join(errs...)
+}
+
+func (i *index) getTrace(hash tongo.Bits256) (*core.Trace, error) {
+	value, err := i.store.Get(tracesBucket, hash[:])
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, core.ErrEntityNotFound
+		}
+		return nil, err
+	}
+	var trace core.Trace
+	if err := json.Unmarshal(value, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+func (i *index) storeBlockHeader(header *core.BlockHeader) error {
+	value, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	return i.store.Put(blockHeadersBucket, blockIDKey(header.BlockID), value)
+}
+
+func (i *index) getBlockHeader(id tongo.BlockID) (*core.BlockHeader, error) {
+	value, err := i.store.Get(blockHeadersBucket, blockIDKey(id))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return nil, core.ErrEntityNotFound
+		}
+		return nil, err
+	}
+	var header core.BlockHeader
+	if err := json.Unmarshal(value, &header); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// lastMasterchainSeqno returns the seqno of the last masterchain block processed by LiteStorage.
+func (i *index) lastMasterchainSeqno() (uint32, bool) {
+	value, err := i.store.Get(metaBucket, lastMasterchainSeqnoKey)
+	if err != nil || len(value) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(value), true
+}
+
+func (i *index) setLastMasterchainSeqno(seqno uint32) error {
+	return i.store.Put(metaBucket, lastMasterchainSeqnoKey, binary.BigEndian.AppendUint32(nil, seqno))
+}
+
+// isPreloaded returns true if the account's history has been fully preloaded at least once.
+func (i *index) isPreloaded(a tongo.AccountID) bool {
+	_, err := i.store.Get(metaBucket, preloadedKey(a))
+	return err == nil
+}
+
+func (i *index) setPreloaded(a tongo.AccountID) error {
+	return i.store.Put(metaBucket, preloadedKey(a), []byte{1})
+}
+
+// Values of trackedAccountsBucket.
+const (
+	// accountUntracked is a tombstone of an account untracked at runtime,
+	// so an account from the ACCOUNTS env variable stays untracked after a restart.
+	accountUntracked byte = 0
+	accountTracked   byte = 1
+)
+
+func (i *index) addTrackedAccount(a tongo.AccountID) error {
+	return i.store.Put(trackedAccountsBucket, accountKey(a), []byte{accountTracked})
+}
+
+func (i *index) removeTrackedAccount(a tongo.AccountID) error {
+	return i.store.Put(trackedAccountsBucket, accountKey(a), []byte{accountUntracked})
+}
+
+// trackedAccounts returns accounts tracked at runtime and accounts untracked at runtime.
+func (i *index) trackedAccounts() (tracked []tongo.AccountID, untracked []tongo.AccountID, err error) {
+	err = i.store.Range(trackedAccountsBucket, nil, nil, false, func(key, value []byte) bool {
+		if len(key) != 36 {
+			return true
+		}
+		if len(value) == 1 && value[0] == accountUntracked {
+			untracked = append(untracked, accountFromKey(key))
+		} else {
+			tracked = append(tracked, accountFromKey(key))
+		}
+		return true
+	})
+	return tracked, untracked, err
+}
+
+func (i *index) addTrackingRule(rule string) error {
+	return i.store.Put(trackingRulesBucket, []byte(rule), []byte{1})
+}
+
+func (i *index) removeTrackingRule(rule string) error {
+	return i.store.Delete(trackingRulesBucket, []byte(rule))
+}
+
+func (i *index) trackingRules() ([]string, error) {
+	var rules []string
+	err := i.store.Range(trackingRulesBucket, nil, nil, false, func(key, value []byte) bool {
+		rules = append(rules, string(key))
+		return true
+	})
+	return rules, err
+}
+
+func (i *index) addMatchedRule(a tongo.AccountID, rule string) error {
+	return i.store.Put(matchedRulesBucket, append(accountKey(a), rule...), []byte{1})
+}
+
+func (i *index) matchedRules(a tongo.AccountID) ([]string, error) {
+	var rules []string
+	prefix := accountKey(a)
+	err := kv.Prefix(i.store, matchedRulesBucket, prefix, false, func(key, value []byte) bool {
+		rules = append(rules, string(key[len(prefix):]))
+		return true
+	})
+	return rules, err
+}
+
+// accountsByPrefix returns accounts stored as the last 36 bytes of keys with the given prefix.
+func (i *index) accountsByPrefix(bucket string, prefix []byte) ([]tongo.AccountID, error) {
+	var accounts []tongo.AccountID
+	err := i.store.Range(bucket, prefix, kv.PrefixEnd(prefix), false, func(key, value []byte) bool {
+		if len(key) == len(prefix)+36 {
+			accounts = append(accounts, accountFromKey(key[len(prefix):]))
+		}
+		return true
+	})
+	return accounts, err
+}
+
+func accountFromKey(key []byte) tongo.AccountID {
+	a := tongo.AccountID{Workchain: int32(binary.BigEndian.Uint32(key))}
+	copy(a.Address[:], key[4:36])
+	return a
+}
+
+func preloadedKey(a tongo.AccountID) []byte {
+	key := make([]byte, 0, len(preloadedAccountPrefix)+36)
+	key = append(key, preloadedAccountPrefix...)
+	return append(key, accountKey(a)...)
+}
+
+func bits256FromBytes(b []byte) (tongo.Bits256, error) {
+	var hash tongo.Bits256
+	if len(b) != len(hash) {
+		return tongo.Bits256{}, fmt.Errorf("invalid hash length: %v", len(b))
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
@@ -0,0 +1,513 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/puzpuzpuz/xsync/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/cache"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestIndex_transactions(t *testing.T) {
+	data, err := os.ReadFile("../core/testdata/convert-tx-1.json")
+	require.Nil(t, err)
+	var tx core.Transaction
+	require.Nil(t, json.Unmarshal(data, &tx))
+
+	path := filepath.Join(t.TempDir(), "index.db")
+	store, err := kv.OpenBolt(path)
+	require.Nil(t, err)
+	idx := newIndex(store)
+	require.Nil(t, idx.storeTransaction(&tx))
+	require.Nil(t, idx.setLastMasterchainSeqno(100))
+	require.Nil(t, idx.setPreloaded(tx.Account))
+	require.Nil(t, store.Close())
+
+	// reopen the store to make sure everything survives a restart.
+	store, err = kv.OpenBolt(path)
+	require.Nil(t, err)
+	defer store.Close()
+	idx = newIndex(store)
+
+	require.True(t, idx.hasTransaction(tx.Hash))
+	got, err := idx.getTransaction(tx.Hash)
+	require.Nil(t, err)
+	require.Equal(t, &tx, got)
+
+	got, err = idx.transactionByInMsgLT(tx.Account, tx.InMsg.CreatedLt)
+	require.Nil(t, err)
+	require.Equal(t, tx.Hash, got.Hash)
+
+	_, err = idx.getTransaction(tongo.Bits256{})
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+
+	seqno, ok := idx.lastMasterchainSeqno()
+	require.True(t, ok)
+	require.Equal(t, uint32(100), seqno)
+	require.True(t, idx.isPreloaded(tx.Account))
+	require.False(t, idx.isPreloaded(tongo.AccountID{}))
+}
+
+func TestIndex_blockHeaders(t *testing.T) {
+	idx := newIndex(kv.NewMemoryStore())
+	header := &core.BlockHeader{
+		BlockIDExt: tongo.BlockIDExt{
+			BlockID:  tongo.BlockID{Workchain: -1, Shard: 0x8000000000000000, Seqno: 10},
+			RootHash: tongo.Bits256{1},
+			FileHash: tongo.Bits256{2},
+		},
+		PrevBlocks: []tongo.BlockIDExt{{BlockID: tongo.BlockID{Workchain: -1, Shard: 0x8000000000000000, Seqno: 9}}},
+		StartLt:    1000,
+		EndLt:      1005,
+		GenUtime:   1700000000,
+		TxQuantity: 3,
+	}
+	require.Nil(t, idx.storeBlockHeader(header))
+	got, err := idx.getBlockHeader(header.BlockID)
+	require.Nil(t, err)
+	require.Equal(t, header, got)
+
+	_, err = idx.getBlockHeader(tongo.BlockID{Workchain: 0, Shard: 0x8000000000000000, Seqno: 10})
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+}
+
+func TestLiteStorage_SearchTraces(t *testing.T) {
+	account := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	other := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	tx := func(a tongo.AccountID, lt uint64) *core.Transaction {
+		return &core.Transaction{
+			TransactionID: core.TransactionID{Hash: tongo.Bits256{byte(lt)}, Lt: lt, Account: a},
+			Utime:         int64(lt),
+		}
+	}
+	storage := &LiteStorage{logger: zap.NewNop(), index: newIndex(kv.NewMemoryStore())}
+	// the account initiates traces at lt 10 and 30 and takes part in a trace started by another account at lt 15.
+	otherRoot := tx(other, 15)
+	roots := map[*core.Transaction]*core.Transaction{}
+	for _, transaction := range []*core.Transaction{tx(account, 10), tx(account, 30), otherRoot} {
+		roots[transaction] = transaction
+	}
+	for _, lt := range []uint64{20, 25} {
+		roots[tx(account, lt)] = otherRoot
+	}
+	for transaction, root := range roots {
+		require.Nil(t, storage.index.storeTransaction(transaction))
+		require.Nil(t, storage.index.setTraceRoot(transaction.TransactionID, core.TraceID{Hash: root.Hash, Lt: root.Lt, UTime: root.Utime}, root.Account))
+	}
+	ptr := func(v int64) *int64 { return &v }
+	lts := func(ids []core.TraceID) []uint64 {
+		var res []uint64
+		for _, id := range ids {
+			res = append(res, id.Lt)
+		}
+		return res
+	}
+	tests := []struct {
+		name       string
+		limit      int
+		beforeLT   *int64
+		afterLT    *int64
+		startTime  *int64
+		initiator  bool
+		descending bool
+		want       []uint64
+	}{
+		{name: "latest", limit: 2, descending: true, want: []uint64{30, 15}},
+		{name: "all ascending", limit: 10, want: []uint64{10, 15, 30}},
+		{name: "before lt", limit: 10, beforeLT: ptr(30), descending: true, want: []uint64{15, 10}},
+		{name: "after lt", limit: 10, afterLT: ptr(10), want: []uint64{15, 30}},
+		{name: "start time", limit: 10, startTime: ptr(11), descending: true, want: []uint64{30, 15}},
+		{name: "initiator", limit: 10, initiator: true, descending: true, want: []uint64{30, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := storage.SearchTraces(context.Background(), account, tt.limit, tt.beforeLT, tt.afterLT, tt.startTime, nil, tt.initiator, tt.descending)
+			require.Nil(t, err)
+			require.Equal(t, tt.want, lts(ids))
+		})
+	}
+}
+
+func TestIndex_traces(t *testing.T) {
+	root := &core.Trace{Transaction: core.Transaction{
+		TransactionID: core.TransactionID{Hash: tongo.Bits256{1}, Lt: 10, Account: tongo.AccountID{Address: tongo.Bits256{1}}},
+		Utime:         1000,
+	}}
+	child := &core.Trace{Transaction: core.Transaction{
+		TransactionID: core.TransactionID{Hash: tongo.Bits256{2}, Lt: 20, Account: tongo.AccountID{Address: tongo.Bits256{2}}},
+		Utime:         1001,
+	}}
+	root.Children = []*core.Trace{child}
+
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	require.Nil(t, storage.index.storeTrace(root))
+
+	trace, ok := storage.assembledTrace(child.Hash)
+	require.True(t, ok)
+	require.Equal(t, root.Hash, trace.Hash)
+	require.Len(t, trace.Children, 1)
+	require.Equal(t, child.Hash, trace.Children[0].Hash)
+
+	tx, err := storage.index.getTransaction(child.Hash)
+	require.Nil(t, err)
+	require.Equal(t, child.Lt, tx.Lt)
+	id, ok := storage.index.traceRoot(child.Hash)
+	require.True(t, ok)
+	require.Equal(t, core.TraceID{Hash: root.Hash, Lt: 10, UTime: 1000}, id)
+}
+
+func TestLiteStorage_jettonHistory(t *testing.T) {
+	owner := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	other := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	usdt := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{10}}
+	not := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{11}}
+	op := func(kind core.JettonOperationType, lt uint64, source, destination *tongo.AccountID, master tongo.AccountID) core.JettonOperation {
+		return core.JettonOperation{
+			Operation:    kind,
+			Source:       source,
+			Destination:  destination,
+			JettonMaster: master,
+			TraceID:      tongo.Bits256{byte(lt / 10)},
+			TxID:         tongo.Bits256{byte(lt)},
+			Amount:       decimal.NewFromInt(int64(lt)),
+			Lt:           lt,
+			Utime:        int64(lt),
+		}
+	}
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	require.Nil(t, storage.index.storeJettonOperations([]core.JettonOperation{
+		op(core.MintJettonOperation, 10, nil, &owner, usdt),
+		op(core.TransferJettonOperation, 20, &owner, &other, usdt),
+		// two operations of the same trace.
+		op(core.TransferJettonOperation, 30, &other, &owner, not),
+		op(core.TransferJettonOperation, 31, &other, &owner, usdt),
+		op(core.BurnJettonOperation, 40, &other, nil, usdt),
+	}))
+	lts := func(ops []core.JettonOperation) []uint64 {
+		var res []uint64
+		for _, op := range ops {
+			res = append(res, op.Lt)
+		}
+		return res
+	}
+	ptr := func(v int64) *int64 { return &v }
+	ctx := context.Background()
+
+	ops, err := storage.GetAccountJettonsHistory(ctx, owner, 10, nil, nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{31, 30, 20, 10}, lts(ops))
+	require.True(t, decimal.NewFromInt(31).Equal(ops[0].Amount))
+
+	ops, err = storage.GetAccountJettonsHistory(ctx, owner, 2, ptr(31), nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{30, 20}, lts(ops))
+
+	ops, err = storage.GetJettonAccountHistoryByID(ctx, owner, usdt, 10, nil, ptr(15), ptr(35))
+	require.Nil(t, err)
+	require.Equal(t, []uint64{31, 20}, lts(ops))
+
+	ops, err = storage.GetAccountJettonsHistory(ctx, other, 10, nil, nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, []uint64{40, 31, 30, 20}, lts(ops))
+
+	traceIDs, err := storage.GetAccountJettonHistoryByID(ctx, other, usdt, 10, nil, nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.Bits256{{4}, {3}, {2}}, traceIDs)
+}
+
+func TestLiteStorage_jettonRegistry(t *testing.T) {
+	usdt := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{10}}
+	unknown := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{11}}
+	wallet := func(i byte) tongo.AccountID { return tongo.AccountID{Workchain: 0, Address: tongo.Bits256{i}} }
+	holder := func(i byte, balance int64) core.JettonHolder {
+		owner := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{100 + i}}
+		return core.JettonHolder{JettonAddress: usdt, Address: wallet(i), Owner: &owner, Balance: decimal.NewFromInt(balance)}
+	}
+	storage := &LiteStorage{
+		index:         newIndex(kv.NewMemoryStore()),
+		jettonMasters: xsync.NewTypedMapOf[tongo.AccountID, struct{}](hashAccountID),
+	}
+	master := core.JettonMaster{Address: usdt, Mintable: true}
+	master.TotalSupply.SetInt64(1_000_700)
+	require.Nil(t, storage.index.putJettonMaster(master))
+	require.Nil(t, storage.loadJettonMasters())
+	for _, h := range []core.JettonHolder{holder(1, 500), holder(2, 300), holder(3, 1_000_000), holder(4, 0), holder(2, 700)} {
+		require.Nil(t, storage.index.setJettonHolder(h))
+	}
+	wallets := func(holders []core.JettonHolder) []tongo.AccountID {
+		var res []tongo.AccountID
+		for _, h := range holders {
+			res = append(res, h.Address)
+		}
+		return res
+	}
+	ctx := context.Background()
+
+	counts, err := storage.GetJettonsHoldersCount(ctx, []tongo.AccountID{usdt, unknown})
+	require.Nil(t, err)
+	require.Equal(t, map[tongo.AccountID]int32{usdt: 3}, counts)
+
+	holders, err := storage.GetJettonHoldersByBalance(ctx, usdt, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{wallet(3), wallet(2), wallet(1)}, wallets(holders))
+	require.True(t, decimal.NewFromInt(700).Equal(holders[1].Balance))
+
+	holders, err = storage.GetJettonHoldersByBalance(ctx, usdt, 1, 1)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{wallet(2)}, wallets(holders))
+
+	_, err = storage.GetJettonHoldersByBalance(ctx, unknown, 10, 0)
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+
+	// the wallet is emptied.
+	require.Nil(t, storage.index.setJettonHolder(holder(3, 0)))
+	last := wallet(1)
+	holders, err = storage.GetJettonHoldersByAddress(ctx, usdt, 10, &last)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{wallet(2)}, wallets(holders))
+	counts, err = storage.GetJettonsHoldersCount(ctx, []tongo.AccountID{usdt})
+	require.Nil(t, err)
+	require.Equal(t, int32(2), counts[usdt])
+
+	masters, err := storage.GetJettonMasters(ctx, 10, nil)
+	require.Nil(t, err)
+	require.Len(t, masters, 1)
+	require.True(t, masters[0].Mintable)
+	require.Equal(t, int64(1_000_700), masters[0].TotalSupply.Int64())
+	masters, err = storage.GetJettonMasters(ctx, 10, &usdt)
+	require.Nil(t, err)
+	require.Empty(t, masters)
+	masters, err = storage.GetJettonMastersByAddresses(ctx, []tongo.AccountID{unknown, usdt})
+	require.Nil(t, err)
+	require.Len(t, masters, 1)
+}
+
+func TestLiteStorage_SearchNFTs(t *testing.T) {
+	account := func(i byte) tongo.AccountID { return tongo.AccountID{Workchain: 0, Address: tongo.Bits256{i}} }
+	alice, bob, saleContract := account(1), account(2), account(3)
+	punks, apes := account(10), account(11)
+	item := func(i byte, collection *tongo.AccountID, owner tongo.AccountID, verified bool) core.NftItem {
+		return core.NftItem{Address: account(100 + i), Index: decimal.NewFromInt(int64(i)), CollectionAddress: collection, OwnerAddress: &owner, Verified: verified}
+	}
+	onSale := item(3, &punks, saleContract, true)
+	onSale.Sale = &core.NftSaleInfo{Contract: saleContract, Nft: onSale.Address, Seller: &alice, Price: core.Price{Currency: core.Currency{Type: core.CurrencyNative}}}
+	onSale.Sale.Price.Amount.SetInt64(1_000_000_000)
+
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	for _, i := range []core.NftItem{
+		item(1, &punks, alice, true),
+		item(2, &apes, alice, false),
+		onSale,
+		item(4, nil, bob, true),
+		// the item is transferred from bob to alice.
+		item(5, &punks, bob, true),
+		item(5, &punks, alice, true),
+	} {
+		require.Nil(t, storage.index.putNftItem(i))
+	}
+	ctx := context.Background()
+	filter := func(a tongo.AccountID) *core.Filter[tongo.AccountID] { return &core.Filter[tongo.AccountID]{Value: a} }
+
+	ids, err := storage.SearchNFTs(ctx, nil, filter(alice), false, false, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{account(101), account(102), account(105)}, ids)
+
+	ids, err = storage.SearchNFTs(ctx, nil, filter(alice), true, true, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{account(101), account(103), account(105)}, ids)
+
+	ids, err = storage.SearchNFTs(ctx, filter(punks), filter(alice), true, false, 1, 1)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{account(103)}, ids)
+
+	ids, err = storage.SearchNFTs(ctx, filter(punks), nil, false, false, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{account(101), account(103), account(105)}, ids)
+
+	ids, err = storage.SearchNFTs(ctx, &core.Filter[tongo.AccountID]{IsZero: true}, nil, false, false, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{account(104)}, ids)
+
+	ids, err = storage.SearchNFTs(ctx, nil, filter(bob), false, false, 10, 0)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{account(104)}, ids)
+
+	// items without filters are paginated within the index.
+	ids, err = storage.SearchNFTs(ctx, nil, nil, false, false, 2, 1)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{account(102), account(103)}, ids)
+
+	items, err := storage.GetNFTs(ctx, []tongo.AccountID{account(103)})
+	require.Nil(t, err)
+	require.Len(t, items, 1)
+	require.Equal(t, alice, *items[0].Sale.Seller)
+	require.Equal(t, int64(1_000_000_000), items[0].Sale.Price.Amount.Int64())
+
+	require.Nil(t, storage.index.putNftCollection(core.NftCollection{Address: punks, NextItemIndex: 6}))
+	require.Nil(t, storage.index.putNftCollection(core.NftCollection{Address: apes, NextItemIndex: 3}))
+	offset := int32(1)
+	collections, err := storage.GetNftCollections(ctx, nil, &offset)
+	require.Nil(t, err)
+	require.Len(t, collections, 1)
+	require.Equal(t, apes, collections[0].Address)
+	collections, err = storage.GetNftCollectionsByAddresses(ctx, []tongo.AccountID{punks, alice})
+	require.Nil(t, err)
+	require.Len(t, collections, 1)
+	require.Equal(t, int64(6), collections[0].NextItemIndex)
+}
+
+func TestLiteStorage_SearchNFTs_batches(t *testing.T) {
+	collection := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	owner := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	var verified []tongo.AccountID
+	for i := 0; i < 2*nftSearchBatchSize+10; i++ {
+		item := core.NftItem{
+			Address:           tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3, byte(i >> 8), byte(i)}},
+			Index:             decimal.NewFromInt(int64(i)),
+			CollectionAddress: &collection,
+			OwnerAddress:      &owner,
+			Verified:          i%2 == 0,
+		}
+		require.Nil(t, storage.index.putNftItem(item))
+		if item.Verified {
+			verified = append(verified, item.Address)
+		}
+	}
+	ctx := context.Background()
+	filter := &core.Filter[tongo.AccountID]{Value: collection}
+
+	ids, err := storage.SearchNFTs(ctx, filter, nil, false, true, 5, nftSearchBatchSize-2)
+	require.Nil(t, err)
+	require.Equal(t, verified[nftSearchBatchSize-2:nftSearchBatchSize+3], ids)
+
+	ids, err = storage.SearchNFTs(ctx, filter, nil, false, true, 10, len(verified)-3)
+	require.Nil(t, err)
+	require.Equal(t, verified[len(verified)-3:], ids)
+}
+
+func TestIndex_matchedRules(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	i := newIndex(kv.NewMemoryStore())
+	require.Nil(t, i.addMatchedRule(a, "interface:jetton_wallet"))
+	require.Nil(t, i.addMatchedRule(a, "code_hash:"+tongo.Bits256{7}.Hex()))
+
+	rules, err := i.matchedRules(a)
+	require.Nil(t, err)
+	require.Equal(t, []string{"code_hash:" + tongo.Bits256{7}.Hex(), "interface:jetton_wallet"}, rules)
+	rules, err = i.matchedRules(b)
+	require.Nil(t, err)
+	require.Empty(t, rules)
+}
+
+func TestLiteStorage_loadTrackedAccounts(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	c := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+	store := kv.NewMemoryStore()
+	storage := &LiteStorage{
+		logger:           zap.NewNop(),
+		index:            newIndex(store),
+		trackingAccounts: map[tongo.AccountID]struct{}{},
+		preloadQueue:     make(chan tongo.AccountID, 1),
+	}
+	preload, err := storage.loadTrackedAccounts([]tongo.AccountID{a, b})
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{a, b}, preload)
+
+	require.Nil(t, storage.TrackAccount(c))
+	require.Equal(t, c, <-storage.preloadQueue)
+	require.Nil(t, storage.UntrackAccount(a))
+
+	// a is listed in the env variable, but it was untracked at runtime.
+	restarted := &LiteStorage{
+		index:            newIndex(store),
+		trackingAccounts: map[tongo.AccountID]struct{}{},
+	}
+	preload, err = restarted.loadTrackedAccounts([]tongo.AccountID{a, b})
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{b, c}, preload)
+	require.Equal(t, map[tongo.AccountID]struct{}{b: {}, c: {}}, restarted.trackingAccounts)
+
+	restarted.logger = zap.NewNop()
+	restarted.preloadQueue = make(chan tongo.AccountID, 1)
+	require.Nil(t, restarted.TrackAccount(a))
+	require.Equal(t, a, <-restarted.preloadQueue)
+	restarted = &LiteStorage{
+		index:            newIndex(store),
+		trackingAccounts: map[tongo.AccountID]struct{}{},
+	}
+	_, err = restarted.loadTrackedAccounts([]tongo.AccountID{a, b})
+	require.Nil(t, err)
+	require.Len(t, restarted.trackingAccounts, 3)
+}
+
+func TestLiteStorage_traceIndexingQueue(t *testing.T) {
+	newTrace := func(hashes ...byte) *core.Trace {
+		var trace *core.Trace
+		for i := len(hashes) - 1; i >= 0; i-- {
+			node := &core.Trace{
+				Transaction: core.Transaction{
+					TransactionID: core.TransactionID{Hash: tongo.Bits256{hashes[i]}, Lt: uint64(hashes[i]), Account: tongo.AccountID{Address: tongo.Bits256{hashes[i]}}},
+				},
+				AccountInterfaces: []abi.ContractInterface{abi.WalletV4R2},
+			}
+			if trace != nil {
+				node.Children = []*core.Trace{trace}
+			}
+			trace = node
+		}
+		return trace
+	}
+	failing := true
+	var indexed []tongo.Bits256
+	storage := &LiteStorage{
+		logger:            zap.NewNop(),
+		index:             newIndex(kv.NewMemoryStore()),
+		indexedTraces:     cache.NewLRUCache[tongo.Bits256, struct{}](10, "test_indexed_traces"),
+		traceIndexingWake: make(chan struct{}, 1),
+		traceExtractors: traceExtractors{
+			jettonOperations: func(ctx context.Context, source core.InformationSource, trace *core.Trace) ([]core.JettonOperation, error) {
+				if trace.Hash == (tongo.Bits256{3}) && failing {
+					return nil, fmt.Errorf("lite server is unavailable")
+				}
+				indexed = append(indexed, trace.Hash)
+				return nil, nil
+			},
+		},
+	}
+	require.Nil(t, storage.index.storeTrace(newTrace(1, 2)))
+	require.Nil(t, storage.index.storeTrace(newTrace(3)))
+	for _, hash := range []tongo.Bits256{{1}, {2}, {3}} {
+		storage.enqueueTraceIndexing(hash)
+	}
+	require.Len(t, storage.traceIndexingWake, 1)
+
+	_, more := storage.indexQueuedTraces(context.Background(), nil)
+	require.False(t, more)
+	// both transactions of the first trace are queued, but the trace is indexed once.
+	require.Equal(t, []tongo.Bits256{{1}}, indexed)
+
+	// the failed trace stays in the queue and is indexed by the next pass.
+	failing = false
+	storage.indexQueuedTraces(context.Background(), nil)
+	require.Equal(t, []tongo.Bits256{{1}, {3}}, indexed)
+	err := storage.index.store.Range(traceIndexingQueueBucket, nil, nil, false, func(key, value []byte) bool {
+		t.Errorf("unexpected queued transaction %x", key)
+		return true
+	})
+	require.Nil(t, err)
+}
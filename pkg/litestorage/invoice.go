@@ -2,16 +2,131 @@ package litestorage
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
+	"fmt"
+
 	"github.com/google/uuid"
-	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
 )
 
+// storedInvoicePayment is core.InvoicePayment as it is kept in the kv store,
+// core.Price can't be encoded to json because of its big.Int amount.
+type storedInvoicePayment struct {
+	core.InvoicePayment
+	Amount storedPrice
+}
+
+func (p storedInvoicePayment) invoicePayment() core.InvoicePayment {
+	payment := p.InvoicePayment
+	payment.Amount = p.Amount.price()
+	return payment
+}
+
+// invoiceCurrency returns a currency the way the API identifies it in invoice lookups:
+// an empty string for TON, a raw address of a jetton master or an extra currency ID.
+func invoiceCurrency(currency core.Currency) string {
+	switch currency.Type {
+	case core.CurrencyJetton:
+		if currency.Jetton != nil {
+			return currency.Jetton.ToRaw()
+		}
+	case core.CurrencyExtra:
+		if currency.CurrencyID != nil {
+			return fmt.Sprintf("%d", int64(uint32(*currency.CurrencyID)))
+		}
+	}
+	return ""
+}
+
+func invoiceKey(source, destination tongo.AccountID, invoiceID uuid.UUID, currency string) []byte {
+	key := append(accountKey(source), accountKey(destination)...)
+	key = append(key, invoiceID[:]...)
+	return append(key, currency...)
+}
+
+func invoicePaymentKey(payment core.InvoicePayment) []byte {
+	key := binary.BigEndian.AppendUint64(accountKey(payment.Source), payment.InMsgLt)
+	return append(key, payment.InvoiceID[:]...)
+}
+
+func (i *index) storeInvoicePayments(payments []core.InvoicePayment) error {
+	for _, payment := range payments {
+		value, err := json.Marshal(storedInvoicePayment{InvoicePayment: payment, Amount: newStoredPrice(payment.Amount)})
+		if err != nil {
+			return err
+		}
+		if err := i.store.Put(invoicePaymentsBucket, invoicePaymentKey(payment), value); err != nil {
+			return err
+		}
+		key := invoiceKey(payment.Source, payment.Destination, payment.InvoiceID, invoiceCurrency(payment.Amount.Currency))
+		if err := i.store.Put(invoicesBucket, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeInvoicePayment(value []byte) (core.InvoicePayment, error) {
+	var payment storedInvoicePayment
+	if err := json.Unmarshal(value, &payment); err != nil {
+		return core.InvoicePayment{}, err
+	}
+	return payment.invoicePayment(), nil
+}
+
+// invoicePayments returns invoices paid by the source before the given lt, the latest first.
+func (i *index) invoicePayments(source tongo.AccountID, beforeLT *int64, limit int) ([]core.InvoicePayment, error) {
+	prefix := accountKey(source)
+	to := kv.PrefixEnd(prefix)
+	if beforeLT != nil {
+		to = binary.BigEndian.AppendUint64(accountKey(source), uint64(*beforeLT))
+	}
+	var payments []core.InvoicePayment
+	var decodeErr error
+	err := i.store.Range(invoicePaymentsBucket, prefix, to, true, func(key, value []byte) bool {
+		var payment core.InvoicePayment
+		if payment, decodeErr = decodeInvoicePayment(value); decodeErr != nil {
+			return false
+		}
+		payments = append(payments, payment)
+		return limit <= 0 || len(payments) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payments, decodeErr
+}
+
+func (i *index) invoice(source, destination tongo.AccountID, invoiceID uuid.UUID, currency string) (core.InvoicePayment, error) {
+	value, err := i.store.Get(invoicesBucket, invoiceKey(source, destination, invoiceID, currency))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return core.InvoicePayment{}, core.ErrEntityNotFound
+		}
+		return core.InvoicePayment{}, err
+	}
+	return decodeInvoicePayment(value)
+}
+
+// GetAccountInvoicesHistory returns invoices paid by the account, the latest first.
 func (s *LiteStorage) GetAccountInvoicesHistory(ctx context.Context, address tongo.AccountID, limit int, beforeLT *int64) ([]core.InvoicePayment, error) {
-	return nil, nil
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_account_invoices_history").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	return s.index.invoicePayments(address, beforeLT, limit)
 }
 
 func (s *LiteStorage) GetInvoice(ctx context.Context, source, destination tongo.AccountID, invoiceID uuid.UUID, currency string) (core.InvoicePayment, error) {
-	return core.InvoicePayment{}, errors.New("not implemented yet")
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_invoice").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	return s.index.invoice(source, destination, invoiceID, currency)
 }
@@ -0,0 +1,55 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestLiteStorage_invoices(t *testing.T) {
+	account := func(i byte) tongo.AccountID { return tongo.AccountID{Workchain: 0, Address: tongo.Bits256{i}} }
+	buyer, shop, usdt := account(1), account(2), account(3)
+	payment := func(lt uint64, id byte, currency core.Currency, amount int64) core.InvoicePayment {
+		p := core.InvoicePayment{
+			Source:      buyer,
+			Destination: shop,
+			TraceID:     core.TraceID{Hash: tongo.Bits256{id}, Lt: lt},
+			InMsgLt:     lt,
+			Utime:       int64(lt),
+			InvoiceID:   uuid.UUID{id},
+			Amount:      core.Price{Currency: currency},
+			Metadata:    core.PurchaseMetadata{Type: core.NoneMetadataType},
+		}
+		p.Amount.Amount.SetInt64(amount)
+		return p
+	}
+	ton := core.Currency{Type: core.CurrencyNative}
+	jetton := core.Currency{Type: core.CurrencyJetton, Jetton: &usdt}
+	payments := []core.InvoicePayment{payment(100, 1, ton, 1_000_000_000), payment(200, 2, jetton, 5_000_000), payment(300, 3, ton, 10)}
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	require.Nil(t, storage.index.storeInvoicePayments(payments))
+
+	ctx := context.Background()
+	history, err := storage.GetAccountInvoicesHistory(ctx, buyer, 2, nil)
+	require.Nil(t, err)
+	require.Equal(t, []core.InvoicePayment{payments[2], payments[1]}, history)
+	beforeLT := int64(200)
+	history, err = storage.GetAccountInvoicesHistory(ctx, buyer, 10, &beforeLT)
+	require.Nil(t, err)
+	require.Equal(t, []core.InvoicePayment{payments[0]}, history)
+	history, err = storage.GetAccountInvoicesHistory(ctx, shop, 10, nil)
+	require.Nil(t, err)
+	require.Empty(t, history)
+
+	invoice, err := storage.GetInvoice(ctx, buyer, shop, uuid.UUID{2}, usdt.ToRaw())
+	require.Nil(t, err)
+	require.Equal(t, payments[1], invoice)
+	_, err = storage.GetInvoice(ctx, buyer, shop, uuid.UUID{2}, "")
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+}
@@ -145,18 +145,6 @@ func (s *LiteStorage) GetJettonMasterData(ctx context.Context, master tongo.Acco
 	return jettonMaster, nil
 }
 
-func (s *LiteStorage) GetAccountJettonsHistory(ctx context.Context, address tongo.AccountID, limit int, beforeLT, startTime, endTime *int64) ([]core.JettonOperation, error) {
-	return nil, nil
-}
-
-func (s *LiteStorage) GetAccountJettonHistoryByID(ctx context.Context, address, jettonMaster tongo.AccountID, limit int, beforeLT, startTime, endTime *int64) ([]tongo.Bits256, error) {
-	return nil, nil
-}
-
-func (s *LiteStorage) GetJettonAccountHistoryByID(ctx context.Context, address, jettonMaster tongo.AccountID, limit int, beforeLT, startTime, endTime *int64) ([]core.JettonOperation, error) {
-	return nil, nil
-}
-
 func (s *LiteStorage) JettonMastersForWallets(ctx context.Context, wallets []tongo.AccountID) (map[tongo.AccountID]tongo.AccountID, error) {
 	masters := make(map[tongo.AccountID]tongo.AccountID)
 	for _, wallet := range wallets {
@@ -183,32 +171,6 @@ func (s *LiteStorage) JettonMastersForWallets(ctx context.Context, wallets []ton
 	return masters, nil
 }
 
-func (s *LiteStorage) GetJettonMasters(ctx context.Context, limit int, lastAccountID *tongo.AccountID) ([]core.JettonMaster, error) {
-	// TODO: implement
-	return []core.JettonMaster{}, nil
-}
-
-func (s *LiteStorage) GetJettonMastersByOffset(ctx context.Context, limit, offset int) ([]core.JettonMaster, error) {
-	// TODO: implement
-	return []core.JettonMaster{}, nil
-}
-
-func (s *LiteStorage) GetJettonMastersByAddresses(ctx context.Context, addresses []ton.AccountID) ([]core.JettonMaster, error) {
-	return []core.JettonMaster{}, nil
-}
-
-func (s *LiteStorage) GetJettonsHoldersCount(ctx context.Context, accountIDs []tongo.AccountID) (map[tongo.AccountID]int32, error) {
-	return map[tongo.AccountID]int32{}, nil
-}
-
-func (s *LiteStorage) GetJettonHoldersByBalance(ctx context.Context, jettonMaster tongo.AccountID, limit, offset int) ([]core.JettonHolder, error) {
-	return []core.JettonHolder{}, nil
-}
-
-func (s *LiteStorage) GetJettonHoldersByAddress(ctx context.Context, jettonMaster tongo.AccountID, limit int, lastAccountID *tongo.AccountID) ([]core.JettonHolder, error) {
-	return []core.JettonHolder{}, nil
-}
-
 func (s *LiteStorage) GetScaledUIParameters(ctx context.Context, master tongo.AccountID, beforeLt *int64) (*core.ScaledUIParameters, error) {
 	// return latest parameters instead of historical data
 	_, value, err := abi.GetDisplayMultiplier(ctx, s.executor, master)
@@ -0,0 +1,131 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+// jettonOperationKey returns a key of the operation in the history of the given account.
+// n distinguishes operations of the same transaction.
+func jettonOperationKey(prefix []byte, op core.JettonOperation, n int) []byte {
+	key := make([]byte, 0, len(prefix)+8+32+2)
+	key = append(key, prefix...)
+	key = binary.BigEndian.AppendUint64(key, op.Lt)
+	key = append(key, op.TxID[:]...)
+	return binary.BigEndian.AppendUint16(key, uint16(n))
+}
+
+func jettonMasterPrefix(a, master tongo.AccountID) []byte {
+	return append(accountKey(a), accountKey(master)...)
+}
+
+// storeJettonOperations adds the operations to the history of their senders and recipients.
+func (i *index) storeJettonOperations(operations []core.JettonOperation) error {
+	for n, op := range operations {
+		value, err := json.Marshal(op)
+		if err != nil {
+			return err
+		}
+		for _, a := range []*tongo.AccountID{op.Source, op.Destination} {
+			if a == nil {
+				continue
+			}
+			if err := i.store.Put(jettonOperationsBucket, jettonOperationKey(accountKey(*a), op, n), value); err != nil {
+				return err
+			}
+			if err := i.store.Put(jettonMasterOperationsBucket, jettonOperationKey(jettonMasterPrefix(*a, op.JettonMaster), op, n), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jettonOperations walks over operations with the given prefix from the latest to the oldest,
+// until fn returns false.
+func (i *index) jettonOperations(bucket string, prefix []byte, beforeLT *int64, fn func(op core.JettonOperation) bool) error {
+	to := kv.PrefixEnd(prefix)
+	if beforeLT != nil {
+		to = binary.BigEndian.AppendUint64(append([]byte{}, prefix...), uint64(*beforeLT))
+	}
+	var decodeErr error
+	err := i.store.Range(bucket, prefix, to, true, func(key, value []byte) bool {
+		var op core.JettonOperation
+		if err := json.Unmarshal(value, &op); err != nil {
+			decodeErr = err
+			return false
+		}
+		return fn(op)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+func inTimeRange(utime int64, startTime, endTime *int64) bool {
+	if startTime != nil && utime < *startTime {
+		return false
+	}
+	if endTime != nil && utime > *endTime {
+		return false
+	}
+	return true
+}
+
+func (s *LiteStorage) jettonHistory(bucket string, prefix []byte, limit int, beforeLT, startTime, endTime *int64) ([]core.JettonOperation, error) {
+	var operations []core.JettonOperation
+	if limit <= 0 {
+		return nil, nil
+	}
+	err := s.index.jettonOperations(bucket, prefix, beforeLT, func(op core.JettonOperation) bool {
+		if inTimeRange(op.Utime, startTime, endTime) {
+			operations = append(operations, op)
+		}
+		return len(operations) < limit
+	})
+	return operations, err
+}
+
+func (s *LiteStorage) GetAccountJettonsHistory(ctx context.Context, address tongo.AccountID, limit int, beforeLT, startTime, endTime *int64) ([]core.JettonOperation, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_account_jettons_history").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	return s.jettonHistory(jettonOperationsBucket, accountKey(address), limit, beforeLT, startTime, endTime)
+}
+
+func (s *LiteStorage) GetAccountJettonHistoryByID(ctx context.Context, address, jettonMaster tongo.AccountID, limit int, beforeLT, startTime, endTime *int64) ([]tongo.Bits256, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_account_jetton_history_by_id").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if limit <= 0 {
+		return nil, nil
+	}
+	var traceIDs []tongo.Bits256
+	seen := map[tongo.Bits256]struct{}{}
+	err := s.index.jettonOperations(jettonMasterOperationsBucket, jettonMasterPrefix(address, jettonMaster), beforeLT, func(op core.JettonOperation) bool {
+		if _, ok := seen[op.TraceID]; !ok && inTimeRange(op.Utime, startTime, endTime) {
+			seen[op.TraceID] = struct{}{}
+			traceIDs = append(traceIDs, op.TraceID)
+		}
+		return len(traceIDs) < limit
+	})
+	return traceIDs, err
+}
+
+func (s *LiteStorage) GetJettonAccountHistoryByID(ctx context.Context, address, jettonMaster tongo.AccountID, limit int, beforeLT, startTime, endTime *int64) ([]core.JettonOperation, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_jetton_account_history_by_id").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	return s.jettonHistory(jettonMasterOperationsBucket, jettonMasterPrefix(address, jettonMaster), limit, beforeLT, startTime, endTime)
+}
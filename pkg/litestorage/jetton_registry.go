@@ -0,0 +1,538 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/shopspring/decimal"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/liteapi"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+	"github.com/tonkeeper/tongo/wallet"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+var jettonRegistryUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "lite_storage_jetton_registry_updates_total",
+	Help: "Number of jetton masters and wallets checked by the jetton registry",
+}, []string{"kind", "result"})
+
+const (
+	// jettonRegistryQueueSize is a number of accounts waiting to be refreshed by the jetton registry.
+	jettonRegistryQueueSize = 10_000
+	// jettonWalletsCacheSize limits the number of wallets whose jetton master has been verified.
+	jettonWalletsCacheSize = 100_000
+)
+
+// jettonRegistryUpdate is an account to be refreshed by the jetton registry.
+type jettonRegistryUpdate struct {
+	account tongo.AccountID
+	// master is true if the account is a registered jetton master, otherwise it is a possible jetton wallet.
+	master bool
+}
+
+// jettonWalletOpCodes contains op codes of inbound messages which change a jetton wallet's balance.
+var jettonWalletOpCodes = map[uint32]struct{}{
+	uint32(abi.JettonTransferMsgOpCode):         {},
+	uint32(abi.JettonInternalTransferMsgOpCode): {},
+	uint32(abi.JettonBurnMsgOpCode):             {},
+}
+
+// enqueueJettonRegistryUpdates schedules refreshing of registered jetton masters and possible jetton wallets
+// which have transactions in the given block.
+// The registry learns about holders only from new blocks, so wallets without recent activity are unknown to it.
+func (s *LiteStorage) enqueueJettonRegistryUpdates(workchain int32, txs []*tlb.Transaction) {
+	for _, tx := range txs {
+		a := *ton.NewAccountID(workchain, tx.AccountAddr)
+		update := jettonRegistryUpdate{account: a}
+		if _, ok := s.jettonMasters.Load(a); ok {
+			update.master = true
+		} else if op, ok := inMsgOpCode(tx); !ok {
+			continue
+		} else if _, ok := jettonWalletOpCodes[op]; !ok {
+			continue
+		}
+		select {
+		case s.jettonRegistryQueue <- update:
+		default:
+			// we don't want to slow down block processing, the wallet is refreshed with its next transaction.
+			jettonRegistryUpdates.WithLabelValues("queue", "dropped").Inc()
+		}
+	}
+}
+
+func inMsgOpCode(tx *tlb.Transaction) (uint32, bool) {
+	if !tx.Msgs.InMsg.Exists || tx.Msgs.InMsg.Value.Value.Info.SumType != "IntMsgInfo" {
+		return 0, false
+	}
+	body := boc.Cell(tx.Msgs.InMsg.Value.Value.Body.Value)
+	body.ResetCounters()
+	if body.BitsAvailableForRead() < 32 {
+		return 0, false
+	}
+	op, err := body.ReadUint(32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(op), true
+}
+
+// runJettonRegistry registers known jettons and then keeps masters and holders up to date.
+func (s *LiteStorage) runJettonRegistry() {
+	ctx := context.Background()
+	for _, master := range s.knownAccounts["jettons"] {
+		if err := s.refreshJettonMaster(ctx, master); err != nil {
+			s.logger.Warn("failed to register jetton master", zap.String("accountID", master.String()), zap.Error(err))
+		}
+	}
+	for update := range s.jettonRegistryQueue {
+		kind, refresh := "wallet", s.refreshJettonWallet
+		if update.master {
+			kind, refresh = "master", s.refreshJettonMaster
+		}
+		if err := refresh(ctx, update.account); err != nil {
+			jettonRegistryUpdates.WithLabelValues(kind, "error").Inc()
+			s.logger.Warn("failed to update jetton registry",
+				zap.String("kind", kind),
+				zap.String("accountID", update.account.String()),
+				zap.Error(err))
+			continue
+		}
+		jettonRegistryUpdates.WithLabelValues(kind, "success").Inc()
+	}
+}
+
+// refreshJettonMaster adds the master to the registry or updates its data.
+func (s *LiteStorage) refreshJettonMaster(ctx context.Context, master tongo.AccountID) error {
+	data, err := s.GetJettonMasterData(ctx, master)
+	if err != nil {
+		return err
+	}
+	if err := s.index.putJettonMaster(data); err != nil {
+		return err
+	}
+	s.jettonMasters.Store(master, struct{}{})
+	return nil
+}
+
+// refreshJettonWallet updates the balance of the jetton wallet.
+// A wallet is accepted only if its jetton master confirms that the wallet belongs to it,
+// so a contract can't pretend to be a wallet of somebody else's jetton.
+func (s *LiteStorage) refreshJettonWallet(ctx context.Context, a tongo.AccountID) error {
+	master, verified := s.jettonWallets.Get(a)
+	if verified && master == nil {
+		// we have already seen this account, it is not a jetton wallet.
+		return nil
+	}
+	_, value, err := abi.GetWalletData(ctx, s.executor, a)
+	if err != nil && verified && errors.Is(err, liteapi.ErrAccountNotFound) {
+		// the wallet has been destroyed.
+		return s.index.setJettonHolder(core.JettonHolder{JettonAddress: *master, Address: a})
+	}
+	if err != nil && isGetMethodFailure(err) {
+		s.jettonWallets.Set(a, nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, ok := value.(abi.GetWalletDataResult)
+	if !ok {
+		s.jettonWallets.Set(a, nil)
+		return nil
+	}
+	jetton, err := tongo.AccountIDFromTlb(data.Jetton)
+	if err != nil || jetton == nil {
+		s.jettonWallets.Set(a, nil)
+		return nil
+	}
+	owner, err := tongo.AccountIDFromTlb(data.Owner)
+	if err != nil {
+		return err
+	}
+	if !verified || *master != *jetton {
+		if owner == nil {
+			s.jettonWallets.Set(a, nil)
+			return nil
+		}
+		_, value, err := abi.GetWalletAddress(ctx, s.executor, *jetton, owner.ToMsgAddress())
+		if err != nil {
+			return err
+		}
+		result, ok := value.(abi.GetWalletAddressResult)
+		if !ok || !msgAddressEquals(result.JettonWalletAddress, a) {
+			s.jettonWallets.Set(a, nil)
+			return nil
+		}
+		s.jettonWallets.Set(a, jetton)
+	}
+	if _, ok := s.jettonMasters.Load(*jetton); !ok {
+		if err := s.refreshJettonMaster(ctx, *jetton); err != nil {
+			return err
+		}
+	}
+	balance := big.Int(data.Balance)
+	holder := core.JettonHolder{
+		JettonAddress: *jetton,
+		Address:       a,
+		Owner:         owner,
+		Balance:       decimal.NewFromBigInt(&balance, 0),
+	}
+	if prev, err := s.index.jettonHolder(*jetton, a); err == nil && ownersEqual(prev.Owner, owner) {
+		holder.OwnerIsWallet = prev.OwnerIsWallet
+	} else if owner != nil {
+		holder.OwnerIsWallet = s.isWallet(ctx, *owner)
+	}
+	if err := s.index.setJettonHolder(holder); err != nil {
+		return err
+	}
+	return s.addLiquidPoolHolder(holder)
+}
+
+// isGetMethodFailure returns true if the error means that the account doesn't support a get method,
+// as opposed to a network failure.
+func isGetMethodFailure(err error) bool {
+	return errors.Is(err, liteapi.ErrAccountNotFound) ||
+		strings.Contains(err.Error(), "can not decode outputs") ||
+		strings.Contains(err.Error(), "method execution failed")
+}
+
+func ownersEqual(a, b *tongo.AccountID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// isWallet returns true if the account's code is a code of one of the well-known wallets.
+func (s *LiteStorage) isWallet(ctx context.Context, a tongo.AccountID) bool {
+	account, err := s.GetRawAccount(ctx, a)
+	if err != nil || len(account.Code) == 0 {
+		return false
+	}
+	hash, err := codeHash(account.Code)
+	if err != nil {
+		return false
+	}
+	_, ok := wallet.GetVerByCodeHash(tlb.Bits256(hash))
+	return ok
+}
+
+func jettonHolderKey(master, wallet tongo.AccountID) []byte {
+	return append(accountKey(master), accountKey(wallet)...)
+}
+
+// jettonBalanceKey orders holders of the jetton by their balance.
+func jettonBalanceKey(holder core.JettonHolder) []byte {
+	key := accountKey(holder.JettonAddress)
+	balance := holder.Balance.BigInt()
+	if balance.Sign() < 0 || balance.BitLen() > 256 {
+		balance = big.NewInt(0)
+	}
+	key = append(key, balance.FillBytes(make([]byte, 32))...)
+	return append(key, accountKey(holder.Address)...)
+}
+
+// storedJettonMaster is how the jetton registry keeps a jetton master.
+// big.Int implements json.Marshaler with a pointer receiver, so TotalSupply needs a pointer.
+type storedJettonMaster struct {
+	core.JettonMaster
+	TotalSupply *big.Int
+}
+
+func (m storedJettonMaster) jettonMaster() core.JettonMaster {
+	master := m.JettonMaster
+	if m.TotalSupply != nil {
+		master.TotalSupply.Set(m.TotalSupply)
+	}
+	return master
+}
+
+func (i *index) putJettonMaster(master core.JettonMaster) error {
+	master.Enriched = nil
+	value, err := json.Marshal(storedJettonMaster{JettonMaster: master, TotalSupply: &master.TotalSupply})
+	if err != nil {
+		return err
+	}
+	return i.store.Put(jettonMastersBucket, accountKey(master.Address), value)
+}
+
+func (i *index) jettonMaster(a tongo.AccountID) (core.JettonMaster, error) {
+	value, err := i.store.Get(jettonMastersBucket, accountKey(a))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return core.JettonMaster{}, core.ErrEntityNotFound
+		}
+		return core.JettonMaster{}, err
+	}
+	var master storedJettonMaster
+	if err := json.Unmarshal(value, &master); err != nil {
+		return core.JettonMaster{}, err
+	}
+	return master.jettonMaster(), nil
+}
+
+// jettonMasters walks over registered masters ordered by their addresses starting from the given key,
+// until fn returns false.
+func (i *index) jettonMasters(from []byte, fn func(master core.JettonMaster) bool) error {
+	var decodeErr error
+	err := i.store.Range(jettonMastersBucket, from, nil, false, func(key, value []byte) bool {
+		var master storedJettonMaster
+		if err := json.Unmarshal(value, &master); err != nil {
+			decodeErr = err
+			return false
+		}
+		return fn(master.jettonMaster())
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+func (i *index) jettonHolder(master, wallet tongo.AccountID) (core.JettonHolder, error) {
+	value, err := i.store.Get(jettonHoldersBucket, jettonHolderKey(master, wallet))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return core.JettonHolder{}, core.ErrEntityNotFound
+		}
+		return core.JettonHolder{}, err
+	}
+	var holder core.JettonHolder
+	if err := json.Unmarshal(value, &holder); err != nil {
+		return core.JettonHolder{}, err
+	}
+	return holder, nil
+}
+
+// setJettonHolder stores the holder's balance.
+// A holder with zero balance is removed, so only wallets with jettons are counted as holders.
+func (i *index) setJettonHolder(holder core.JettonHolder) error {
+	prev, err := i.jettonHolder(holder.JettonAddress, holder.Address)
+	exists := err == nil
+	if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+		return err
+	}
+	if exists {
+		if err := i.store.Delete(jettonHoldersByBalanceBucket, jettonBalanceKey(prev)); err != nil {
+			return err
+		}
+	}
+	key := jettonHolderKey(holder.JettonAddress, holder.Address)
+	if !holder.Balance.IsPositive() {
+		if !exists {
+			return nil
+		}
+		if err := i.store.Delete(jettonHoldersBucket, key); err != nil {
+			return err
+		}
+		return i.addJettonHoldersCount(holder.JettonAddress, -1)
+	}
+	value, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+	if err := i.store.Put(jettonHoldersBucket, key, value); err != nil {
+		return err
+	}
+	if err := i.store.Put(jettonHoldersByBalanceBucket, jettonBalanceKey(holder), []byte{1}); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return i.addJettonHoldersCount(holder.JettonAddress, 1)
+}
+
+func (i *index) jettonHoldersCount(master tongo.AccountID) int64 {
+	value, err := i.store.Get(jettonHoldersCountBucket, accountKey(master))
+	if err != nil || len(value) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value))
+}
+
+func (i *index) addJettonHoldersCount(master tongo.AccountID, delta int64) error {
+	count := max(i.jettonHoldersCount(master)+delta, 0)
+	return i.store.Put(jettonHoldersCountBucket, accountKey(master), binary.BigEndian.AppendUint64(nil, uint64(count)))
+}
+
+// jettonHolders walks over holders of the jetton ordered by their wallet addresses, until fn returns false.
+func (i *index) jettonHolders(master tongo.AccountID, after *tongo.AccountID, fn func(holder core.JettonHolder) bool) error {
+	prefix := accountKey(master)
+	from := prefix
+	if after != nil {
+		// the smallest key greater than the given wallet.
+		from = append(jettonHolderKey(master, *after), 0)
+	}
+	var decodeErr error
+	err := i.store.Range(jettonHoldersBucket, from, kv.PrefixEnd(prefix), false, func(key, value []byte) bool {
+		var holder core.JettonHolder
+		if err := json.Unmarshal(value, &holder); err != nil {
+			decodeErr = err
+			return false
+		}
+		return fn(holder)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// jettonWalletsByBalance returns up to limit wallets of the jetton with the largest balances, skipping offset wallets.
+func (i *index) jettonWalletsByBalance(master tongo.AccountID, limit, offset int) ([]tongo.AccountID, error) {
+	prefix := accountKey(master)
+	var wallets []tongo.AccountID
+	skipped := 0
+	err := i.store.Range(jettonHoldersByBalanceBucket, prefix, kv.PrefixEnd(prefix), true, func(key, value []byte) bool {
+		if skipped < offset {
+			skipped++
+			return true
+		}
+		wallets = append(wallets, accountFromKey(key[len(key)-36:]))
+		return len(wallets) < limit
+	})
+	return wallets, err
+}
+
+func (s *LiteStorage) GetJettonMasters(ctx context.Context, limit int, lastAccountID *tongo.AccountID) ([]core.JettonMaster, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_jetton_masters").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	masters := []core.JettonMaster{}
+	if limit <= 0 {
+		return masters, nil
+	}
+	var from []byte
+	if lastAccountID != nil {
+		from = append(accountKey(*lastAccountID), 0)
+	}
+	err := s.index.jettonMasters(from, func(master core.JettonMaster) bool {
+		masters = append(masters, master)
+		return len(masters) < limit
+	})
+	return masters, err
+}
+
+func (s *LiteStorage) GetJettonMastersByOffset(ctx context.Context, limit, offset int) ([]core.JettonMaster, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_jetton_masters_by_offset").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	masters := []core.JettonMaster{}
+	if limit <= 0 {
+		return masters, nil
+	}
+	skipped := 0
+	err := s.index.jettonMasters(nil, func(master core.JettonMaster) bool {
+		if skipped < offset {
+			skipped++
+			return true
+		}
+		masters = append(masters, master)
+		return len(masters) < limit
+	})
+	return masters, err
+}
+
+func (s *LiteStorage) GetJettonMastersByAddresses(ctx context.Context, addresses []ton.AccountID) ([]core.JettonMaster, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_jetton_masters_by_addresses").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	masters := make([]core.JettonMaster, 0, len(addresses))
+	for _, a := range addresses {
+		master, err := s.index.jettonMaster(a)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		masters = append(masters, master)
+	}
+	return masters, nil
+}
+
+func (s *LiteStorage) GetJettonsHoldersCount(ctx context.Context, accountIDs []tongo.AccountID) (map[tongo.AccountID]int32, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_jettons_holders_count").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	counts := make(map[tongo.AccountID]int32, len(accountIDs))
+	for _, a := range accountIDs {
+		if _, ok := s.jettonMasters.Load(a); ok {
+			counts[a] = int32(s.index.jettonHoldersCount(a))
+		}
+	}
+	return counts, nil
+}
+
+func (s *LiteStorage) GetJettonHoldersByBalance(ctx context.Context, jettonMaster tongo.AccountID, limit, offset int) ([]core.JettonHolder, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_jetton_holders_by_balance").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if _, ok := s.jettonMasters.Load(jettonMaster); !ok {
+		return nil, core.ErrEntityNotFound
+	}
+	holders := []core.JettonHolder{}
+	if limit <= 0 {
+		return holders, nil
+	}
+	wallets, err := s.index.jettonWalletsByBalance(jettonMaster, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range wallets {
+		holder, err := s.index.jettonHolder(jettonMaster, w)
+		if err != nil {
+			return nil, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, nil
+}
+
+func (s *LiteStorage) GetJettonHoldersByAddress(ctx context.Context, jettonMaster tongo.AccountID, limit int, lastAccountID *tongo.AccountID) ([]core.JettonHolder, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_jetton_holders_by_address").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if _, ok := s.jettonMasters.Load(jettonMaster); !ok {
+		return nil, core.ErrEntityNotFound
+	}
+	holders := []core.JettonHolder{}
+	if limit <= 0 {
+		return holders, nil
+	}
+	err := s.index.jettonHolders(jettonMaster, lastAccountID, func(holder core.JettonHolder) bool {
+		holders = append(holders, holder)
+		return len(holders) < limit
+	})
+	return holders, err
+}
+
+// loadJettonMasters fills the in-memory set of registered masters from the index.
+func (s *LiteStorage) loadJettonMasters() error {
+	return s.index.store.Range(jettonMastersBucket, nil, nil, false, func(key, value []byte) bool {
+		if len(key) == 36 {
+			s.jettonMasters.Store(accountFromKey(key), struct{}{})
+		}
+		return true
+	})
+}
@@ -0,0 +1,395 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/shopspring/decimal"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+	"github.com/tonkeeper/opentonapi/pkg/references"
+)
+
+const (
+	// liquidStakingQueueSize is a number of accounts waiting to be checked by the liquid staking indexer.
+	liquidStakingQueueSize = 10_000
+	// notLiquidPoolsCacheSize limits the number of accounts we remember as not being liquid pools.
+	notLiquidPoolsCacheSize = 10_000
+)
+
+// liquidStakingUpdate is either a possible liquid pool, a withdrawal payout NFT minted for a pool member,
+// or a payout NFT burnt once the member has been paid.
+type liquidStakingUpdate struct {
+	account tongo.AccountID
+	payout  *liquidPayout
+	burnt   bool
+}
+
+// liquidPayout is a withdrawal payout NFT.
+// Its owner receives TON for the burnt pool jettons at the end of the current round.
+type liquidPayout struct {
+	collection tongo.AccountID
+	owner      tongo.AccountID
+	amount     uint64
+}
+
+func (s *LiteStorage) enqueueLiquidStakingUpdates(workchain int32, txs []*tlb.Transaction) {
+	for _, tx := range txs {
+		update := liquidStakingUpdate{account: *ton.NewAccountID(workchain, tx.AccountAddr)}
+		op, ok := inMsgOpCode(tx)
+		switch {
+		case tx.EndStatus == tlb.AccountNone && tx.OrigStatus != tlb.AccountNone:
+			if !s.index.isLiquidPayout(update.account) {
+				continue
+			}
+			update.burnt = true
+		case !ok:
+			continue
+		case op == abi.TonstakePoolDepositMsgOpCode, op == abi.TonstakePoolWithdrawMsgOpCode:
+			if _, ok := s.liquidPools.Load(update.account); ok {
+				continue
+			}
+			if _, ok := s.notLiquidPools.Get(update.account); ok {
+				continue
+			}
+		case op == abi.TonstakeNftInitMsgOpCode:
+			payout, ok := decodeLiquidPayout(tx)
+			if !ok {
+				continue
+			}
+			update.payout = &payout
+		default:
+			continue
+		}
+		if update.burnt || update.payout != nil {
+			// payouts are rare and can't be recovered later, so they wait for the indexer.
+			s.liquidStakingQueue <- update
+			continue
+		}
+		select {
+		case s.liquidStakingQueue <- update:
+		default:
+			// we don't want to slow down block processing, a pool is checked again with its next deposit.
+		}
+	}
+}
+
+// decodeLiquidPayout decodes a message sent by a payout collection to initialize a new payout NFT.
+func decodeLiquidPayout(tx *tlb.Transaction) (liquidPayout, bool) {
+	msg := tx.Msgs.InMsg.Value.Value
+	collection, err := ton.AccountIDFromTlb(msg.Info.IntMsgInfo.Src)
+	if err != nil || collection == nil {
+		return liquidPayout{}, false
+	}
+	body := boc.Cell(msg.Body.Value)
+	body.ResetCounters()
+	if _, err := body.ReadUint(32); err != nil {
+		return liquidPayout{}, false
+	}
+	var init abi.TonstakeNftInitMsgBody
+	if err := tlb.Unmarshal(&body, &init); err != nil {
+		return liquidPayout{}, false
+	}
+	owner, err := ton.AccountIDFromTlb(init.Owner)
+	if err != nil || owner == nil {
+		return liquidPayout{}, false
+	}
+	return liquidPayout{collection: *collection, owner: *owner, amount: uint64(init.Amount)}, true
+}
+
+func (s *LiteStorage) runLiquidStakingIndexer() {
+	ctx := context.Background()
+	if _, err := s.checkLiquidPool(ctx, references.TFLiquidPool); err != nil {
+		s.logger.Warn("failed to check liquid pool", zap.String("accountID", references.TFLiquidPool.String()), zap.Error(err))
+	}
+	for update := range s.liquidStakingQueue {
+		var err error
+		switch {
+		case update.burnt:
+			err = s.index.deleteLiquidPayout(update.account)
+		case update.payout != nil:
+			err = s.storeLiquidPayout(ctx, update.account, *update.payout)
+		default:
+			_, err = s.checkLiquidPool(ctx, update.account)
+		}
+		if err != nil {
+			s.logger.Warn("failed to update liquid staking index", zap.String("accountID", update.account.String()), zap.Error(err))
+		}
+	}
+}
+
+// checkLiquidPool registers the account as a liquid pool if it has the code of a known liquid pool.
+// Any contract can implement the liquid pool's get-methods, so they aren't enough to list the account as a pool.
+func (s *LiteStorage) checkLiquidPool(ctx context.Context, a tongo.AccountID) (bool, error) {
+	if _, ok := s.liquidPools.Load(a); ok {
+		return true, nil
+	}
+	if _, ok := s.notLiquidPools.Get(a); ok {
+		return false, nil
+	}
+	account, err := s.GetRawAccount(ctx, a)
+	if err != nil {
+		return false, err
+	}
+	isPool := false
+	if account.Status == tlb.AccountActive {
+		hash, err := codeHash(account.Code)
+		if err != nil {
+			return false, err
+		}
+		isPool = slices.Contains(references.LiquidPoolCodeHashes, hash)
+	}
+	if !isPool {
+		s.notLiquidPools.Set(a, struct{}{})
+		return false, nil
+	}
+	// the minter lets the jetton registry recognize members holding pool jettons.
+	data, err := s.liquidPoolData(ctx, a)
+	if err != nil {
+		return false, err
+	}
+	minter, err := tongo.AccountIDFromTlb(data.JettonMinter)
+	if err != nil || minter == nil {
+		return false, err
+	}
+	if err := s.index.store.Put(liquidPoolsBucket, accountKey(a), accountKey(*minter)); err != nil {
+		return false, err
+	}
+	s.liquidPools.Store(a, *minter)
+	s.liquidPoolMinters.Store(*minter, a)
+	return true, nil
+}
+
+func (s *LiteStorage) liquidPoolData(ctx context.Context, pool tongo.AccountID) (abi.GetPoolFullDataResult, error) {
+	_, value, err := abi.GetPoolFullData(ctx, s.executor, pool)
+	if err != nil {
+		return abi.GetPoolFullDataResult{}, err
+	}
+	data, ok := value.(abi.GetPoolFullDataResult)
+	if !ok {
+		return abi.GetPoolFullDataResult{}, errors.New("invalid get_pool_full_data result")
+	}
+	return data, nil
+}
+
+// loadLiquidPools fills the in-memory sets of known pools from the index.
+func (s *LiteStorage) loadLiquidPools() error {
+	return s.index.store.Range(liquidPoolsBucket, nil, nil, false, func(key, value []byte) bool {
+		pool, minter := accountFromKey(key), accountFromKey(value)
+		s.liquidPools.Store(pool, minter)
+		s.liquidPoolMinters.Store(minter, pool)
+		return true
+	})
+}
+
+// addLiquidPoolHolder remembers the owner of a jetton wallet as a member of the liquid pool issuing the jetton.
+func (s *LiteStorage) addLiquidPoolHolder(holder core.JettonHolder) error {
+	pool, ok := s.liquidPoolMinters.Load(holder.JettonAddress)
+	if !ok || holder.Owner == nil || !holder.Balance.IsPositive() {
+		return nil
+	}
+	return s.index.addLiquidMember(*holder.Owner, pool)
+}
+
+// knownLiquidPools returns discovered liquid pools sorted by address.
+func (s *LiteStorage) knownLiquidPools() []tongo.AccountID {
+	var pools []tongo.AccountID
+	s.liquidPools.Range(func(a tongo.AccountID, _ tongo.AccountID) bool {
+		pools = append(pools, a)
+		return true
+	})
+	slices.SortFunc(pools, func(a, b tongo.AccountID) int {
+		return strings.Compare(a.ToRaw(), b.ToRaw())
+	})
+	return pools
+}
+
+// storeLiquidPayout keeps a payout NFT minted by the current payout collection of a known liquid pool.
+func (s *LiteStorage) storeLiquidPayout(ctx context.Context, item tongo.AccountID, payout liquidPayout) error {
+	_, value, err := abi.GetCollectionData(ctx, s.executor, payout.collection)
+	if err != nil && isGetMethodFailure(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, ok := value.(abi.GetCollectionDataResult)
+	if !ok {
+		return nil
+	}
+	pool, err := tongo.AccountIDFromTlb(data.OwnerAddress)
+	if err != nil || pool == nil {
+		return err
+	}
+	if ok, err := s.checkLiquidPool(ctx, *pool); err != nil || !ok {
+		return err
+	}
+	// anybody can deploy a collection owned by a pool, so the pool has to confirm it.
+	poolData, err := s.liquidPoolData(ctx, *pool)
+	if err != nil {
+		return err
+	}
+	if poolData.WithdrawalPayout == nil || !msgAddressEquals(*poolData.WithdrawalPayout, payout.collection) {
+		return nil
+	}
+	if err := s.index.putLiquidPayout(payout.owner, *pool, item, payout.amount); err != nil {
+		return err
+	}
+	return s.index.addLiquidMember(payout.owner, *pool)
+}
+
+func liquidPayoutKey(member, pool, item tongo.AccountID) []byte {
+	key := append(accountKey(member), accountKey(pool)...)
+	return append(key, accountKey(item)...)
+}
+
+func (i *index) putLiquidPayout(member, pool, item tongo.AccountID, amount uint64) error {
+	if err := i.store.Put(liquidPayoutItemsBucket, accountKey(item), append(accountKey(member), accountKey(pool)...)); err != nil {
+		return err
+	}
+	return i.store.Put(liquidPayoutsBucket, liquidPayoutKey(member, pool, item), binary.BigEndian.AppendUint64(nil, amount))
+}
+
+// isLiquidPayout reports whether the account is a known payout NFT.
+func (i *index) isLiquidPayout(item tongo.AccountID) bool {
+	_, err := i.store.Get(liquidPayoutItemsBucket, accountKey(item))
+	return err == nil
+}
+
+// deleteLiquidPayout forgets the payout NFT, if it is known.
+func (i *index) deleteLiquidPayout(item tongo.AccountID) error {
+	value, err := i.store.Get(liquidPayoutItemsBucket, accountKey(item))
+	if errors.Is(err, kv.ErrNotFound) || (err == nil && len(value) != 72) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	member, pool := accountFromKey(value[:36]), accountFromKey(value[36:])
+	if err := i.store.Delete(liquidPayoutsBucket, liquidPayoutKey(member, pool, item)); err != nil {
+		return err
+	}
+	return i.store.Delete(liquidPayoutItemsBucket, accountKey(item))
+}
+
+func (i *index) addLiquidMember(member, pool tongo.AccountID) error {
+	return i.store.Put(liquidMembersBucket, append(accountKey(member), accountKey(pool)...), []byte{1})
+}
+
+// memberLiquidPools returns pools where the account has been seen holding pool jettons or payout NFTs.
+func (i *index) memberLiquidPools(member tongo.AccountID) ([]tongo.AccountID, error) {
+	return i.accountsByPrefix(liquidMembersBucket, accountKey(member))
+}
+
+// liquidPayouts returns payout NFTs of the member in the pool with amounts of pool jettons they were minted for.
+func (i *index) liquidPayouts(member, pool tongo.AccountID) (map[tongo.AccountID]uint64, error) {
+	prefix := append(accountKey(member), accountKey(pool)...)
+	payouts := make(map[tongo.AccountID]uint64)
+	err := i.store.Range(liquidPayoutsBucket, prefix, kv.PrefixEnd(prefix), false, func(key, value []byte) bool {
+		if len(value) == 8 {
+			payouts[accountFromKey(key[len(prefix):])] = binary.BigEndian.Uint64(value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payouts, nil
+}
+
+// pendingLiquidWithdrawal returns the amount of pool jettons the member waits to withdraw from the pool.
+// Payout NFTs burnt after the member has been paid are removed by the liquid staking indexer.
+func (s *LiteStorage) pendingLiquidWithdrawal(member, pool tongo.AccountID) (uint64, error) {
+	payouts, err := s.index.liquidPayouts(member, pool)
+	if err != nil {
+		return 0, err
+	}
+	var pending uint64
+	for _, amount := range payouts {
+		pending += amount
+	}
+	return pending, nil
+}
+
+// poolJettonsToTon converts pool jettons to nanotons at the current rate of the pool.
+func poolJettonsToTon(amount decimal.Decimal, pool abi.GetPoolFullDataResult) int64 {
+	if pool.Supply <= 0 {
+		return amount.IntPart()
+	}
+	return amount.Mul(decimal.NewFromInt(pool.TotalBalance)).Div(decimal.NewFromInt(pool.Supply)).IntPart()
+}
+
+func (s *LiteStorage) GetLiquidPools(ctx context.Context, onlyVerified bool) ([]core.LiquidPool, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_liquid_pools").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	var result []core.LiquidPool
+	for _, a := range s.knownLiquidPools() {
+		pool, err := s.GetLiquidPool(ctx, a)
+		if err != nil {
+			continue
+		}
+		if onlyVerified && !pool.VerifiedSources {
+			continue
+		}
+		result = append(result, pool)
+	}
+	return result, nil
+}
+
+// GetParticipatingInLiquidPools returns liquid pools the member holds pool jettons of or waits to withdraw from.
+// Both amounts are converted to nanotons at the current rate of the pool.
+// Only pools the member has been seen in by the jetton registry or the liquid staking indexer are checked.
+func (s *LiteStorage) GetParticipatingInLiquidPools(ctx context.Context, member tongo.AccountID) ([]core.Nominator, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_participating_in_liquid_pools").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	pools, err := s.index.memberLiquidPools(member)
+	if err != nil {
+		return nil, err
+	}
+	var result []core.Nominator
+	for _, a := range pools {
+		minter, ok := s.liquidPools.Load(a)
+		if !ok {
+			continue
+		}
+		data, err := s.liquidPoolData(ctx, a)
+		if err != nil {
+			continue
+		}
+		balance, err := s.jettonBalance(ctx, member, minter)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			balance = decimal.Zero
+		} else if err != nil {
+			return nil, err
+		}
+		pending, err := s.pendingLiquidWithdrawal(member, a)
+		if err != nil {
+			return nil, err
+		}
+		if balance.IsZero() && pending == 0 {
+			continue
+		}
+		result = append(result, core.Nominator{
+			Pool:                  a,
+			Member:                member,
+			MemberBalance:         poolJettonsToTon(balance, data),
+			MemberPendingWithdraw: poolJettonsToTon(decimal.NewFromInt(int64(pending)), data),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package litestorage
+
+import (
+	"testing"
+
+	"github.com/puzpuzpuz/xsync/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestIndex_liquidPayouts(t *testing.T) {
+	member := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	pool := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	otherPool := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+	item1 := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{10}}
+	item2 := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{11}}
+	item3 := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{12}}
+	i := newIndex(kv.NewMemoryStore())
+	require.Nil(t, i.putLiquidPayout(member, pool, item1, 100))
+	require.Nil(t, i.putLiquidPayout(member, pool, item2, 50))
+	require.Nil(t, i.putLiquidPayout(member, otherPool, item3, 7))
+
+	payouts, err := i.liquidPayouts(member, pool)
+	require.Nil(t, err)
+	require.Equal(t, map[tongo.AccountID]uint64{item1: 100, item2: 50}, payouts)
+
+	// burnt payout NFTs are removed by their address, unknown accounts are ignored.
+	require.Nil(t, i.deleteLiquidPayout(item1))
+	require.Nil(t, i.deleteLiquidPayout(member))
+	require.False(t, i.isLiquidPayout(item1))
+	require.True(t, i.isLiquidPayout(item2))
+	payouts, err = i.liquidPayouts(member, pool)
+	require.Nil(t, err)
+	require.Equal(t, map[tongo.AccountID]uint64{item2: 50}, payouts)
+	payouts, err = i.liquidPayouts(member, otherPool)
+	require.Nil(t, err)
+	require.Equal(t, map[tongo.AccountID]uint64{item3: 7}, payouts)
+
+	require.Nil(t, i.addLiquidMember(member, pool))
+	require.Nil(t, i.addLiquidMember(member, otherPool))
+	require.Nil(t, i.addLiquidMember(pool, otherPool))
+	pools, err := i.memberLiquidPools(member)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{pool, otherPool}, pools)
+
+	// holders of pool jettons found by the jetton registry become members of the pool.
+	minter := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{20}}
+	holder := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{21}}
+	storage := &LiteStorage{index: i, liquidPoolMinters: xsync.NewTypedMapOf[tongo.AccountID, tongo.AccountID](hashAccountID)}
+	storage.liquidPoolMinters.Store(minter, pool)
+	require.Nil(t, storage.addLiquidPoolHolder(core.JettonHolder{JettonAddress: minter, Owner: &holder, Balance: decimal.Zero}))
+	require.Nil(t, storage.addLiquidPoolHolder(core.JettonHolder{JettonAddress: pool, Owner: &holder, Balance: decimal.NewFromInt(1)}))
+	pools, err = i.memberLiquidPools(holder)
+	require.Nil(t, err)
+	require.Empty(t, pools)
+	require.Nil(t, storage.addLiquidPoolHolder(core.JettonHolder{JettonAddress: minter, Owner: &holder, Balance: decimal.NewFromInt(1)}))
+	pools, err = i.memberLiquidPools(holder)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{pool}, pools)
+
+	data := abi.GetPoolFullDataResult{TotalBalance: 1_100, Supply: 1_000}
+	require.Equal(t, int64(110), poolJettonsToTon(decimal.NewFromInt(100), data))
+	require.Equal(t, int64(100), poolJettonsToTon(decimal.NewFromInt(100), abi.GetPoolFullDataResult{}))
+}
@@ -10,6 +10,7 @@ import (
 	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"maps"
@@ -23,15 +24,19 @@ import (
 	"github.com/tonkeeper/tongo/abi"
 	"github.com/tonkeeper/tongo/boc"
 	"github.com/tonkeeper/tongo/liteapi"
+	"github.com/tonkeeper/tongo/liteclient"
 	"github.com/tonkeeper/tongo/tep64"
 	"github.com/tonkeeper/tongo/tlb"
 	"github.com/tonkeeper/tongo/ton"
 	"github.com/tonkeeper/tongo/wallet"
 	"go.uber.org/zap"
 
+	"github.com/tonkeeper/opentonapi/pkg/blockchain"
 	"github.com/tonkeeper/opentonapi/pkg/blockchain/indexer"
+	"github.com/tonkeeper/opentonapi/pkg/blockchain/traces"
 	"github.com/tonkeeper/opentonapi/pkg/cache"
 	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
 	"github.com/tonkeeper/opentonapi/pkg/pyth"
 )
 
@@ -44,40 +49,75 @@ var storageTimeHistogramVec = promauto.NewHistogramVec(
 	[]string{"method"},
 )
 
-// inMsgCreatedLT is used as a key to look up a transaction's hash based on in msg's account and created lt.
-type inMsgCreatedLT struct {
-	account tongo.AccountID
-	lt      uint64
-}
-
-func extractInMsgCreatedLT(accountID tongo.AccountID, tx *tlb.Transaction) (inMsgCreatedLT, bool) {
-	if tx.Msgs.InMsg.Exists && tx.Msgs.InMsg.Value.Value.Info.IntMsgInfo != nil {
-		return inMsgCreatedLT{account: accountID, lt: tx.Msgs.InMsg.Value.Value.Info.IntMsgInfo.CreatedLt}, true
-	}
-	return inMsgCreatedLT{}, false
-}
+// maxPreloadTransactions is a number of the latest transactions we load for a tracked account.
+const maxPreloadTransactions = 2000
 
 type PriceFeeds interface {
 	GetFeed(id string) (pyth.PriceFeedAttributes, bool)
 }
 
 type LiteStorage struct {
-	logger                  *zap.Logger
-	client                  *liteapi.Client
-	executor                abi.Executor
-	jettonMetaCache         *xsync.MapOf[string, tep64.Metadata]
-	transactionsIndexByHash *xsync.MapOf[tongo.Bits256, *core.Transaction]
-	transactionsByInMsgLT   *xsync.MapOf[inMsgCreatedLT, tongo.Bits256]
-	blockCache              *xsync.MapOf[tongo.BlockIDExt, *tlb.Block]
-	accountInterfacesCache  *xsync.MapOf[tongo.AccountID, []abi.ContractInterface]
+	logger          *zap.Logger
+	client          *liteapi.Client
+	executor        abi.Executor
+	jettonMetaCache *xsync.MapOf[string, tep64.Metadata]
+	// index contains transactions and block headers of tracked accounts.
+	// Depending on configuration, it is kept either in memory or on disk.
+	index      *index
+	blockCache cache.Cache[tongo.BlockIDExt, *tlb.Block]
+	// traceExtractors find jetton operations, auction bids, invoice payments and subscriptions in traces of tracked accounts.
+	traceExtractors traceExtractors
+	// traceIndexingWake wakes the trace indexer up when transactions are added to its queue.
+	traceIndexingWake chan struct{}
+	// indexedTraces contains recently processed traces, so we don't index them twice.
+	indexedTraces cache.Cache[tongo.Bits256, struct{}]
+	// jettonMasters contains jetton masters registered by the jetton registry,
+	// the registry keeps their data and holders up to date.
+	jettonMasters       *xsync.MapOf[tongo.AccountID, struct{}]
+	jettonRegistryQueue chan jettonRegistryUpdate
+	// jettonWallets maps a jetton wallet to its verified jetton master, nil means the account is not a jetton wallet.
+	jettonWallets cache.Cache[tongo.AccountID, *tongo.AccountID]
+	// nftCollections contains collections known to the NFT index.
+	nftCollections   *xsync.MapOf[tongo.AccountID, struct{}]
+	nftIndexingQueue chan tongo.AccountID
+	// nftTransferTraces contains traces to be checked for NFT transfers.
+	nftTransferTraces chan *core.Trace
+	notNftItems       cache.Cache[tongo.AccountID, struct{}]
+	// liquidPools maps known liquid staking pools to their jetton minters.
+	liquidPools *xsync.MapOf[tongo.AccountID, tongo.AccountID]
+	// liquidPoolMinters maps jetton minters of known liquid staking pools to the pools.
+	liquidPoolMinters  *xsync.MapOf[tongo.AccountID, tongo.AccountID]
+	liquidStakingQueue chan liquidStakingUpdate
+	notLiquidPools     cache.Cache[tongo.AccountID, struct{}]
+	// multisigQueue contains possible multisig contracts found in traces of tracked accounts.
+	multisigQueue chan tongo.AccountID
+	// preloadQueue contains accounts tracked at runtime waiting for their history to be loaded.
+	preloadQueue chan tongo.AccountID
+	// traceAssembler builds traces from new blocks, so we don't have to look for their transactions later.
+	traceAssembler *traces.Assembler
+	// walletPlugins contains plugins and extensions of wallets read at their last transactions.
+	walletPlugins          cache.Cache[tongo.AccountID, walletPlugins]
+	accountInterfacesCache *xsync.MapOf[tongo.AccountID, []abi.ContractInterface]
 	// tvmLibraryCache contains public tvm libraries.
 	// As a library is immutable, it's ok to cache it.
 	tvmLibraryCache cache.Cache[string, boc.Cell]
 	knownAccounts   map[string][]tongo.AccountID
 	// maxGoroutines specifies a number of goroutines used to perform some time-consuming operations.
 	maxGoroutines int
-	// trackingAccounts is a list of accounts we track. Defined with ACCOUNTS env variable.
+	// trackingMu protects trackingAccounts, trackingRules and rulesGeneration.
+	trackingMu sync.RWMutex
+	// trackingAccounts is a list of accounts we track.
+	// Defined with ACCOUNTS env variable and can be changed at runtime with TrackAccount/UntrackAccount.
 	trackingAccounts map[tongo.AccountID]struct{}
+	// trackingRules contains rules to find new accounts to track, the key is TrackingRule.String().
+	trackingRules   map[string]TrackingRule
+	rulesGeneration uint64
+	// rulesQueue contains untracked accounts from new blocks waiting to be checked against tracking rules.
+	rulesQueue chan tongo.AccountID
+	// notMatchingAccounts contains accounts already checked against the current set of rules.
+	notMatchingAccounts cache.Cache[tongo.AccountID, struct{}]
+	// notMatchingRules contains accounts checked against a particular rule by MatchesTrackingRule.
+	notMatchingRules cache.Cache[notMatchingRule, struct{}]
 	configCache      cache.Cache[int, ton.BlockchainConfig]
 
 	stopCh chan struct{}
@@ -89,6 +129,35 @@ type LiteStorage struct {
 	trimmedConfigBase64 string
 
 	pythPriceFeeds PriceFeeds
+
+	// reducedBlocks contains summaries of the latest blocks received from blockCh.
+	reducedBlocks *reducedBlocks
+	// emulatedTraces contains traces of messages emulated before they landed in the blockchain.
+	emulatedTraces *emulatedTraces
+	// persistEmulatedTraces is set if emulated traces are kept in the kv store as well.
+	persistEmulatedTraces bool
+	// reducedBlocksRetention is how long summaries of blocks are kept in the kv store,
+	// zero means they are kept in memory only.
+	reducedBlocksRetention time.Duration
+	// accountEventBuilder converts traces into events returned by GetMissedEvents.
+	accountEventBuilder atomic.Pointer[AccountEventBuilder]
+	// traceListener is notified about completed traces of tracked accounts.
+	traceListener atomic.Pointer[TraceListener]
+	// chainTraceListener is notified about all completed traces.
+	chainTraceListener atomic.Pointer[TraceListener]
+	// sentMessagesMu protects pendingSentMessages and serializes updates of sent messages.
+	sentMessagesMu sync.Mutex
+	// pendingSentMessages contains hashes of sent messages waiting to land in the blockchain.
+	pendingSentMessages map[tongo.Bits256]struct{}
+	// sentMessageStatuses receives sent messages once they are included or expired, if set.
+	sentMessageStatuses chan<- core.SentMessage
+	// sentMessageOutbox contains final statuses waiting to be sent to sentMessageStatuses,
+	// sentMessageOutboxWake is signalled when a status is added.
+	sentMessageOutboxMu   sync.Mutex
+	sentMessageOutbox     []core.SentMessage
+	sentMessageOutboxWake chan struct{}
+	// masterchainUtimes contains times of the latest masterchain blocks, it is accessed by run only.
+	masterchainUtimes []int64
 }
 
 func (s *LiteStorage) GetPythPriceFeedMeta(id string) (pyth.PriceFeedAttributes, bool) {
@@ -108,6 +177,17 @@ type Options struct {
 	// blockCh is used to receive new blocks in the blockchain, if set.
 	blockCh        <-chan indexer.IDandBlock
 	pythPriceFeeds PriceFeeds
+	// store keeps the index of tracked accounts, if set.
+	// Otherwise, the index lives in memory.
+	store                  kv.Store
+	trackingRules          []TrackingRule
+	traceExtractors        traceExtractors
+	reducedBlocksRetention time.Duration
+	emulatedTracesMaxSize  int64
+	persistEmulatedTraces  bool
+	// sentMessages is used to receive copies of messages sent to the blockchain, if set.
+	sentMessages        <-chan blockchain.ExtInMsgCopy
+	sentMessageStatuses chan<- core.SentMessage
 }
 
 func WithPythPriceFeeds(feeds PriceFeeds) Option {
@@ -147,6 +227,59 @@ func WithBlockChannel(ch <-chan indexer.IDandBlock) Option {
 	}
 }
 
+// WithKVStore configures a storage to keep the index of tracked accounts.
+// With a persistent store, LiteStorage doesn't need to preload accounts from scratch after a restart.
+func WithKVStore(store kv.Store) Option {
+	return func(o *Options) {
+		o.store = store
+	}
+}
+
+// WithTrackingRules configures rules to find new accounts to track in the blockchain.
+func WithTrackingRules(rules []TrackingRule) Option {
+	return func(o *Options) {
+		o.trackingRules = rules
+	}
+}
+
+// WithReducedBlocksRetention keeps summaries of blocks in the kv store for the given period,
+// so reduced blocks older than the in-memory buffer can be served.
+func WithReducedBlocksRetention(retention time.Duration) Option {
+	return func(o *Options) {
+		o.reducedBlocksRetention = retention
+	}
+}
+
+// WithEmulatedTracesMaxSize limits the total size of emulated traces kept in memory.
+func WithEmulatedTracesMaxSize(size int64) Option {
+	return func(o *Options) {
+		o.emulatedTracesMaxSize = size
+	}
+}
+
+// WithPersistentEmulatedTraces keeps emulated traces in the kv store as well,
+// so they survive restarts if the kv store is on disk.
+func WithPersistentEmulatedTraces(persistent bool) Option {
+	return func(o *Options) {
+		o.persistEmulatedTraces = persistent
+	}
+}
+
+// WithSentMessages configures a channel to receive copies of messages sent to the blockchain,
+// LiteStorage watches new blocks for their transactions, see GetSentMessage.
+func WithSentMessages(ch <-chan blockchain.ExtInMsgCopy) Option {
+	return func(o *Options) {
+		o.sentMessages = ch
+	}
+}
+
+// WithSentMessageStatusReceiver configures a channel to receive sent messages once they are included or expired.
+func WithSentMessageStatusReceiver(ch chan<- core.SentMessage) Option {
+	return func(o *Options) {
+		o.sentMessageStatuses = ch
+	}
+}
+
 type Option func(o *Options)
 
 func NewLiteStorage(log *zap.Logger, cli *liteapi.Client, opts ...Option) (*LiteStorage, error) {
@@ -157,6 +290,12 @@ func NewLiteStorage(log *zap.Logger, cli *liteapi.Client, opts ...Option) (*Lite
 	if o.executor == nil {
 		o.executor = cli
 	}
+	if o.store == nil {
+		o.store = kv.NewMemoryStore()
+	}
+	if o.emulatedTracesMaxSize <= 0 {
+		o.emulatedTracesMaxSize = defaultEmulatedTracesMaxSize
+	}
 	storage := &LiteStorage{
 		logger: log,
 		// TODO: introduce an env variable to configure this number
@@ -167,22 +306,90 @@ func NewLiteStorage(log *zap.Logger, cli *liteapi.Client, opts ...Option) (*Lite
 		// read-only data
 		knownAccounts:    make(map[string][]tongo.AccountID),
 		trackingAccounts: map[tongo.AccountID]struct{}{},
+		trackingRules:    map[string]TrackingRule{},
 		// data for concurrent access
 		// TODO: implement expiration logic for the caches below.
-		jettonMetaCache:         xsync.NewMapOf[tep64.Metadata](),
-		transactionsIndexByHash: xsync.NewTypedMapOf[tongo.Bits256, *core.Transaction](hashBits256),
-		transactionsByInMsgLT:   xsync.NewTypedMapOf[inMsgCreatedLT, tongo.Bits256](hashInMsgCreatedLT),
-		blockCache:              xsync.NewTypedMapOf[tongo.BlockIDExt, *tlb.Block](hashBlockIDExt),
-		accountInterfacesCache:  xsync.NewTypedMapOf[tongo.AccountID, []abi.ContractInterface](hashAccountID),
-		tvmLibraryCache:         cache.NewLRUCache[string, boc.Cell](10000, "tvm_libraries"),
-		configCache:             cache.NewLRUCache[int, ton.BlockchainConfig](4, "config"),
-		pythPriceFeeds:          o.pythPriceFeeds,
+		jettonMetaCache:        xsync.NewMapOf[tep64.Metadata](),
+		index:                  newIndex(o.store),
+		blockCache:             cache.NewLRUCache[tongo.BlockIDExt, *tlb.Block](1000, "lite_storage_blocks"),
+		traceAssembler:         traces.NewAssembler(log),
+		traceIndexingWake:      make(chan struct{}, 1),
+		indexedTraces:          cache.NewLRUCache[tongo.Bits256, struct{}](10_000, "lite_storage_indexed_traces"),
+		traceExtractors:        o.traceExtractors,
+		reducedBlocks:          newReducedBlocks(reducedBlocksBufferSize),
+		reducedBlocksRetention: o.reducedBlocksRetention,
+		emulatedTraces:         newEmulatedTraces(o.emulatedTracesMaxSize),
+		persistEmulatedTraces:  o.persistEmulatedTraces,
+		jettonMasters:          xsync.NewTypedMapOf[tongo.AccountID, struct{}](hashAccountID),
+		jettonRegistryQueue:    make(chan jettonRegistryUpdate, jettonRegistryQueueSize),
+		jettonWallets:          cache.NewLRUCache[tongo.AccountID, *tongo.AccountID](jettonWalletsCacheSize, "lite_storage_jetton_wallets"),
+		nftCollections:         xsync.NewTypedMapOf[tongo.AccountID, struct{}](hashAccountID),
+		nftIndexingQueue:       make(chan tongo.AccountID, nftIndexingQueueSize),
+		nftTransferTraces:      make(chan *core.Trace, nftTransferTracesQueueSize),
+		notNftItems:            cache.NewLRUCache[tongo.AccountID, struct{}](notNftItemsCacheSize, "lite_storage_not_nft_items"),
+		walletPlugins:          cache.NewLRUCache[tongo.AccountID, walletPlugins](walletPluginsCacheSize, "lite_storage_wallet_plugins"),
+		liquidPools:            xsync.NewTypedMapOf[tongo.AccountID, tongo.AccountID](hashAccountID),
+		liquidPoolMinters:      xsync.NewTypedMapOf[tongo.AccountID, tongo.AccountID](hashAccountID),
+		liquidStakingQueue:     make(chan liquidStakingUpdate, liquidStakingQueueSize),
+		notLiquidPools:         cache.NewLRUCache[tongo.AccountID, struct{}](notLiquidPoolsCacheSize, "lite_storage_not_liquid_pools"),
+		multisigQueue:          make(chan tongo.AccountID, multisigQueueSize),
+		preloadQueue:           make(chan tongo.AccountID, preloadQueueSize),
+		rulesQueue:             make(chan tongo.AccountID, rulesQueueSize),
+		accountInterfacesCache: xsync.NewTypedMapOf[tongo.AccountID, []abi.ContractInterface](hashAccountID),
+		tvmLibraryCache:        cache.NewLRUCache[string, boc.Cell](10000, "tvm_libraries"),
+		configCache:            cache.NewLRUCache[int, ton.BlockchainConfig](4, "config"),
+		notMatchingAccounts:    cache.NewLRUCache[tongo.AccountID, struct{}](notMatchingAccountsCacheSize, "lite_storage_not_matching_accounts"),
+		notMatchingRules:       cache.NewLRUCache[notMatchingRule, struct{}](notMatchingRulesCacheSize, "lite_storage_not_matching_rules"),
+		pythPriceFeeds:         o.pythPriceFeeds,
+		pendingSentMessages:    map[tongo.Bits256]struct{}{},
+		sentMessageStatuses:    o.sentMessageStatuses,
+		sentMessageOutboxWake:  make(chan struct{}, 1),
 	}
 	storage.knownAccounts["tf_pools"] = o.tfPools
 	storage.knownAccounts["jettons"] = o.jettons
 
-	for _, a := range o.preloadAccounts {
-		storage.trackingAccounts[a] = struct{}{}
+	preloadAccounts, err := storage.loadTrackedAccounts(o.preloadAccounts)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range o.trackingRules {
+		storage.trackingRules[rule.String()] = rule
+	}
+	rules, err := storage.index.trackingRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		rule, err := ParseTrackingRule(r)
+		if err != nil {
+			log.Warn("skipping invalid tracking rule", zap.String("rule", r), zap.Error(err))
+			continue
+		}
+		storage.trackingRules[r] = rule
+	}
+
+	if err := storage.loadJettonMasters(); err != nil {
+		return nil, err
+	}
+	if err := storage.loadNftCollections(); err != nil {
+		return nil, err
+	}
+	if err := storage.loadLiquidPools(); err != nil {
+		return nil, err
+	}
+	if err := storage.loadPendingSentMessages(); err != nil {
+		return nil, err
+	}
+
+	go storage.runTraceIndexer()
+	go storage.runJettonRegistry()
+	go storage.runNftIndexer()
+	go storage.runNftTransferFinder()
+	go storage.runMultisigIndexer()
+	go storage.runLiquidStakingIndexer()
+	go storage.runSentMessagesTracker(o.sentMessages)
+	if o.sentMessageStatuses != nil {
+		go storage.runSentMessageStatusSender()
 	}
 
 	blockIterator := iter.Iterator[tongo.BlockID]{MaxGoroutines: storage.maxGoroutines}
@@ -194,13 +401,20 @@ func NewLiteStorage(log *zap.Logger, cli *liteapi.Client, opts ...Option) (*Lite
 		}
 	})
 	iterator := iter.Iterator[tongo.AccountID]{MaxGoroutines: storage.maxGoroutines}
-	iterator.ForEach(o.preloadAccounts, func(accountID *tongo.AccountID) {
+	iterator.ForEach(preloadAccounts, func(accountID *tongo.AccountID) {
 		if err := storage.preloadAccount(*accountID); err != nil {
 			log.Error("failed to preload account",
 				zap.String("accountID", accountID.String()),
 				zap.Error(err))
 		}
 	})
+	for i := 0; i < storage.maxGoroutines; i++ {
+		go storage.runPreloader()
+		go storage.runRuleEvaluator()
+	}
+	if storage.reducedBlocksRetention > 0 {
+		go storage.runReducedBlocksPruner(reducedBlocksPruneInterval)
+	}
 	go storage.run(o.blockCh)
 	go storage.runBlockchainConfigUpdate(5 * time.Second)
 	return storage, nil
@@ -215,31 +429,112 @@ func (s *LiteStorage) Shutdown() {
 	s.stopCh <- struct{}{}
 }
 
+// LastIndexedMasterchainSeqno returns the seqno of the last masterchain block
+// processed by LiteStorage before the latest shutdown or restart.
+func (s *LiteStorage) LastIndexedMasterchainSeqno() (uint32, bool) {
+	return s.index.lastMasterchainSeqno()
+}
+
 func (s *LiteStorage) run(ch <-chan indexer.IDandBlock) {
 	if ch == nil {
 		return
 	}
 	for block := range ch {
+		// transactions are converted once and shared by the index, the sent messages tracker and the trace assembler.
+		transactions := make(map[tongo.Bits256]*core.Transaction)
+		var converted []*core.Transaction
 		for _, tx := range block.Block.AllTransactions() {
-			accountID := *ton.NewAccountID(block.ID.Workchain, tx.AccountAddr)
-			if _, ok := s.trackingAccounts[accountID]; ok {
-				hash := tongo.Bits256(tx.Hash())
-				transaction, err := core.ConvertTransaction(accountID.Workchain, tongo.Transaction{Transaction: *tx, BlockID: block.ID}, nil)
-				if err != nil {
-					s.logger.Error("failed to process tx",
-						zap.String("tx-hash", hash.Hex()),
-						zap.Error(err))
-					continue
-				}
-				s.transactionsIndexByHash.Store(hash, transaction)
-				if createLT, ok := extractInMsgCreatedLT(accountID, tx); ok {
-					s.transactionsByInMsgLT.Store(createLT, hash)
-				}
+			transaction, err := core.ConvertTransaction(block.ID.Workchain, tongo.Transaction{Transaction: *tx, BlockID: block.ID}, nil)
+			if err != nil {
+				s.logger.Error("failed to process tx",
+					zap.String("tx-hash", tongo.Bits256(tx.Hash()).Hex()),
+					zap.Error(err))
+				continue
+			}
+			transactions[transaction.Hash] = transaction
+			converted = append(converted, transaction)
+		}
+		untracked := map[tongo.AccountID]struct{}{}
+		for _, transaction := range converted {
+			if !s.isTracked(transaction.Account) {
+				untracked[transaction.Account] = struct{}{}
+			}
+		}
+		// accounts matching tracking rules become tracked in the background,
+		// and TrackAccount loads their history including transactions from this block.
+		s.enqueueRuleEvaluation(slices.Collect(maps.Keys(untracked)))
+		hasTracked := false
+		for _, transaction := range converted {
+			if !s.isTracked(transaction.Account) {
+				continue
+			}
+			hasTracked = true
+			if err := s.index.storeTransaction(transaction); err != nil {
+				s.logger.Error("failed to store tx",
+					zap.String("tx-hash", transaction.Hash.Hex()),
+					zap.Error(err))
+			}
+		}
+		s.enqueueJettonRegistryUpdates(block.ID.Workchain, block.Block.AllTransactions())
+		s.enqueueNftIndexing(block.ID.Workchain, block.Block.AllTransactions())
+		s.enqueueLiquidStakingUpdates(block.ID.Workchain, block.Block.AllTransactions())
+		s.checkSentMessages(block, transactions)
+		completed := s.traceAssembler.AddTransactions(converted)
+		s.enqueueNftTransfers(completed)
+		for _, trace := range completed {
+			if !s.involvesTrackedAccount(trace) {
+				continue
+			}
+			if err := s.index.storeTrace(trace); err != nil {
+				s.logger.Error("failed to store trace", zap.String("hash", trace.Hash.Hex()), zap.Error(err))
+				continue
+			}
+			if !s.traceExtractors.empty() {
+				s.enqueueTraceIndexing(trace.Hash)
+			}
+			core.Visit(trace, func(t *core.Trace) {
+				s.discoverMultisigs(&t.Transaction)
+			})
+			if listener := s.traceListener.Load(); listener != nil {
+				(*listener)(trace)
+			}
+		}
+		if listener := s.chainTraceListener.Load(); listener != nil {
+			for _, trace := range completed {
+				(*listener)(trace)
+			}
+		}
+		s.storeBlockHeader(block, hasTracked)
+		if block.ID.Workchain == -1 {
+			if err := s.index.setLastMasterchainSeqno(block.ID.Seqno); err != nil {
+				s.logger.Error("failed to save last masterchain seqno", zap.Error(err))
+			}
+			if utime, ok := s.expiryUtime(int64(block.Block.Info.GenUtime)); ok {
+				s.expireSentMessages(utime)
 			}
 		}
 	}
 }
 
+// storeBlockHeader keeps the header of a block with transactions of tracked accounts,
+// headers of other blocks are fetched on demand, so the index doesn't grow with the whole chain.
+// A summary of every block is kept for /v2/blockchain/reduced/blocks.
+func (s *LiteStorage) storeBlockHeader(block indexer.IDandBlock, hasTracked bool) {
+	header, err := core.ConvertToBlockHeader(block.ID, block.Block)
+	if err != nil {
+		s.logger.Error("failed to convert block header", zap.String("block", block.ID.String()), zap.Error(err))
+		return
+	}
+	if hasTracked {
+		if err := s.index.storeBlockHeader(header); err != nil {
+			s.logger.Error("failed to store block header", zap.String("block", block.ID.String()), zap.Error(err))
+		}
+	}
+	if err := s.addReducedBlock(header, block.Block); err != nil {
+		s.logger.Error("failed to store reduced block", zap.String("block", block.ID.String()), zap.Error(err))
+	}
+}
+
 func (s *LiteStorage) GetContract(ctx context.Context, id tongo.AccountID) (*core.Contract, error) {
 	account, err := s.GetRawAccount(ctx, id)
 	if err != nil {
@@ -306,30 +601,56 @@ func (s *LiteStorage) GetRawAccounts(ctx context.Context, ids []tongo.AccountID)
 	return accounts, nil
 }
 
+// preloadAccount loads the latest transactions of the given account into the index.
+// If the account has been preloaded before, it stops at the first transaction that is already indexed,
+// so a restart with a persistent index costs only a few requests per account.
 func (s *LiteStorage) preloadAccount(a tongo.AccountID) error {
 	ctx := context.Background()
-	accountTxs, err := s.client.GetLastTransactions(ctx, a, 2000)
+	account, err := s.GetRawAccount(ctx, a)
 	if err != nil {
 		return err
 	}
-	for _, tx := range accountTxs {
-		inspector := abi.NewContractInspector(abi.InspectWithLibraryResolver(s))
-		account, err := s.GetRawAccount(ctx, a)
-		if err != nil {
-			return err
+	inspector := abi.NewContractInspector(abi.InspectWithLibraryResolver(s))
+	cd, err := inspector.InspectContract(ctx, account.Code, s.executor, a)
+	if err != nil {
+		s.logger.Warn("failed to inspect contract", zap.String("accountID", a.String()), zap.Error(err))
+	}
+	preloaded := s.index.isPreloaded(a)
+	lastLt, lastHash := account.LastTransactionLt, account.LastTransactionHash
+	loaded := 0
+	for lastLt != 0 && loaded < maxPreloadTransactions {
+		if preloaded && s.index.hasTransaction(lastHash) {
+			// everything older than this transaction is already in the index.
+			break
 		}
-		cd, err := inspector.InspectContract(ctx, account.Code, s.executor, a)
-		t, err := core.ConvertTransaction(a.Workchain, tx, cd)
+		count := min(16, maxPreloadTransactions-loaded)
+		txs, err := s.client.GetTransactions(ctx, uint32(count), a, lastLt, lastHash)
 		if err != nil {
+			if e, ok := err.(liteclient.LiteServerErrorC); ok && int32(e.Code) == -400 {
+				// a lite server can keep only a part of the history.
+				break
+			}
 			return err
 		}
-		hash := tongo.Bits256(tx.Hash())
-		s.transactionsIndexByHash.Store(hash, t)
-		if createLT, ok := extractInMsgCreatedLT(a, &tx.Transaction); ok {
-			s.transactionsByInMsgLT.Store(createLT, hash)
+		if len(txs) == 0 {
+			break
+		}
+		for _, tx := range txs {
+			t, err := core.ConvertTransaction(a.Workchain, tx, cd)
+			if err != nil {
+				return err
+			}
+			if err := s.index.storeTransaction(t); err != nil {
+				return err
+			}
+			s.enqueueTraceIndexing(t.Hash)
+			s.discoverMultisigs(t)
 		}
+		loaded += len(txs)
+		last := txs[len(txs)-1]
+		lastLt, lastHash = last.PrevTransLt, tongo.Bits256(last.PrevTransHash)
 	}
-	return nil
+	return s.index.setPreloaded(a)
 }
 
 func (s *LiteStorage) preloadBlock(id tongo.BlockID) error {
@@ -342,7 +663,7 @@ func (s *LiteStorage) preloadBlock(id tongo.BlockID) error {
 	if err != nil {
 		return err
 	}
-	s.blockCache.Store(extID, &block)
+	s.blockCache.Set(extID, &block)
 	errs := []error{}
 	for _, tx := range block.AllTransactions() {
 		accountID := tongo.AccountID{
@@ -365,10 +686,8 @@ func (s *LiteStorage) preloadBlock(id tongo.BlockID) error {
 			errs = append(errs, err)
 			continue
 		}
-		hash := tongo.Bits256(tx.Hash())
-		s.transactionsIndexByHash.Store(hash, t)
-		if createLT, ok := extractInMsgCreatedLT(accountID, tx); ok {
-			s.transactionsByInMsgLT.Store(createLT, hash)
+		if err := s.index.storeTransaction(t); err != nil {
+			errs = append(errs, err)
 		}
 	}
 	return errors.Join(errs...)
@@ -379,6 +698,9 @@ func (s *LiteStorage) GetBlockHeader(ctx context.Context, id tongo.BlockID) (*co
 		storageTimeHistogramVec.WithLabelValues("get_block_header").Observe(v)
 	}))
 	defer timer.ObserveDuration()
+	if header, err := s.index.getBlockHeader(id); err == nil {
+		return header, nil
+	}
 	blockID, _, err := s.client.LookupBlock(ctx, id, 1, nil, nil)
 	if err != nil {
 		return nil, err
@@ -388,7 +710,8 @@ func (s *LiteStorage) GetBlockHeader(ctx context.Context, id tongo.BlockID) (*co
 		return nil, err
 	}
 
-	s.blockCache.Store(blockID, &block)
+	// the block is cached in memory only, the index keeps headers of blocks with tracked transactions.
+	s.blockCache.Set(blockID, &block)
 	header, err := core.ConvertToBlockHeader(blockID, &block)
 	if err != nil {
 		return nil, err
@@ -436,11 +759,14 @@ func (s *LiteStorage) GetTransaction(ctx context.Context, hash tongo.Bits256) (*
 		storageTimeHistogramVec.WithLabelValues("get_transaction").Observe(v)
 	}))
 	defer timer.ObserveDuration()
-	tx, prs := s.transactionsIndexByHash.Load(hash)
-	if prs {
+	tx, err := s.index.getTransaction(hash)
+	if err == nil {
 		return tx, nil
 	}
-	return nil, fmt.Errorf("not found tx %x", hash)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		return nil, fmt.Errorf("not found tx %x", hash)
+	}
+	return nil, err
 }
 
 func (s *LiteStorage) SearchTransactionByMessageHash(ctx context.Context, hash tongo.Bits256) (*tongo.Bits256, error) {
@@ -477,12 +803,8 @@ func (s *LiteStorage) GetBlockTransactions(ctx context.Context, id tongo.BlockID
 }
 
 func (s *LiteStorage) searchTxInCache(a tongo.AccountID, lt uint64) *core.Transaction {
-	hash, ok := s.transactionsByInMsgLT.Load(inMsgCreatedLT{account: a, lt: lt})
-	if !ok {
-		return nil
-	}
-	tx, ok := s.transactionsIndexByHash.Load(hash)
-	if !ok {
+	tx, err := s.index.transactionByInMsgLT(a, lt)
+	if err != nil {
 		return nil
 	}
 	return tx
@@ -619,33 +941,6 @@ func (s *LiteStorage) GetDnsExpiring(ctx context.Context, id tongo.AccountID, pe
 	return nil, nil
 }
 
-func (s *LiteStorage) GetReducedBlocks(ctx context.Context, from, to int64) ([]core.ReducedBlock, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-func (s *LiteStorage) GetAccountMultisigs(ctx context.Context, accountID ton.AccountID) ([]core.Multisig, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-func (s *LiteStorage) GetMultisigByID(ctx context.Context, accountID ton.AccountID) (*core.Multisig, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-func (s *LiteStorage) GetMultisigOrderByID(ctx context.Context, accountID ton.AccountID) (*core.MultisigOrder, error) {
-	return nil, fmt.Errorf("not implemented")
-}
-
-func (s *LiteStorage) SaveTraceWithState(ctx context.Context, msgHash string, trace *core.Trace, version int, getMethods []abi.MethodInvocation, ttl time.Duration) error {
-	return fmt.Errorf("not implemented")
-}
-
-func (s *LiteStorage) GetTraceWithState(ctx context.Context, msgHash string) (*core.Trace, int, []abi.MethodInvocation, error) {
-	return nil, 0, nil, fmt.Errorf("not implemented")
-}
-
-func (s *LiteStorage) SaveEmulationError(ctx context.Context, msg *boc.Cell, msgHash string, err error) error {
-	return fmt.Errorf("not implemented")
-}
-
 func (s *LiteStorage) GetBlockchainBlock(ctx context.Context, id ton.BlockID) ([]byte, error) {
 	idExt, _, err := s.client.LookupBlock(ctx, id, 1, nil, nil)
 	if err != nil {
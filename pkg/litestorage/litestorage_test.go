@@ -2,11 +2,11 @@ package litestorage
 
 import (
 	"context"
+	"encoding/hex"
 	"os"
 	"testing"
 	"time"
 
-	"github.com/puzpuzpuz/xsync/v2"
 	"github.com/stretchr/testify/require"
 	"github.com/tonkeeper/tongo"
 	"github.com/tonkeeper/tongo/boc"
@@ -15,7 +15,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/tonkeeper/opentonapi/pkg/blockchain/indexer"
-	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
 )
 
 func TestLiteStorage_run(t *testing.T) {
@@ -52,11 +52,11 @@ func TestLiteStorage_run(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			store := kv.NewMemoryStore()
 			s := &LiteStorage{
-				logger:                  zap.L(),
-				transactionsIndexByHash: xsync.NewTypedMapOf[tongo.Bits256, *core.Transaction](hashBits256),
-				transactionsByInMsgLT:   xsync.NewTypedMapOf[inMsgCreatedLT, tongo.Bits256](hashInMsgCreatedLT),
-				trackingAccounts:        tt.trackingAccounts,
+				logger:           zap.L(),
+				index:            newIndex(store),
+				trackingAccounts: tt.trackingAccounts,
 			}
 			ch := make(chan indexer.IDandBlock)
 			go s.run(ch)
@@ -72,10 +72,11 @@ func TestLiteStorage_run(t *testing.T) {
 			time.Sleep(time.Second)
 
 			txs := map[string]struct{}{}
-			s.transactionsIndexByHash.Range(func(key tongo.Bits256, value *core.Transaction) bool {
-				txs[key.Hex()] = struct{}{}
+			err = store.Range(transactionsBucket, nil, nil, false, func(key, value []byte) bool {
+				txs[hex.EncodeToString(key)] = struct{}{}
 				return true
 			})
+			require.Nil(t, err)
 			require.Equal(t, tt.wantTxHashes, txs)
 		})
 	}
@@ -1,11 +1,54 @@
 package litestorage
 
 import (
-	"github.com/tonkeeper/opentonapi/pkg/oas"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonkeeper/tongo"
 	"github.com/tonkeeper/tongo/ton"
-	"golang.org/x/net/context"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/oas"
 )
 
+// AccountEventBuilder converts a trace into an event of the account.
+// It lets the storage, webhooks and the pusher build events the same way the api does without importing the api package.
+type AccountEventBuilder func(ctx context.Context, account tongo.AccountID, traceID core.TraceID) oas.AccountEvent
+
+var errNoAccountEventBuilder = errors.New("account event builder is not configured")
+
+// SetAccountEventBuilder configures a builder of events returned by GetMissedEvents.
+// The api handler is created after the storage, so the builder can't be passed as an option.
+func (s *LiteStorage) SetAccountEventBuilder(builder AccountEventBuilder) {
+	s.accountEventBuilder.Store(&builder)
+}
+
+// GetMissedEvents returns events of a tracked account with LT above the given one, the oldest one first.
 func (s *LiteStorage) GetMissedEvents(ctx context.Context, account ton.AccountID, lt uint64, limit int) ([]oas.AccountEvent, error) {
-	return nil, nil
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_missed_events").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if limit <= 0 {
+		return nil, nil
+	}
+	builder := s.accountEventBuilder.Load()
+	if builder == nil {
+		return nil, errNoAccountEventBuilder
+	}
+	if !s.isTracked(account) {
+		return nil, fmt.Errorf("account %v is not tracked: %w", account, core.ErrEntityNotFound)
+	}
+	afterLT := int64(lt)
+	traceIDs, err := s.SearchTraces(ctx, account, limit, nil, &afterLT, nil, nil, false, false)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]oas.AccountEvent, 0, len(traceIDs))
+	for _, traceID := range traceIDs {
+		events = append(events, (*builder)(ctx, account, traceID))
+	}
+	return events, nil
 }
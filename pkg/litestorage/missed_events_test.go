@@ -0,0 +1,49 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+	"github.com/tonkeeper/opentonapi/pkg/oas"
+)
+
+func TestLiteStorage_GetMissedEvents(t *testing.T) {
+	account := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	other := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	storage := &LiteStorage{
+		logger:           zap.NewNop(),
+		index:            newIndex(kv.NewMemoryStore()),
+		trackingAccounts: map[tongo.AccountID]struct{}{account: {}},
+	}
+	ctx := context.Background()
+	_, err := storage.GetMissedEvents(ctx, account, 0, 10)
+	require.ErrorIs(t, err, errNoAccountEventBuilder)
+
+	storage.SetAccountEventBuilder(func(ctx context.Context, a tongo.AccountID, traceID core.TraceID) oas.AccountEvent {
+		return oas.AccountEvent{EventID: traceID.Hash.Hex(), Lt: int64(traceID.Lt)}
+	})
+	for _, lt := range []uint64{10, 20, 30} {
+		tx := &core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{byte(lt)}, Lt: lt, Account: account}, Utime: int64(lt)}
+		require.Nil(t, storage.index.storeTransaction(tx))
+		require.Nil(t, storage.index.setTraceRoot(tx.TransactionID, core.TraceID{Hash: tx.Hash, Lt: tx.Lt, UTime: tx.Utime}, account))
+	}
+	events, err := storage.GetMissedEvents(ctx, account, 10, 10)
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, int64(20), events[0].Lt)
+	require.Equal(t, int64(30), events[1].Lt)
+
+	events, err = storage.GetMissedEvents(ctx, account, 0, 1)
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, int64(10), events[0].Lt)
+
+	_, err = storage.GetMissedEvents(ctx, other, 0, 10)
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+}
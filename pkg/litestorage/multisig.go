@@ -0,0 +1,332 @@
+package litestorage
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+const (
+	// multisigQueueSize is a number of possible multisig contracts waiting to be checked.
+	multisigQueueSize = 1_000
+	// multisigOrdersLookback is a number of the latest order seqnos we check to find active orders of a multisig.
+	multisigOrdersLookback = 20
+)
+
+// multisigCandidates returns accounts of the transaction that look like multisig v2 contracts and their orders.
+// A multisig receives new_order from a signer or a proposer and sends order_init to an order contract.
+func multisigCandidates(tx *core.Transaction) (multisigs []tongo.AccountID, orders map[tongo.AccountID]tongo.AccountID) {
+	orders = map[tongo.AccountID]tongo.AccountID{}
+	if tx.InMsg != nil && tx.InMsg.OpCode != nil {
+		switch *tx.InMsg.OpCode {
+		case uint32(abi.MultisigNewOrderMsgOpCode):
+			multisigs = append(multisigs, tx.Account)
+		case uint32(abi.MultisigOrderInitMsgOpCode):
+			if tx.InMsg.Source != nil {
+				orders[tx.Account] = *tx.InMsg.Source
+			}
+		}
+	}
+	for _, m := range tx.OutMsgs {
+		if m.Destination == nil || m.OpCode == nil {
+			continue
+		}
+		switch *m.OpCode {
+		case uint32(abi.MultisigNewOrderMsgOpCode):
+			multisigs = append(multisigs, *m.Destination)
+		case uint32(abi.MultisigOrderInitMsgOpCode):
+			orders[*m.Destination] = tx.Account
+		}
+	}
+	return multisigs, orders
+}
+
+// discoverMultisigs looks for multisig contracts and their orders in transactions of tracked accounts.
+// Orders are indexed right away, multisigs are checked with get-methods in the background.
+func (s *LiteStorage) discoverMultisigs(txs ...*core.Transaction) {
+	for _, tx := range txs {
+		multisigs, orders := multisigCandidates(tx)
+		for order, multisig := range orders {
+			if err := s.index.addMultisigOrder(multisig, order); err != nil {
+				s.logger.Error("failed to index multisig order", zap.String("order", order.String()), zap.Error(err))
+			}
+		}
+		for _, a := range multisigs {
+			select {
+			case s.multisigQueue <- a:
+			default:
+				// the multisig is checked again with its next order.
+			}
+		}
+	}
+}
+
+func (s *LiteStorage) runMultisigIndexer() {
+	for a := range s.multisigQueue {
+		if err := s.indexMultisig(context.Background(), a); err != nil {
+			s.logger.Warn("failed to index multisig", zap.String("accountID", a.String()), zap.Error(err))
+		}
+	}
+}
+
+// indexMultisig adds the multisig to the lists of multisigs of its signers and proposers.
+func (s *LiteStorage) indexMultisig(ctx context.Context, a tongo.AccountID) error {
+	// members are all we need, so active orders aren't looked up.
+	multisig, err := s.multisigData(ctx, a)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, member := range multisigMembers(multisig) {
+		if err := s.index.addMultisigMember(member, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// multisigMembers returns signers and proposers of the multisig in a new slice.
+func multisigMembers(multisig *core.Multisig) []tongo.AccountID {
+	return slices.Concat(multisig.Signers, multisig.Proposers)
+}
+
+func accountIDs(addresses []tlb.MsgAddress) ([]tongo.AccountID, error) {
+	var accounts []tongo.AccountID
+	for _, address := range addresses {
+		a, err := ton.AccountIDFromTlb(address)
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			accounts = append(accounts, *a)
+		}
+	}
+	return accounts, nil
+}
+
+// multisigData returns the multisig without orders.
+func (s *LiteStorage) multisigData(ctx context.Context, a tongo.AccountID) (*core.Multisig, error) {
+	_, value, err := abi.GetMultisigData(ctx, s.executor, a)
+	if err != nil && isGetMethodFailure(err) {
+		return nil, core.ErrEntityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.(abi.GetMultisigDataResult)
+	if !ok {
+		return nil, core.ErrEntityNotFound
+	}
+	multisig := &core.Multisig{
+		AccountID: a,
+		Seqno:     big.Int(data.Seqno),
+		Threshold: int32(data.Threshold),
+	}
+	multisig.Signers, err = accountIDs(data.Signers.Signers.Values())
+	if err != nil {
+		return nil, err
+	}
+	if data.Proposers != nil {
+		multisig.Proposers, err = accountIDs(data.Proposers.Proposers.Values())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return multisig, nil
+}
+
+// activeOrders returns orders of the multisig which are neither executed nor expired.
+// It checks orders seen in traces of tracked accounts and the latest orders by their seqno.
+func (s *LiteStorage) activeOrders(ctx context.Context, multisig *core.Multisig) ([]core.MultisigOrder, error) {
+	candidates, err := s.index.multisigOrders(multisig.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	// next_order_seqno is -1 if the multisig allows arbitrary seqnos, then the indexed orders is all we have.
+	for i := int64(1); i <= multisigOrdersLookback && multisig.Seqno.Sign() > 0; i++ {
+		seqno := new(big.Int).Sub(&multisig.Seqno, big.NewInt(i))
+		if seqno.Sign() < 0 {
+			break
+		}
+		_, value, err := abi.GetOrderAddress(ctx, s.executor, multisig.AccountID, tlb.Int257(*seqno))
+		if err != nil {
+			return nil, err
+		}
+		result, ok := value.(abi.GetOrderAddressResult)
+		if !ok {
+			continue
+		}
+		if order, err := ton.AccountIDFromTlb(result.OrderAddress); err == nil && order != nil {
+			candidates = append(candidates, *order)
+		}
+	}
+	now := time.Now().Unix()
+	seen := map[tongo.AccountID]struct{}{}
+	var orders []core.MultisigOrder
+	for _, a := range candidates {
+		if _, ok := seen[a]; ok {
+			continue
+		}
+		seen[a] = struct{}{}
+		order, err := s.GetMultisigOrderByID(ctx, a)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			// the order hasn't been deployed or has been destroyed after execution.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if order.MultisigAccountID != multisig.AccountID || order.SentForExecution || order.ExpirationDate < now {
+			continue
+		}
+		orders = append(orders, *order)
+	}
+	return orders, nil
+}
+
+func (s *LiteStorage) GetAccountMultisigs(ctx context.Context, accountID ton.AccountID) ([]core.Multisig, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_account_multisigs").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	candidates, err := s.index.memberMultisigs(accountID)
+	if err != nil {
+		return nil, err
+	}
+	var multisigs []core.Multisig
+	for _, a := range candidates {
+		multisig, err := s.GetMultisigByID(ctx, a)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		member := false
+		for _, m := range multisigMembers(multisig) {
+			member = member || m == accountID
+		}
+		if !member {
+			// the account has been removed from the multisig.
+			if err := s.index.removeMultisigMember(accountID, a); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		multisigs = append(multisigs, *multisig)
+	}
+	return multisigs, nil
+}
+
+func (s *LiteStorage) GetMultisigByID(ctx context.Context, accountID ton.AccountID) (*core.Multisig, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_multisig_by_id").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	multisig, err := s.multisigData(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	multisig.Orders, err = s.activeOrders(ctx, multisig)
+	if err != nil {
+		return nil, err
+	}
+	return multisig, nil
+}
+
+func (s *LiteStorage) GetMultisigOrderByID(ctx context.Context, accountID ton.AccountID) (*core.MultisigOrder, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_multisig_order_by_id").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	_, value, err := abi.GetOrderData(ctx, s.executor, accountID)
+	if err != nil && isGetMethodFailure(err) {
+		return nil, core.ErrEntityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.(abi.GetOrderDataResult)
+	if !ok {
+		return nil, core.ErrEntityNotFound
+	}
+	multisig, err := ton.AccountIDFromTlb(data.MultisigAddress)
+	if err != nil {
+		return nil, err
+	}
+	if multisig == nil {
+		return nil, core.ErrEntityNotFound
+	}
+	order := &core.MultisigOrder{
+		AccountID:         accountID,
+		MultisigAccountID: *multisig,
+		OrderSeqno:        big.Int(data.OrderSeqno),
+		Threshold:         int32(data.Threshold),
+		SentForExecution:  data.SentForExecution,
+		ApprovalsMask:     approvalsMask(data.ApprovalsMask),
+		ApprovalsNum:      int32(data.ApprovalsNum),
+		ExpirationDate:    int64(data.ExpirationDate),
+	}
+	order.Signers, err = accountIDs(data.Signers.Values())
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range data.Order.Field0.Values() {
+		order.Actions = append(order.Actions, action.Value)
+	}
+	// get-methods don't return the creation date, so we take it from the order's first transaction if we have it.
+	if txs, err := s.index.accountTransactions(accountID, 0, 0, false, 1); err == nil && len(txs) > 0 {
+		if tx, err := s.index.getTransaction(txs[0].Hash); err == nil {
+			order.CreationDate = tx.Utime
+		}
+	}
+	return order, nil
+}
+
+// approvalsMask returns the bit mask of signers who approved an order as 32 big-endian bytes.
+func approvalsMask(mask tlb.Int256) []byte {
+	value := big.Int(mask)
+	if value.Sign() < 0 {
+		// the highest bit is set, so the mask is decoded as a negative number.
+		value.Add(&value, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return value.FillBytes(make([]byte, 32))
+}
+
+func multisigMemberKey(member, multisig tongo.AccountID) []byte {
+	return append(accountKey(member), accountKey(multisig)...)
+}
+
+func (i *index) addMultisigMember(member, multisig tongo.AccountID) error {
+	return i.store.Put(multisigMembersBucket, multisigMemberKey(member, multisig), []byte{1})
+}
+
+func (i *index) removeMultisigMember(member, multisig tongo.AccountID) error {
+	return i.store.Delete(multisigMembersBucket, multisigMemberKey(member, multisig))
+}
+
+// memberMultisigs returns multisigs where the account has been seen as a signer or a proposer.
+func (i *index) memberMultisigs(member tongo.AccountID) ([]tongo.AccountID, error) {
+	return i.accountsByPrefix(multisigMembersBucket, accountKey(member))
+}
+
+func (i *index) addMultisigOrder(multisig, order tongo.AccountID) error {
+	return i.store.Put(multisigOrdersBucket, multisigMemberKey(multisig, order), []byte{1})
+}
+
+func (i *index) multisigOrders(multisig tongo.AccountID) ([]tongo.AccountID, error) {
+	return i.accountsByPrefix(multisigOrdersBucket, accountKey(multisig))
+}
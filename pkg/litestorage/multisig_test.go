@@ -0,0 +1,86 @@
+package litestorage
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/tlb"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestMultisigCandidates(t *testing.T) {
+	signer := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	multisig := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	order := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+	op := func(code abi.MsgOpCode) *uint32 {
+		v := uint32(code)
+		return &v
+	}
+	// the signer sends new_order to the multisig.
+	signerTx := &core.Transaction{
+		TransactionID: core.TransactionID{Account: signer},
+		OutMsgs: []core.Message{
+			{MessageID: core.MessageID{Source: &signer, Destination: &multisig}, OpCode: op(abi.MultisigNewOrderMsgOpCode)},
+		},
+	}
+	multisigs, orders := multisigCandidates(signerTx)
+	require.Equal(t, []tongo.AccountID{multisig}, multisigs)
+	require.Empty(t, orders)
+
+	// the multisig deploys the order.
+	multisigTx := &core.Transaction{
+		TransactionID: core.TransactionID{Account: multisig},
+		InMsg:         &core.Message{MessageID: core.MessageID{Source: &signer, Destination: &multisig}, OpCode: op(abi.MultisigNewOrderMsgOpCode)},
+		OutMsgs: []core.Message{
+			{MessageID: core.MessageID{Source: &multisig, Destination: &order}, OpCode: op(abi.MultisigOrderInitMsgOpCode)},
+		},
+	}
+	multisigs, orders = multisigCandidates(multisigTx)
+	require.Equal(t, []tongo.AccountID{multisig}, multisigs)
+	require.Equal(t, map[tongo.AccountID]tongo.AccountID{order: multisig}, orders)
+
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore()), multisigQueue: make(chan tongo.AccountID, 10)}
+	storage.discoverMultisigs(signerTx, multisigTx)
+	require.Len(t, storage.multisigQueue, 2)
+	indexed, err := storage.index.multisigOrders(multisig)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{order}, indexed)
+
+	require.Nil(t, storage.index.addMultisigMember(signer, multisig))
+	members, err := storage.index.memberMultisigs(signer)
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{multisig}, members)
+	require.Nil(t, storage.index.removeMultisigMember(signer, multisig))
+	members, err = storage.index.memberMultisigs(signer)
+	require.Nil(t, err)
+	require.Empty(t, members)
+}
+
+func TestApprovalsMask(t *testing.T) {
+	mask := approvalsMask(tlb.Int256(*big.NewInt(0b101)))
+	require.Len(t, mask, 32)
+	require.Equal(t, byte(0b101), mask[31])
+
+	// the signer with index 255 approved the order.
+	negative := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 255))
+	mask = approvalsMask(tlb.Int256(*negative))
+	require.Equal(t, byte(0x80), mask[0])
+	require.Equal(t, byte(0), mask[31])
+}
+
+func TestMultisigMembers(t *testing.T) {
+	a, b, c := tongo.AccountID{Address: tongo.Bits256{1}}, tongo.AccountID{Address: tongo.Bits256{2}}, tongo.AccountID{Address: tongo.Bits256{3}}
+	signers := make([]tongo.AccountID, 1, 4)
+	signers[0] = a
+	multisig := &core.Multisig{Signers: signers, Proposers: []tongo.AccountID{b}}
+	require.Equal(t, []tongo.AccountID{a, b}, multisigMembers(multisig))
+	// proposers aren't written into the spare capacity of signers.
+	require.Equal(t, tongo.AccountID{}, signers[:2][1])
+	multisig.Proposers[0] = c
+	require.Equal(t, []tongo.AccountID{a, c}, multisigMembers(multisig))
+}
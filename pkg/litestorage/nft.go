@@ -13,31 +13,8 @@ import (
 	"github.com/tonkeeper/tongo/abi"
 	"github.com/tonkeeper/tongo/boc"
 	"github.com/tonkeeper/tongo/tep64"
-	"github.com/tonkeeper/tongo/ton"
 )
 
-func (s *LiteStorage) GetNFTs(ctx context.Context, accounts []tongo.AccountID) ([]core.NftItem, error) {
-	return nil, nil
-}
-
-func (s *LiteStorage) SearchNFTs(ctx context.Context,
-	collection *core.Filter[tongo.AccountID],
-	owner *core.Filter[tongo.AccountID],
-	includeOnSale bool,
-	onlyVerified bool,
-	limit, offset int,
-) ([]tongo.AccountID, error) {
-	return nil, nil
-}
-
-func (s *LiteStorage) GetNftCollections(ctx context.Context, limit, offset *int32) ([]core.NftCollection, error) {
-	return []core.NftCollection{}, nil
-}
-
-func (s *LiteStorage) GetNftCollectionsByAddresses(ctx context.Context, addresses []ton.AccountID) ([]core.NftCollection, error) {
-	return []core.NftCollection{}, nil
-}
-
 func (s *LiteStorage) GetNftCollectionByCollectionAddress(ctx context.Context, address tongo.AccountID) (core.NftCollection, error) {
 	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
 		storageTimeHistogramVec.WithLabelValues("get_nft_collection").Observe(v)
@@ -71,20 +48,26 @@ func (s *LiteStorage) GetNftCollectionByCollectionAddress(ctx context.Context, a
 		ContentLayout:     int(fullContent.Layout),
 		NextItemIndex:     g.Pointer(big.Int(source.NextItemIndex)).Int64(),
 	}
+	collection.Metadata, err = nftMetadata(fullContent)
+	if err != nil {
+		return core.NftCollection{}, err
+	}
+	return collection, nil
+}
+
+// nftMetadata returns metadata of an NFT item or collection, off-chain metadata is downloaded.
+func nftMetadata(fullContent tep64.FullContent) (map[string]interface{}, error) {
+	data := fullContent.Data
 	if fullContent.Layout == tep64.OffChain {
 		meta, err := core.GetNftMetaData(string(fullContent.Data))
 		if err != nil {
-			return core.NftCollection{}, err
+			return nil, err
 		}
-		var m map[string]interface{}
-		json.Unmarshal(meta, &m)
-		collection.Metadata = m
-	} else {
-		var m map[string]interface{}
-		json.Unmarshal(fullContent.Data, &m)
-		collection.Metadata = m
+		data = meta
 	}
-	return collection, nil
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	return m, nil
 }
 
 func (s *LiteStorage) NftSaleContracts(ctx context.Context, contracts []tongo.AccountID) (map[tongo.AccountID]core.NftSaleContract, error) {
@@ -0,0 +1,675 @@
+package litestorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/shopspring/decimal"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/liteapi"
+	"github.com/tonkeeper/tongo/tep64"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/blockchain/traces"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+var (
+	nftIndexUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lite_storage_nft_index_updates_total",
+		Help: "Number of NFT items checked by the NFT index",
+	}, []string{"result"})
+	nftTransferTraces = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lite_storage_nft_transfer_traces_total",
+		Help: "Number of traces checked for NFT transfers by the NFT index",
+	}, []string{"result"})
+)
+
+const (
+	// nftIndexingQueueSize is a number of possible NFT items waiting to be refreshed by the NFT index.
+	nftIndexingQueueSize = 10_000
+	// nftTransferTracesQueueSize is a number of traces waiting to be checked for NFT transfers.
+	nftTransferTracesQueueSize = 1_000
+	// notNftItemsCacheSize limits the number of accounts we remember as not being NFT items.
+	notNftItemsCacheSize = 100_000
+	// nftSearchBatchSize is a number of item addresses SearchNFTs reads from the index at once.
+	nftSearchBatchSize = 1000
+)
+
+// errNotNftItem means that an account doesn't implement get_nft_data.
+var errNotNftItem = errors.New("not an nft item")
+
+// isNotNftItem returns true if the error means that the account is not an NFT item, as opposed to a network failure.
+func isNotNftItem(err error) bool {
+	return errors.Is(err, errNotNftItem) || isGetMethodFailure(err)
+}
+
+// nftOwnership describes how an owner holds an NFT item in nftOwnerItemsBucket.
+const (
+	nftOwnedDirectly byte = 0
+	// nftOwnedOnSale means the item belongs to a sale contract put up by the owner.
+	nftOwnedOnSale byte = 1
+)
+
+// enqueueNftIndexing schedules refreshing of NFT items which have been minted in the given block.
+// An item is minted when it receives a message from a known collection.
+// The index learns about items only from new blocks, so items without recent activity are unknown to it.
+func (s *LiteStorage) enqueueNftIndexing(workchain int32, txs []*tlb.Transaction) {
+	for _, tx := range txs {
+		if s.isMintedNftItem(tx) {
+			s.enqueueNftItem(*ton.NewAccountID(workchain, tx.AccountAddr))
+		}
+	}
+}
+
+func (s *LiteStorage) enqueueNftItem(a tongo.AccountID) {
+	select {
+	case s.nftIndexingQueue <- a:
+	default:
+		// we don't want to slow down block processing, the item is refreshed with its next transfer.
+		nftIndexUpdates.WithLabelValues("dropped").Inc()
+	}
+}
+
+// enqueueNftTransfers schedules completed traces to be checked for NFT transfers.
+// Transfers are found by the NFT transfers extractor, so that failed and bounced transfers are noticed too,
+// and only traces with an nft_transfer message are worth running it.
+func (s *LiteStorage) enqueueNftTransfers(completed []*core.Trace) {
+	if s.traceExtractors.nftTransfers == nil {
+		return
+	}
+	for _, trace := range completed {
+		if !hasNftTransferMessage(trace) {
+			continue
+		}
+		select {
+		// the trace is shared with the trace listener, and the extractor sets account interfaces of its copy.
+		case s.nftTransferTraces <- traces.Clone(trace):
+		default:
+			nftTransferTraces.WithLabelValues("dropped").Inc()
+		}
+	}
+}
+
+func hasNftTransferMessage(trace *core.Trace) bool {
+	found := false
+	core.Visit(trace, func(t *core.Trace) {
+		if msg := t.InMsg; msg != nil && msg.OpCode != nil && *msg.OpCode == uint32(abi.NftTransferMsgOpCode) {
+			found = true
+		}
+	})
+	return found
+}
+
+func (s *LiteStorage) runNftTransferFinder() {
+	ctx := context.Background()
+	for trace := range s.nftTransferTraces {
+		s.setAccountInterfaces(ctx, trace)
+		transfers, err := s.traceExtractors.nftTransfers(ctx, s, trace)
+		if err != nil {
+			nftTransferTraces.WithLabelValues("error").Inc()
+			s.logger.Warn("failed to find nft transfers", zap.String("hash", trace.Hash.Hex()), zap.Error(err))
+			continue
+		}
+		nftTransferTraces.WithLabelValues("success").Inc()
+		for _, transfer := range transfers {
+			s.enqueueNftItem(transfer.Nft)
+		}
+	}
+}
+
+func (s *LiteStorage) isMintedNftItem(tx *tlb.Transaction) bool {
+	if !tx.Msgs.InMsg.Exists || tx.Msgs.InMsg.Value.Value.Info.SumType != "IntMsgInfo" {
+		return false
+	}
+	source, err := ton.AccountIDFromTlb(tx.Msgs.InMsg.Value.Value.Info.IntMsgInfo.Src)
+	if err != nil || source == nil {
+		return false
+	}
+	_, ok := s.nftCollections.Load(*source)
+	return ok
+}
+
+func (s *LiteStorage) runNftIndexer() {
+	ctx := context.Background()
+	for a := range s.nftIndexingQueue {
+		if err := s.refreshNftItem(ctx, a); err != nil {
+			nftIndexUpdates.WithLabelValues("error").Inc()
+			s.logger.Warn("failed to update nft index", zap.String("accountID", a.String()), zap.Error(err))
+			continue
+		}
+		nftIndexUpdates.WithLabelValues("success").Inc()
+	}
+}
+
+// refreshNftItem puts the current state of the NFT item into the index.
+func (s *LiteStorage) refreshNftItem(ctx context.Context, a tongo.AccountID) error {
+	if _, ok := s.notNftItems.Get(a); ok {
+		return nil
+	}
+	prev, err := s.index.nftItem(a)
+	exists := err == nil
+	if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+		return err
+	}
+	var prevMetadata map[string]interface{}
+	if exists {
+		// metadata rarely changes, but downloading it is expensive.
+		prevMetadata = prev.Metadata
+	}
+	item, err := s.fetchNftItem(ctx, a, prevMetadata)
+	if err != nil && isNotNftItem(err) {
+		if exists && errors.Is(err, liteapi.ErrAccountNotFound) {
+			// the item has been burnt.
+			return s.index.deleteNftItem(prev)
+		}
+		s.notNftItems.Set(a, struct{}{})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if item.CollectionAddress != nil {
+		if _, ok := s.nftCollections.Load(*item.CollectionAddress); !ok {
+			if err := s.registerNftCollection(ctx, *item.CollectionAddress); err != nil {
+				return err
+			}
+		}
+	}
+	return s.index.putNftItem(item)
+}
+
+func (s *LiteStorage) registerNftCollection(ctx context.Context, a tongo.AccountID) error {
+	collection, err := s.GetNftCollectionByCollectionAddress(ctx, a)
+	if err != nil {
+		return err
+	}
+	if collection.Address != a {
+		// GetNftCollectionByCollectionAddress returns an empty collection if get_collection_data fails.
+		return nil
+	}
+	if err := s.index.putNftCollection(collection); err != nil {
+		return err
+	}
+	s.nftCollections.Store(a, struct{}{})
+	return nil
+}
+
+// fetchNftItem gets the NFT item with get-methods.
+// If metadata is nil, it is downloaded, otherwise the given metadata is used.
+func (s *LiteStorage) fetchNftItem(ctx context.Context, a tongo.AccountID, metadata map[string]interface{}) (core.NftItem, error) {
+	_, value, err := abi.GetNftData(ctx, s.executor, a)
+	if err != nil {
+		return core.NftItem{}, err
+	}
+	data, ok := value.(abi.GetNftDataResult)
+	if !ok {
+		return core.NftItem{}, errNotNftItem
+	}
+	index := big.Int(data.Index)
+	item := core.NftItem{
+		Address:      a,
+		Index:        decimal.NewFromBigInt(&index, 0),
+		Transferable: true,
+		Metadata:     metadata,
+	}
+	item.CollectionAddress, err = ton.AccountIDFromTlb(data.CollectionAddress)
+	if err != nil {
+		return core.NftItem{}, err
+	}
+	item.OwnerAddress, err = ton.AccountIDFromTlb(data.OwnerAddress)
+	if err != nil {
+		return core.NftItem{}, err
+	}
+	// an item without a collection has nobody to confirm it.
+	item.Verified = item.CollectionAddress == nil
+	if item.CollectionAddress != nil {
+		_, value, err := abi.GetNftAddressByIndex(ctx, s.executor, *item.CollectionAddress, data.Index)
+		if err != nil && !isGetMethodFailure(err) {
+			return core.NftItem{}, err
+		}
+		if result, ok := value.(abi.GetNftAddressByIndexResult); ok {
+			item.Verified = msgAddressEquals(result.Address, a)
+		}
+	}
+	if item.Metadata == nil {
+		item.Metadata, err = s.nftItemMetadata(ctx, item.CollectionAddress, data)
+		if err != nil {
+			s.logger.Debug("failed to get nft item metadata", zap.String("accountID", a.String()), zap.Error(err))
+		}
+	}
+	if item.OwnerAddress != nil {
+		item.Sale = s.nftSale(ctx, a, *item.OwnerAddress)
+	}
+	return item, nil
+}
+
+func (s *LiteStorage) nftItemMetadata(ctx context.Context, collection *tongo.AccountID, data abi.GetNftDataResult) (map[string]interface{}, error) {
+	var fullContent tep64.FullContent
+	if collection == nil {
+		cell := boc.Cell(data.IndividualContent)
+		content, err := tep64.DecodeFullContentFromCell(&cell)
+		if err != nil {
+			return nil, err
+		}
+		fullContent = content
+	} else {
+		_, value, err := abi.GetNftContent(ctx, s.executor, *collection, data.Index, data.IndividualContent)
+		if err != nil {
+			return nil, err
+		}
+		result, ok := value.(abi.GetNftContentResult)
+		if !ok {
+			return nil, errors.New("invalid get_nft_content result")
+		}
+		fullContent, err = tep64.DecodeFullContent(result.Content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nftMetadata(fullContent)
+}
+
+// nftSale returns information about a sale, if the item's owner is a sale contract.
+func (s *LiteStorage) nftSale(ctx context.Context, item, owner tongo.AccountID) *core.NftSaleInfo {
+	_, value, err := abi.GetSaleData(ctx, s.executor, owner)
+	if err != nil {
+		return nil
+	}
+	var (
+		nft, seller, marketplace, royalty tlb.MsgAddress
+		price                             big.Int
+		marketplaceFee, royaltyAmount     uint64
+	)
+	switch data := value.(type) {
+	case abi.GetSaleData_BasicResult:
+		nft, seller, marketplace, royalty = data.Nft, data.Owner, data.Marketplace, data.RoyaltyAddress
+		price = big.Int(data.FullPrice)
+		marketplaceFee, royaltyAmount = data.MarketFee, data.RoyaltyAmount
+	case abi.GetSaleData_GetgemsResult:
+		if data.IsComplete {
+			return nil
+		}
+		nft, seller, marketplace, royalty = data.Nft, data.Owner, data.Marketplace, data.RoyaltyAddress
+		price = big.Int(data.FullPrice)
+		marketplaceFee, royaltyAmount = data.MarketFee, data.RoyaltyAmount
+	case abi.GetSaleData_GetgemsAuctionResult:
+		if data.End || data.IsCanceled {
+			return nil
+		}
+		nft, seller, marketplace, royalty = data.Nft, data.Owner, data.Marketplace, data.RoyaltyAddress
+		price.SetUint64(data.MaxBid)
+	default:
+		return nil
+	}
+	if !msgAddressEquals(nft, item) {
+		return nil
+	}
+	sale := &core.NftSaleInfo{
+		Contract:       owner,
+		Nft:            item,
+		Price:          core.Price{Currency: core.Currency{Type: core.CurrencyNative}, Amount: price},
+		MarketplaceFee: marketplaceFee,
+		RoyaltyAmount:  royaltyAmount,
+	}
+	if a, err := ton.AccountIDFromTlb(marketplace); err == nil && a != nil {
+		sale.Marketplace = *a
+	}
+	sale.Seller, _ = ton.AccountIDFromTlb(seller)
+	sale.RoyaltyAddress, _ = ton.AccountIDFromTlb(royalty)
+	return sale
+}
+
+// storedNftItem is how the NFT index keeps an item.
+// big.Int implements json.Marshaler with a pointer receiver, so the sale price needs a pointer.
+type storedNftItem struct {
+	core.NftItem
+	Sale *storedNftSale
+}
+
+type storedNftSale struct {
+	core.NftSaleInfo
+	Price storedPrice
+}
+
+type storedPrice struct {
+	Currency core.Currency
+	Amount   *big.Int
+}
+
+func newStoredPrice(price core.Price) storedPrice {
+	return storedPrice{Currency: price.Currency, Amount: &price.Amount}
+}
+
+func (p storedPrice) price() core.Price {
+	price := core.Price{Currency: p.Currency}
+	if p.Amount != nil {
+		price.Amount.Set(p.Amount)
+	}
+	return price
+}
+
+func newStoredNftItem(item core.NftItem) storedNftItem {
+	stored := storedNftItem{NftItem: item}
+	if item.Sale != nil {
+		stored.Sale = &storedNftSale{
+			NftSaleInfo: *item.Sale,
+			Price:       newStoredPrice(item.Sale.Price),
+		}
+	}
+	return stored
+}
+
+func (i storedNftItem) nftItem() core.NftItem {
+	item := i.NftItem
+	item.Sale = nil
+	if i.Sale != nil {
+		sale := i.Sale.NftSaleInfo
+		sale.Price = i.Sale.Price.price()
+		item.Sale = &sale
+	}
+	return item
+}
+
+func nftOwnerItemKey(owner, item tongo.AccountID) []byte {
+	return append(accountKey(owner), accountKey(item)...)
+}
+
+// nftOwners returns owners of the item in nftOwnerItemsBucket together with the kind of ownership.
+func nftOwners(item core.NftItem) map[tongo.AccountID]byte {
+	owners := map[tongo.AccountID]byte{}
+	if item.OwnerAddress != nil {
+		owners[*item.OwnerAddress] = nftOwnedDirectly
+	}
+	if item.Sale != nil && item.Sale.Seller != nil {
+		owners[*item.Sale.Seller] = nftOwnedOnSale
+	}
+	return owners
+}
+
+func (i *index) nftItem(a tongo.AccountID) (core.NftItem, error) {
+	value, err := i.store.Get(nftItemsBucket, accountKey(a))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return core.NftItem{}, core.ErrEntityNotFound
+		}
+		return core.NftItem{}, err
+	}
+	var item storedNftItem
+	if err := json.Unmarshal(value, &item); err != nil {
+		return core.NftItem{}, err
+	}
+	return item.nftItem(), nil
+}
+
+func (i *index) putNftItem(item core.NftItem) error {
+	prev, err := i.nftItem(item.Address)
+	if err == nil {
+		if err := i.deleteNftItemKeys(prev); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, core.ErrEntityNotFound) {
+		return err
+	}
+	value, err := json.Marshal(newStoredNftItem(item))
+	if err != nil {
+		return err
+	}
+	if err := i.store.Put(nftItemsBucket, accountKey(item.Address), value); err != nil {
+		return err
+	}
+	for owner, ownership := range nftOwners(item) {
+		if err := i.store.Put(nftOwnerItemsBucket, nftOwnerItemKey(owner, item.Address), []byte{ownership}); err != nil {
+			return err
+		}
+	}
+	if item.CollectionAddress != nil {
+		return i.store.Put(nftCollectionItemsBucket, nftOwnerItemKey(*item.CollectionAddress, item.Address), []byte{1})
+	}
+	return nil
+}
+
+func (i *index) deleteNftItem(item core.NftItem) error {
+	if err := i.deleteNftItemKeys(item); err != nil {
+		return err
+	}
+	return i.store.Delete(nftItemsBucket, accountKey(item.Address))
+}
+
+// deleteNftItemKeys removes the item from the secondary indexes.
+func (i *index) deleteNftItemKeys(item core.NftItem) error {
+	for owner := range nftOwners(item) {
+		if err := i.store.Delete(nftOwnerItemsBucket, nftOwnerItemKey(owner, item.Address)); err != nil {
+			return err
+		}
+	}
+	if item.CollectionAddress != nil {
+		return i.store.Delete(nftCollectionItemsBucket, nftOwnerItemKey(*item.CollectionAddress, item.Address))
+	}
+	return nil
+}
+
+// nftItemIDs returns up to n items of the bucket within [from, to) ordered by their addresses,
+// after skipping the first skip items, and the key to continue from, or nil if there are no more items.
+func (i *index) nftItemIDs(bucket string, from, to []byte, skip, n int) ([]tongo.AccountID, []byte, error) {
+	var ids []tongo.AccountID
+	var next []byte
+	err := i.store.Range(bucket, from, to, false, func(key, value []byte) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		if len(ids) == n {
+			next = bytes.Clone(key)
+			return false
+		}
+		ids = append(ids, accountFromKey(key[len(key)-36:]))
+		return true
+	})
+	return ids, next, err
+}
+
+func (i *index) putNftCollection(collection core.NftCollection) error {
+	value, err := json.Marshal(collection)
+	if err != nil {
+		return err
+	}
+	return i.store.Put(nftCollectionsBucket, accountKey(collection.Address), value)
+}
+
+func (i *index) nftCollection(a tongo.AccountID) (core.NftCollection, error) {
+	value, err := i.store.Get(nftCollectionsBucket, accountKey(a))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return core.NftCollection{}, core.ErrEntityNotFound
+		}
+		return core.NftCollection{}, err
+	}
+	var collection core.NftCollection
+	if err := json.Unmarshal(value, &collection); err != nil {
+		return core.NftCollection{}, err
+	}
+	return collection, nil
+}
+
+// loadNftCollections fills the in-memory set of known collections from the index.
+func (s *LiteStorage) loadNftCollections() error {
+	return s.index.store.Range(nftCollectionsBucket, nil, nil, false, func(key, value []byte) bool {
+		if len(key) == 36 {
+			s.nftCollections.Store(accountFromKey(key), struct{}{})
+		}
+		return true
+	})
+}
+
+func matchesFilter(value *tongo.AccountID, filter *core.Filter[tongo.AccountID]) bool {
+	if filter == nil {
+		return true
+	}
+	if filter.IsZero {
+		return value == nil
+	}
+	return value != nil && *value == filter.Value
+}
+
+func (s *LiteStorage) GetNFTs(ctx context.Context, accounts []tongo.AccountID) ([]core.NftItem, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_nfts").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	items := make([]core.NftItem, 0, len(accounts))
+	for _, a := range accounts {
+		item, err := s.index.nftItem(a)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			// the item hasn't been transferred since we started indexing.
+			item, err = s.fetchNftItem(ctx, a, nil)
+			if err != nil && isNotNftItem(err) {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *LiteStorage) SearchNFTs(ctx context.Context,
+	collection *core.Filter[tongo.AccountID],
+	owner *core.Filter[tongo.AccountID],
+	includeOnSale bool,
+	onlyVerified bool,
+	limit, offset int,
+) ([]tongo.AccountID, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("search_nfts").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if limit <= 0 {
+		return nil, nil
+	}
+	// pick the narrowest index to walk over, the rest of the filters are checked against items.
+	bucket, prefix := nftItemsBucket, []byte(nil)
+	// exact means that every item of the walked range matches the filters.
+	exact := collection == nil && owner == nil && !onlyVerified
+	switch {
+	case owner != nil && !owner.IsZero:
+		bucket, prefix = nftOwnerItemsBucket, accountKey(owner.Value)
+		exact = collection == nil && includeOnSale && !onlyVerified
+	case collection != nil && !collection.IsZero:
+		bucket, prefix = nftCollectionItemsBucket, accountKey(collection.Value)
+		exact = owner == nil && !onlyVerified
+	}
+	from, to := prefix, []byte(nil)
+	if len(prefix) > 0 {
+		to = kv.PrefixEnd(prefix)
+	}
+	if exact {
+		ids, _, err := s.index.nftItemIDs(bucket, from, to, offset, limit)
+		return ids, err
+	}
+	var result []tongo.AccountID
+	skipped := 0
+	for {
+		// items are loaded between batches, because the store can't be accessed while iterating over it.
+		ids, next, err := s.index.nftItemIDs(bucket, from, to, 0, nftSearchBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range ids {
+			item, err := s.index.nftItem(a)
+			if err != nil {
+				return nil, err
+			}
+			if !matchesFilter(item.CollectionAddress, collection) {
+				continue
+			}
+			if onlyVerified && !item.Verified {
+				continue
+			}
+			if owner != nil && !matchesFilter(item.OwnerAddress, owner) {
+				if !includeOnSale || item.Sale == nil || !matchesFilter(item.Sale.Seller, owner) {
+					continue
+				}
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			result = append(result, a)
+			if len(result) == limit {
+				return result, nil
+			}
+		}
+		if next == nil {
+			return result, nil
+		}
+		from = next
+	}
+}
+
+func (s *LiteStorage) GetNftCollections(ctx context.Context, limit, offset *int32) ([]core.NftCollection, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_nft_collections").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	collections := []core.NftCollection{}
+	skip, count := 0, -1
+	if offset != nil {
+		skip = int(*offset)
+	}
+	if limit != nil {
+		count = int(*limit)
+	}
+	if count == 0 {
+		return collections, nil
+	}
+	var decodeErr error
+	err := s.index.store.Range(nftCollectionsBucket, nil, nil, false, func(key, value []byte) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		var collection core.NftCollection
+		if err := json.Unmarshal(value, &collection); err != nil {
+			decodeErr = err
+			return false
+		}
+		collections = append(collections, collection)
+		return len(collections) != count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return collections, decodeErr
+}
+
+func (s *LiteStorage) GetNftCollectionsByAddresses(ctx context.Context, addresses []ton.AccountID) ([]core.NftCollection, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_nft_collections_by_addresses").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	collections := make([]core.NftCollection, 0, len(addresses))
+	for _, a := range addresses {
+		collection, err := s.index.nftCollection(a)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, nil
+}
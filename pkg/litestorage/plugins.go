@@ -0,0 +1,199 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+// walletPluginsCacheSize limits the number of wallets with cached plugins.
+const walletPluginsCacheSize = 10_000
+
+// walletPlugins are plugins of a wallet v4 or extensions of a wallet v5 read at the wallet's last transaction.
+type walletPlugins struct {
+	lt      uint64
+	plugins []core.Plugin
+}
+
+// pluginAccounts returns accounts allowed to spend from the wallet, the way its get-methods report them.
+func (s *LiteStorage) pluginAccounts(ctx context.Context, wallet ton.AccountID, walletVersion abi.ContractInterface) ([]ton.AccountID, error) {
+	switch walletVersion {
+	case abi.WalletV4R1, abi.WalletV4R2:
+		_, value, err := abi.GetPluginList(ctx, s.executor, wallet)
+		if err != nil {
+			return nil, err
+		}
+		result, ok := value.(abi.GetPluginListResult)
+		if !ok {
+			return nil, nil
+		}
+		var accounts []ton.AccountID
+		for _, p := range result.Plugins {
+			accounts = append(accounts, ton.AccountID{Workchain: p.Workchain, Address: p.Address})
+		}
+		return accounts, nil
+	case abi.WalletV5Beta, abi.WalletV5R1:
+		_, value, err := abi.GetExtensions(ctx, s.executor, wallet)
+		if err != nil {
+			return nil, err
+		}
+		result, ok := value.(abi.GetExtensionsResult)
+		if !ok || result.Extensions == nil {
+			return nil, nil
+		}
+		var accounts []ton.AccountID
+		for _, address := range result.Extensions.Extensions.Keys() {
+			// extensions are stored without a workchain, they live in the wallet's workchain.
+			accounts = append(accounts, ton.AccountID{Workchain: wallet.Workchain, Address: address})
+		}
+		return accounts, nil
+	}
+	return nil, nil
+}
+
+// pluginType returns a type of the plugin: a subscription or, if we don't recognize it, a generic plugin or extension.
+func pluginType(interfaces []abi.ContractInterface, walletVersion abi.ContractInterface) string {
+	for _, iface := range interfaces {
+		if iface == abi.SubscriptionV1 || iface == abi.SubscriptionV2 {
+			return iface.String()
+		}
+	}
+	if walletVersion == abi.WalletV5Beta || walletVersion == abi.WalletV5R1 {
+		return "extension"
+	}
+	return "plugin"
+}
+
+// GetAccountPlugins returns plugins of a wallet v4 or extensions of a wallet v5.
+// Both can send messages on behalf of the wallet, so every one of them is reported, recognized or not.
+// The list is cached until the wallet's next transaction.
+func (s *LiteStorage) GetAccountPlugins(ctx context.Context, accountID ton.AccountID, walletVersion abi.ContractInterface) ([]core.Plugin, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_account_plugins").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	wallet, err := s.GetRawAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := s.walletPlugins.Get(accountID); ok && cached.lt == wallet.LastTransactionLt {
+		return cached.plugins, nil
+	}
+	accounts, err := s.pluginAccounts(ctx, accountID, walletVersion)
+	if err != nil && isGetMethodFailure(err) {
+		// the wallet isn't initialized or doesn't support plugins.
+		accounts, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plugins := make([]core.Plugin, 0, len(accounts))
+	for _, a := range accounts {
+		plugin := core.Plugin{AccountID: a, Status: tlb.AccountNone}
+		account, err := s.GetRawAccount(ctx, a)
+		if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+			return nil, err
+		}
+		if account != nil {
+			plugin.Status = account.Status
+		}
+		var interfaces []abi.ContractInterface
+		if plugin.Status == tlb.AccountActive {
+			interfaces, err = s.getAccountInterfaces(ctx, a)
+			if err != nil {
+				return nil, err
+			}
+		}
+		plugin.Type = pluginType(interfaces, walletVersion)
+		plugins = append(plugins, plugin)
+	}
+	s.walletPlugins.Set(accountID, walletPlugins{lt: wallet.LastTransactionLt, plugins: plugins})
+	return plugins, nil
+}
+
+// storedAccountStat contains counters of the account's transactions, they are updated with every new transaction.
+type storedAccountStat struct {
+	TxCount       int64
+	FirstLt       uint64
+	FirstActivity int64
+	LastLt        uint64
+	LastActivity  int64
+	GramBalance   int64
+}
+
+func (stat *storedAccountStat) add(tx *core.Transaction) {
+	if stat.TxCount == 0 || tx.Lt < stat.FirstLt {
+		stat.FirstLt, stat.FirstActivity = tx.Lt, tx.Utime
+	}
+	if stat.TxCount == 0 || tx.Lt > stat.LastLt {
+		stat.LastLt, stat.LastActivity, stat.GramBalance = tx.Lt, tx.Utime, tx.EndBalance
+	}
+	stat.TxCount++
+}
+
+func (i *index) storedAccountStat(a tongo.AccountID) (storedAccountStat, error) {
+	value, err := i.store.Get(accountStatsBucket, accountKey(a))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return storedAccountStat{}, core.ErrEntityNotFound
+		}
+		return storedAccountStat{}, err
+	}
+	var stat storedAccountStat
+	if err := json.Unmarshal(value, &stat); err != nil {
+		return storedAccountStat{}, err
+	}
+	return stat, nil
+}
+
+// updateAccountStat adds a new transaction, which has been stored already, to the counters of its account.
+func (i *index) updateAccountStat(tx *core.Transaction) error {
+	stat, err := i.storedAccountStat(tx.Account)
+	if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+		return err
+	}
+	stat.add(tx)
+	value, err := json.Marshal(stat)
+	if err != nil {
+		return err
+	}
+	return i.store.Put(accountStatsBucket, accountKey(tx.Account), value)
+}
+
+// accountStat returns statistics of the account taken from the local index.
+func (i *index) accountStat(a tongo.AccountID) (core.AccountStat, error) {
+	stored, err := i.storedAccountStat(a)
+	if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+		return core.AccountStat{}, err
+	}
+	stat := core.AccountStat{
+		AccountID:     a,
+		GramBalance:   stored.GramBalance,
+		TxCount:       stored.TxCount,
+		FirstActivity: stored.FirstActivity,
+		LastActivity:  stored.LastActivity,
+	}
+	prefix := accountKey(a)
+	err = i.store.Range(nftOwnerItemsBucket, prefix, kv.PrefixEnd(prefix), false, func(key, value []byte) bool {
+		stat.NftsCount++
+		return true
+	})
+	if err != nil {
+		return core.AccountStat{}, err
+	}
+	multisigs, err := i.memberMultisigs(a)
+	if err != nil {
+		return core.AccountStat{}, err
+	}
+	stat.MultisigCount = int32(len(multisigs))
+	return stat, nil
+}
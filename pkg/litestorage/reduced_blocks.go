@@ -0,0 +1,197 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// reducedBlocksBufferSize is a number of the latest blocks kept in memory,
+// it is about half an hour of the masterchain and the basechain.
+const reducedBlocksBufferSize = 20_000
+
+// reducedBlocksPruneInterval defines how often blocks older than the retention period are removed from the kv store.
+const reducedBlocksPruneInterval = time.Minute
+
+// reducedBlocks is a ring buffer with summaries of the latest blocks.
+type reducedBlocks struct {
+	mu     sync.RWMutex
+	blocks []core.ReducedBlock
+	// next is a position in blocks to write the next block to.
+	next int
+}
+
+func newReducedBlocks(size int) *reducedBlocks {
+	return &reducedBlocks{blocks: make([]core.ReducedBlock, 0, size)}
+}
+
+func (r *reducedBlocks) add(block core.ReducedBlock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.blocks) < cap(r.blocks) {
+		r.blocks = append(r.blocks, block)
+		return
+	}
+	r.blocks[r.next] = block
+	r.next = (r.next + 1) % len(r.blocks)
+}
+
+// oldest returns utime of the oldest block in the buffer.
+func (r *reducedBlocks) oldest() (int64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.blocks) == 0 {
+		return 0, false
+	}
+	oldest := r.blocks[0].Utime
+	for _, b := range r.blocks {
+		oldest = min(oldest, b.Utime)
+	}
+	return oldest, true
+}
+
+// between returns blocks generated within [from, to].
+func (r *reducedBlocks) between(from, to int64) []core.ReducedBlock {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var blocks []core.ReducedBlock
+	for _, b := range r.blocks {
+		if b.Utime >= from && b.Utime <= to {
+			blocks = append(blocks, b)
+		}
+	}
+	sortReducedBlocks(blocks)
+	return blocks
+}
+
+func sortReducedBlocks(blocks []core.ReducedBlock) {
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Utime != blocks[j].Utime {
+			return blocks[i].Utime < blocks[j].Utime
+		}
+		if blocks[i].Workchain != blocks[j].Workchain {
+			return blocks[i].Workchain < blocks[j].Workchain
+		}
+		if blocks[i].Shard != blocks[j].Shard {
+			return blocks[i].Shard < blocks[j].Shard
+		}
+		return blocks[i].Seqno < blocks[j].Seqno
+	})
+}
+
+// newReducedBlock builds a summary of the block, shards blocks are set for masterchain blocks only.
+func newReducedBlock(header *core.BlockHeader, block *tlb.Block) core.ReducedBlock {
+	reduced := core.ReducedBlock{
+		BlockIDExt: header.BlockIDExt,
+		Utime:      int64(header.GenUtime),
+		TxQuantity: header.TxQuantity,
+	}
+	if header.MasterRef != nil {
+		reduced.MasterRef = &header.MasterRef.BlockID
+	}
+	for _, parent := range header.PrevBlocks {
+		reduced.ParentBlocks = append(reduced.ParentBlocks, parent.BlockID)
+	}
+	if header.Workchain == -1 {
+		for _, shard := range ton.ShardIDs(block) {
+			reduced.ShardsBlocks = append(reduced.ShardsBlocks, shard.BlockID)
+		}
+	}
+	return reduced
+}
+
+func reducedBlockKey(utime int64, id tongo.BlockID) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, uint64(utime)), blockIDKey(id)...)
+}
+
+func (i *index) storeReducedBlock(block core.ReducedBlock) error {
+	value, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+	return i.store.Put(reducedBlocksBucket, reducedBlockKey(block.Utime, block.BlockID), value)
+}
+
+// reducedBlocks returns stored blocks generated within [from, to].
+func (i *index) reducedBlocks(from, to int64) ([]core.ReducedBlock, error) {
+	var blocks []core.ReducedBlock
+	var decodeErr error
+	err := i.store.Range(reducedBlocksBucket, binary.BigEndian.AppendUint64(nil, uint64(from)), binary.BigEndian.AppendUint64(nil, uint64(to+1)), false, func(key, value []byte) bool {
+		var block core.ReducedBlock
+		if decodeErr = json.Unmarshal(value, &block); decodeErr != nil {
+			return false
+		}
+		blocks = append(blocks, block)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	sortReducedBlocks(blocks)
+	return blocks, nil
+}
+
+// pruneReducedBlocks removes stored blocks generated before the given time.
+func (i *index) pruneReducedBlocks(before int64) error {
+	var keys [][]byte
+	err := i.store.Range(reducedBlocksBucket, nil, binary.BigEndian.AppendUint64(nil, uint64(before)), false, func(key, value []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := i.store.Delete(reducedBlocksBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addReducedBlock keeps a summary of the block in memory and, if configured, in the kv store.
+func (s *LiteStorage) addReducedBlock(header *core.BlockHeader, block *tlb.Block) error {
+	reduced := newReducedBlock(header, block)
+	s.reducedBlocks.add(reduced)
+	if s.reducedBlocksRetention <= 0 {
+		return nil
+	}
+	return s.index.storeReducedBlock(reduced)
+}
+
+// runReducedBlocksPruner removes blocks older than the retention period from the kv store.
+// Pruning walks over the bucket, so it runs on a timer rather than with every block.
+func (s *LiteStorage) runReducedBlocksPruner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if err := s.index.pruneReducedBlocks(now.Add(-s.reducedBlocksRetention).Unix()); err != nil {
+			s.logger.Error("failed to prune reduced blocks", zap.Error(err))
+		}
+	}
+}
+
+func (s *LiteStorage) GetReducedBlocks(ctx context.Context, from, to int64) ([]core.ReducedBlock, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_reduced_blocks").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if oldest, ok := s.reducedBlocks.oldest(); s.reducedBlocksRetention <= 0 || (ok && oldest <= from) {
+		return s.reducedBlocks.between(from, to), nil
+	}
+	return s.index.reducedBlocks(from, to)
+}
@@ -0,0 +1,81 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestLiteStorage_GetReducedBlocks(t *testing.T) {
+	block := func(workchain int32, seqno uint32, utime int64) core.ReducedBlock {
+		return core.ReducedBlock{
+			BlockIDExt: tongo.BlockIDExt{BlockID: tongo.BlockID{Workchain: workchain, Shard: 0x8000000000000000, Seqno: seqno}},
+			Utime:      utime,
+		}
+	}
+	seqnos := func(blocks []core.ReducedBlock) []uint32 {
+		var res []uint32
+		for _, b := range blocks {
+			res = append(res, b.Seqno)
+		}
+		return res
+	}
+	storage := &LiteStorage{
+		index:                  newIndex(kv.NewMemoryStore()),
+		reducedBlocks:          newReducedBlocks(3),
+		reducedBlocksRetention: time.Hour,
+	}
+	for _, b := range []core.ReducedBlock{
+		block(-1, 1, 100),
+		block(0, 2, 101),
+		block(-1, 3, 103),
+		block(0, 4, 103),
+		block(-1, 5, 105),
+	} {
+		storage.reducedBlocks.add(b)
+		require.Nil(t, storage.index.storeReducedBlock(b))
+	}
+	ctx := context.Background()
+
+	// the buffer keeps the last 3 blocks.
+	blocks, err := storage.GetReducedBlocks(ctx, 103, 110)
+	require.Nil(t, err)
+	require.Equal(t, []uint32{3, 4, 5}, seqnos(blocks))
+	require.Equal(t, []uint32{3, 4, 5}, seqnos(storage.reducedBlocks.between(0, 200)))
+
+	// older blocks are read from the kv store.
+	blocks, err = storage.GetReducedBlocks(ctx, 100, 103)
+	require.Nil(t, err)
+	require.Equal(t, []uint32{1, 2, 3, 4}, seqnos(blocks))
+
+	require.Nil(t, storage.index.pruneReducedBlocks(103))
+	blocks, err = storage.index.reducedBlocks(0, 200)
+	require.Nil(t, err)
+	require.Equal(t, []uint32{3, 4, 5}, seqnos(blocks))
+}
+
+func TestLiteStorage_runReducedBlocksPruner(t *testing.T) {
+	storage := &LiteStorage{
+		logger:                 zap.NewNop(),
+		index:                  newIndex(kv.NewMemoryStore()),
+		reducedBlocksRetention: time.Hour,
+	}
+	now := time.Now()
+	old := core.ReducedBlock{BlockIDExt: tongo.BlockIDExt{BlockID: tongo.BlockID{Workchain: -1, Seqno: 1}}, Utime: now.Add(-2 * time.Hour).Unix()}
+	recent := core.ReducedBlock{BlockIDExt: tongo.BlockIDExt{BlockID: tongo.BlockID{Workchain: -1, Seqno: 2}}, Utime: now.Unix()}
+	require.Nil(t, storage.index.storeReducedBlock(old))
+	require.Nil(t, storage.index.storeReducedBlock(recent))
+
+	go storage.runReducedBlocksPruner(10 * time.Millisecond)
+	require.Eventually(t, func() bool {
+		blocks, err := storage.index.reducedBlocks(0, now.Unix())
+		return err == nil && len(blocks) == 1 && blocks[0].Seqno == 2
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,444 @@
+package litestorage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+	"go.uber.org/zap"
+)
+
+var ruleEvaluations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "lite_storage_tracking_rule_evaluations_total",
+	Help: "Number of accounts checked against tracking rules",
+}, []string{"result"})
+
+const (
+	// notMatchingAccountsCacheSize limits the number of accounts we remember as not matching any rule.
+	notMatchingAccountsCacheSize = 100_000
+	// notMatchingRulesCacheSize limits the number of accounts we remember as not matching a particular rule.
+	notMatchingRulesCacheSize = 100_000
+	// rulesQueueSize is a number of accounts from new blocks waiting to be checked against tracking rules.
+	rulesQueueSize = 10_000
+)
+
+// RuleKind defines what a tracking rule checks.
+type RuleKind string
+
+const (
+	// RuleCodeHash matches accounts with the given code hash.
+	RuleCodeHash RuleKind = "code_hash"
+	// RuleInterface matches accounts implementing the given contract interface, e.g. "jetton_wallet".
+	RuleInterface RuleKind = "interface"
+	// RuleJettonMaster matches jetton wallets of the given jetton master,
+	// the master has to confirm the wallet with get_wallet_address.
+	RuleJettonMaster RuleKind = "jetton_master"
+	// RuleNftCollection matches NFT items of the given collection,
+	// the collection has to confirm the item with get_nft_address_by_index.
+	RuleNftCollection RuleKind = "nft_collection"
+)
+
+// TrackingRule is a predicate to find accounts to track automatically.
+// LiteStorage checks accounts appearing in new blocks against tracking rules,
+// and once an account matches a rule, it is tracked as if it was added with TrackAccount.
+type TrackingRule struct {
+	Kind RuleKind
+	// CodeHash is set for RuleCodeHash.
+	CodeHash tongo.Bits256
+	// Interface is set for RuleInterface.
+	Interface abi.ContractInterface
+	// Account is a jetton master for RuleJettonMaster and a collection for RuleNftCollection.
+	Account tongo.AccountID
+}
+
+// ParseTrackingRule parses a rule in the "kind:value" format, for example:
+//
+//	code_hash:<hex>
+//	interface:jetton_wallet
+//	jetton_master:<address>
+//	nft_collection:<address>
+func ParseTrackingRule(s string) (TrackingRule, error) {
+	kind, value, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return TrackingRule{}, fmt.Errorf("invalid tracking rule %q, expected kind:value", s)
+	}
+	rule := TrackingRule{Kind: RuleKind(kind)}
+	switch rule.Kind {
+	case RuleCodeHash:
+		hash, err := tongo.ParseHash(value)
+		if err != nil {
+			return TrackingRule{}, fmt.Errorf("invalid code hash: %w", err)
+		}
+		rule.CodeHash = hash
+	case RuleInterface:
+		rule.Interface = abi.ContractInterfaceFromString(value)
+		if rule.Interface == abi.IUnknown {
+			return TrackingRule{}, fmt.Errorf("unknown contract interface %q", value)
+		}
+	case RuleJettonMaster, RuleNftCollection:
+		account, err := tongo.ParseAddress(value)
+		if err != nil {
+			return TrackingRule{}, err
+		}
+		rule.Account = account.ID
+	default:
+		return TrackingRule{}, fmt.Errorf("unknown tracking rule kind %q", kind)
+	}
+	return rule, nil
+}
+
+// String returns the rule in the format accepted by ParseTrackingRule.
+// It also serves as a unique identifier of the rule.
+func (r TrackingRule) String() string {
+	switch r.Kind {
+	case RuleCodeHash:
+		return fmt.Sprintf("%v:%v", r.Kind, r.CodeHash.Hex())
+	case RuleInterface:
+		return fmt.Sprintf("%v:%v", r.Kind, r.Interface)
+	default:
+		return fmt.Sprintf("%v:%v", r.Kind, r.Account.ToRaw())
+	}
+}
+
+// needsInspection returns true if the rule can't be checked by the code hash alone.
+func (r TrackingRule) needsInspection() bool {
+	return r.Kind != RuleCodeHash
+}
+
+func (r TrackingRule) match(codeHash tongo.Bits256, cd *abi.ContractDescription) bool {
+	switch r.Kind {
+	case RuleCodeHash:
+		return codeHash == r.CodeHash
+	case RuleInterface:
+		return implements(cd, r.Interface)
+	case RuleJettonMaster:
+		if !implements(cd, abi.JettonWallet) {
+			return false
+		}
+		for _, m := range cd.GetMethods {
+			if data, ok := m.Result.(abi.GetWalletDataResult); ok {
+				return msgAddressEquals(data.Jetton, r.Account)
+			}
+		}
+	case RuleNftCollection:
+		if !implements(cd, abi.NftItem) {
+			return false
+		}
+		for _, m := range cd.GetMethods {
+			if data, ok := m.Result.(abi.GetNftDataResult); ok {
+				return msgAddressEquals(data.CollectionAddress, r.Account)
+			}
+		}
+	}
+	return false
+}
+
+func implements(cd *abi.ContractDescription, iface abi.ContractInterface) bool {
+	if cd == nil {
+		return false
+	}
+	for _, i := range cd.ContractInterfaces {
+		if i.Implements(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+func msgAddressEquals(address tlb.MsgAddress, account tongo.AccountID) bool {
+	a, err := ton.AccountIDFromTlb(address)
+	return err == nil && a != nil && *a == account
+}
+
+// TrackingRules returns all tracking rules sorted by their string representation.
+func (s *LiteStorage) TrackingRules() []TrackingRule {
+	s.trackingMu.RLock()
+	defer s.trackingMu.RUnlock()
+	rules := make([]TrackingRule, 0, len(s.trackingRules))
+	for _, rule := range s.trackingRules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].String() < rules[j].String()
+	})
+	return rules
+}
+
+// AddTrackingRule adds a new rule.
+// The rule applies to accounts appearing in new blocks,
+// accounts that don't have any new transactions are not discovered.
+func (s *LiteStorage) AddTrackingRule(rule TrackingRule) error {
+	s.trackingMu.Lock()
+	defer s.trackingMu.Unlock()
+	key := rule.String()
+	if _, ok := s.trackingRules[key]; ok {
+		return nil
+	}
+	if err := s.index.addTrackingRule(key); err != nil {
+		return err
+	}
+	s.trackingRules[key] = rule
+	// accounts that didn't match the previous set of rules can match the new one.
+	s.rulesGeneration++
+	for _, a := range s.notMatchingAccounts.Keys() {
+		s.notMatchingAccounts.Delete(a)
+	}
+	return nil
+}
+
+// RemoveTrackingRule removes the rule.
+// Accounts that have already matched the rule stay tracked, use UntrackAccount to stop tracking them.
+func (s *LiteStorage) RemoveTrackingRule(rule TrackingRule) error {
+	s.trackingMu.Lock()
+	defer s.trackingMu.Unlock()
+	key := rule.String()
+	if err := s.index.removeTrackingRule(key); err != nil {
+		return err
+	}
+	delete(s.trackingRules, key)
+	return nil
+}
+
+// rulesSnapshot returns a copy of the rules together with their generation,
+// which changes every time a new rule is added.
+func (s *LiteStorage) rulesSnapshot() ([]TrackingRule, uint64) {
+	s.trackingMu.RLock()
+	defer s.trackingMu.RUnlock()
+	rules := make([]TrackingRule, 0, len(s.trackingRules))
+	for _, rule := range s.trackingRules {
+		rules = append(rules, rule)
+	}
+	return rules, s.rulesGeneration
+}
+
+// markNotMatching remembers that the account doesn't match the rules of the given generation.
+func (s *LiteStorage) markNotMatching(a tongo.AccountID, generation uint64) {
+	s.trackingMu.RLock()
+	defer s.trackingMu.RUnlock()
+	if generation == s.rulesGeneration {
+		s.notMatchingAccounts.Set(a, struct{}{})
+	}
+}
+
+// enqueueRuleEvaluation schedules checking of the given untracked accounts against tracking rules.
+// Getting an account's state and inspecting its code is slow, so it doesn't happen in the block processing loop.
+func (s *LiteStorage) enqueueRuleEvaluation(accounts []tongo.AccountID) {
+	s.trackingMu.RLock()
+	noRules := len(s.trackingRules) == 0
+	s.trackingMu.RUnlock()
+	if noRules {
+		return
+	}
+	for _, a := range accounts {
+		if _, ok := s.notMatchingAccounts.Get(a); ok {
+			continue
+		}
+		select {
+		case s.rulesQueue <- a:
+		default:
+			// the account is checked again with its next transaction.
+			ruleEvaluations.WithLabelValues("dropped").Inc()
+		}
+	}
+}
+
+func (s *LiteStorage) runRuleEvaluator() {
+	ctx := context.Background()
+	for a := range s.rulesQueue {
+		s.trackIfMatching(ctx, a)
+	}
+}
+
+// trackIfMatching checks the account against tracking rules and starts tracking it if it matches any rule.
+func (s *LiteStorage) trackIfMatching(ctx context.Context, a tongo.AccountID) {
+	if s.isTracked(a) {
+		// the account has appeared in several blocks and matched already.
+		return
+	}
+	if _, ok := s.notMatchingAccounts.Get(a); ok {
+		return
+	}
+	rules, generation := s.rulesSnapshot()
+	if len(rules) == 0 {
+		return
+	}
+	matched, final, err := s.matchRules(ctx, a, rules)
+	if err != nil {
+		ruleEvaluations.WithLabelValues("error").Inc()
+		s.logger.Warn("failed to check tracking rules", zap.String("accountID", a.String()), zap.Error(err))
+		return
+	}
+	if matched == nil {
+		ruleEvaluations.WithLabelValues("miss").Inc()
+		if final {
+			s.markNotMatching(a, generation)
+		}
+		return
+	}
+	ruleEvaluations.WithLabelValues("match").Inc()
+	s.logger.Info("account matches tracking rule",
+		zap.String("accountID", a.String()),
+		zap.String("rule", matched.String()))
+	if err := s.TrackAccount(a); err != nil {
+		s.logger.Error("failed to track account", zap.String("accountID", a.String()), zap.Error(err))
+		return
+	}
+	if err := s.index.addMatchedRule(a, matched.String()); err != nil {
+		s.logger.Error("failed to save matched rule", zap.String("accountID", a.String()), zap.Error(err))
+	}
+}
+
+// notMatchingRule is an account checked against a rule given in the format of TrackingRule.String().
+type notMatchingRule struct {
+	account tongo.AccountID
+	rule    string
+}
+
+// MatchesTrackingRule returns true if the account matches the rule.
+// An account is tracked because of the first rule it matches, or explicitly,
+// so other rules are checked on demand and a match is saved.
+func (s *LiteStorage) MatchesTrackingRule(ctx context.Context, a tongo.AccountID, rule TrackingRule) (bool, error) {
+	key := rule.String()
+	matched, err := s.index.matchedRules(a)
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(matched, key) {
+		return true, nil
+	}
+	if _, ok := s.notMatchingRules.Get(notMatchingRule{account: a, rule: key}); ok {
+		return false, nil
+	}
+	m, final, err := s.matchRules(ctx, a, []TrackingRule{rule})
+	if err != nil {
+		return false, err
+	}
+	if m == nil {
+		if final {
+			s.notMatchingRules.Set(notMatchingRule{account: a, rule: key}, struct{}{})
+		}
+		return false, nil
+	}
+	return true, s.index.addMatchedRule(a, key)
+}
+
+// matchRules returns the first rule the account matches.
+// final is false if the account doesn't have code yet, so it has to be checked again later.
+func (s *LiteStorage) matchRules(ctx context.Context, a tongo.AccountID, rules []TrackingRule) (matched *TrackingRule, final bool, err error) {
+	account, err := s.GetRawAccount(ctx, a)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(account.Code) == 0 {
+		return nil, false, nil
+	}
+	codeHash, err := codeHash(account.Code)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid code of %v: %w", a, err)
+	}
+	var cd *abi.ContractDescription
+	for i := range rules {
+		if rules[i].needsInspection() && cd == nil {
+			inspector := abi.NewContractInspector(abi.InspectWithLibraryResolver(s))
+			cd, err = inspector.InspectContract(ctx, account.Code, s.executor, a)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		if !rules[i].match(codeHash, cd) {
+			continue
+		}
+		confirmed := true
+		switch rules[i].Kind {
+		case RuleJettonMaster:
+			confirmed, err = s.confirmJettonWallet(ctx, a, cd, rules[i].Account)
+		case RuleNftCollection:
+			confirmed, err = s.confirmNftItem(ctx, a, cd, rules[i].Account)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if !confirmed {
+			continue
+		}
+		return &rules[i], true, nil
+	}
+	return nil, true, nil
+}
+
+// confirmJettonWallet returns true if the jetton master derives the wallet's address from the wallet's owner.
+// A contract can report any master in get_wallet_data, so only the master knows its real wallets.
+func (s *LiteStorage) confirmJettonWallet(ctx context.Context, wallet tongo.AccountID, cd *abi.ContractDescription, master tongo.AccountID) (bool, error) {
+	if verified, ok := s.jettonWallets.Get(wallet); ok {
+		return verified != nil && *verified == master, nil
+	}
+	var owner tlb.MsgAddress
+	found := false
+	for _, m := range cd.GetMethods {
+		if data, ok := m.Result.(abi.GetWalletDataResult); ok {
+			owner, found = data.Owner, true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	_, value, err := abi.GetWalletAddress(ctx, s.executor, master, owner)
+	if err != nil {
+		if isGetMethodFailure(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	result, ok := value.(abi.GetWalletAddressResult)
+	if !ok || !msgAddressEquals(result.JettonWalletAddress, wallet) {
+		return false, nil
+	}
+	s.jettonWallets.Set(wallet, &master)
+	return true, nil
+}
+
+// confirmNftItem returns true if the collection returns the item's address for the item's index.
+// An item can report any collection in get_nft_data, so only the collection knows its real items.
+func (s *LiteStorage) confirmNftItem(ctx context.Context, item tongo.AccountID, cd *abi.ContractDescription, collection tongo.AccountID) (bool, error) {
+	var data abi.GetNftDataResult
+	found := false
+	for _, m := range cd.GetMethods {
+		if result, ok := m.Result.(abi.GetNftDataResult); ok {
+			data, found = result, true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	_, value, err := abi.GetNftAddressByIndex(ctx, s.executor, collection, data.Index)
+	if err != nil {
+		if isGetMethodFailure(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	result, ok := value.(abi.GetNftAddressByIndexResult)
+	return ok && msgAddressEquals(result.Address, item), nil
+}
+
+func codeHash(code []byte) (tongo.Bits256, error) {
+	cells, err := boc.DeserializeBoc(code)
+	if err != nil {
+		return tongo.Bits256{}, err
+	}
+	if len(cells) != 1 {
+		return tongo.Bits256{}, fmt.Errorf("code must contain exactly one root cell")
+	}
+	return cells[0].Hash256()
+}
@@ -0,0 +1,206 @@
+package litestorage
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/tlb"
+
+	"github.com/tonkeeper/opentonapi/pkg/cache"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestParseTrackingRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		want    TrackingRule
+		wantErr bool
+	}{
+		{
+			name: "code hash",
+			rule: "code_hash:feb5ff6820e2ff0d9483e7e0d62c817d846789fb4ae580c878866d959dabd5c0",
+			want: TrackingRule{Kind: RuleCodeHash, CodeHash: tongo.MustParseHash("feb5ff6820e2ff0d9483e7e0d62c817d846789fb4ae580c878866d959dabd5c0")},
+		},
+		{
+			name: "interface",
+			rule: "interface:jetton_wallet",
+			want: TrackingRule{Kind: RuleInterface, Interface: abi.JettonWallet},
+		},
+		{
+			name: "jetton master",
+			rule: "jetton_master:0:b113a994b5024a16719f69139328eb759596c38a25f59028b146fecdc3621dfe",
+			want: TrackingRule{Kind: RuleJettonMaster, Account: tongo.MustParseAddress("0:b113a994b5024a16719f69139328eb759596c38a25f59028b146fecdc3621dfe").ID},
+		},
+		{
+			name:    "unknown interface",
+			rule:    "interface:whatever",
+			wantErr: true,
+		},
+		{
+			name:    "unknown kind",
+			rule:    "owner:0:b113a994b5024a16719f69139328eb759596c38a25f59028b146fecdc3621dfe",
+			wantErr: true,
+		},
+		{
+			name:    "no value",
+			rule:    "nft_collection",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseTrackingRule(tt.rule)
+			if tt.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.want, rule)
+			require.Equal(t, tt.rule, rule.String())
+		})
+	}
+}
+
+func TestTrackingRule_match(t *testing.T) {
+	master := tongo.MustParseAddress("0:b113a994b5024a16719f69139328eb759596c38a25f59028b146fecdc3621dfe").ID
+	wallet := &abi.ContractDescription{
+		ContractInterfaces: []abi.ContractInterface{abi.JettonWallet},
+		GetMethods: []abi.MethodInvocation{{
+			Result:   abi.GetWalletDataResult{Jetton: master.ToMsgAddress()},
+			TypeHint: "GetWalletDataResult",
+		}},
+	}
+	other := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+
+	require.True(t, TrackingRule{Kind: RuleJettonMaster, Account: master}.match(tongo.Bits256{}, wallet))
+	require.False(t, TrackingRule{Kind: RuleJettonMaster, Account: other}.match(tongo.Bits256{}, wallet))
+	require.False(t, TrackingRule{Kind: RuleNftCollection, Account: master}.match(tongo.Bits256{}, wallet))
+	require.True(t, TrackingRule{Kind: RuleInterface, Interface: abi.JettonWallet}.match(tongo.Bits256{}, wallet))
+	require.False(t, TrackingRule{Kind: RuleInterface, Interface: abi.JettonWallet}.match(tongo.Bits256{}, nil))
+	require.True(t, TrackingRule{Kind: RuleCodeHash, CodeHash: tongo.Bits256{2}}.match(tongo.Bits256{2}, nil))
+	require.False(t, TrackingRule{Kind: RuleCodeHash, CodeHash: tongo.Bits256{2}}.match(tongo.Bits256{3}, wallet))
+
+	var empty tlb.MsgAddress
+	require.False(t, msgAddressEquals(empty, master))
+}
+
+func TestLiteStorage_confirmJettonWallet(t *testing.T) {
+	master := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	other := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	wallet := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+	s := &LiteStorage{
+		jettonWallets: cache.NewLRUCache[tongo.AccountID, *tongo.AccountID](10, "test_jetton_wallets"),
+	}
+	// the wallet has been verified by its real master before.
+	s.jettonWallets.Set(wallet, &other)
+	confirmed, err := s.confirmJettonWallet(context.Background(), wallet, nil, master)
+	require.Nil(t, err)
+	require.False(t, confirmed)
+	confirmed, err = s.confirmJettonWallet(context.Background(), wallet, nil, other)
+	require.Nil(t, err)
+	require.True(t, confirmed)
+
+	// an account without get_wallet_data isn't a wallet of any master.
+	confirmed, err = s.confirmJettonWallet(context.Background(), master, &abi.ContractDescription{}, master)
+	require.Nil(t, err)
+	require.False(t, confirmed)
+}
+
+// nftCollectionExecutor runs get_nft_address_by_index of a collection with the given items.
+type nftCollectionExecutor struct {
+	items map[int64]tongo.AccountID
+}
+
+func (e nftCollectionExecutor) RunSmcMethodByID(ctx context.Context, accountID tongo.AccountID, methodID int, params tlb.VmStack) (uint32, tlb.VmStack, error) {
+	index := big.Int(params.Peek(0).VmStkInt)
+	item, ok := e.items[index.Int64()]
+	if !ok {
+		return 11, tlb.VmStack{}, nil
+	}
+	value, err := tlb.TlbStructToVmCellSlice(item.ToMsgAddress())
+	if err != nil {
+		return 0, tlb.VmStack{}, err
+	}
+	var stack tlb.VmStack
+	stack.Put(value)
+	return 0, stack, nil
+}
+
+func TestLiteStorage_confirmNftItem(t *testing.T) {
+	collection := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	item := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	fake := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+	s := &LiteStorage{executor: nftCollectionExecutor{items: map[int64]tongo.AccountID{0: item}}}
+	description := func(index int64) *abi.ContractDescription {
+		return &abi.ContractDescription{GetMethods: []abi.MethodInvocation{{
+			Result: abi.GetNftDataResult{Index: tlb.Int257(*big.NewInt(index)), CollectionAddress: collection.ToMsgAddress()},
+		}}}
+	}
+	confirmed, err := s.confirmNftItem(context.Background(), item, description(0), collection)
+	require.Nil(t, err)
+	require.True(t, confirmed)
+
+	// a contract claiming an index of a real item isn't that item.
+	confirmed, err = s.confirmNftItem(context.Background(), fake, description(0), collection)
+	require.Nil(t, err)
+	require.False(t, confirmed)
+	confirmed, err = s.confirmNftItem(context.Background(), fake, description(1), collection)
+	require.Nil(t, err)
+	require.False(t, confirmed)
+
+	// an account without get_nft_data isn't an item of any collection.
+	confirmed, err = s.confirmNftItem(context.Background(), item, &abi.ContractDescription{}, collection)
+	require.Nil(t, err)
+	require.False(t, confirmed)
+}
+
+func TestLiteStorage_enqueueRuleEvaluation(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	c := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+	s := &LiteStorage{
+		trackingRules:       map[string]TrackingRule{},
+		rulesQueue:          make(chan tongo.AccountID, 1),
+		notMatchingAccounts: cache.NewLRUCache[tongo.AccountID, struct{}](10, "test_not_matching_accounts"),
+	}
+	// nothing to check without rules.
+	s.enqueueRuleEvaluation([]tongo.AccountID{a})
+	require.Len(t, s.rulesQueue, 0)
+
+	rule := TrackingRule{Kind: RuleInterface, Interface: abi.JettonWallet}
+	s.trackingRules[rule.String()] = rule
+	s.notMatchingAccounts.Set(a, struct{}{})
+	// the queue is full after b, so c is dropped instead of blocking the caller.
+	s.enqueueRuleEvaluation([]tongo.AccountID{a, b, c})
+	require.Len(t, s.rulesQueue, 1)
+	require.Equal(t, b, <-s.rulesQueue)
+}
+
+func TestLiteStorage_MatchesTrackingRule(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	s := &LiteStorage{
+		index:            newIndex(kv.NewMemoryStore()),
+		notMatchingRules: cache.NewLRUCache[notMatchingRule, struct{}](10, "test_not_matching_rules"),
+	}
+	first := TrackingRule{Kind: RuleInterface, Interface: abi.JettonWallet}
+	second := TrackingRule{Kind: RuleCodeHash, CodeHash: tongo.Bits256{7}}
+	// a is tracked because of the first rule and has been checked against the second one.
+	require.Nil(t, s.index.addMatchedRule(a, first.String()))
+	require.Nil(t, s.index.addMatchedRule(a, second.String()))
+	s.notMatchingRules.Set(notMatchingRule{account: b, rule: second.String()}, struct{}{})
+
+	for _, rule := range []TrackingRule{first, second} {
+		matches, err := s.MatchesTrackingRule(context.Background(), a, rule)
+		require.Nil(t, err)
+		require.True(t, matches)
+	}
+	matches, err := s.MatchesTrackingRule(context.Background(), b, second)
+	require.Nil(t, err)
+	require.False(t, matches)
+}
@@ -0,0 +1,322 @@
+package litestorage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/tlb"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/blockchain"
+	"github.com/tonkeeper/opentonapi/pkg/blockchain/indexer"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+var sentMessagesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "lite_storage_sent_messages_total",
+	Help: "Number of sent messages by their final status",
+}, []string{"status"})
+
+const (
+	// defaultSentMessageTTL is how long we wait for a message to land in the blockchain
+	// if we can't read its valid_until.
+	defaultSentMessageTTL = 5 * time.Minute
+	// maxSentMessageTTL limits how long we wait for a message to land in the blockchain.
+	maxSentMessageTTL = time.Hour
+	// sentMessagesRetention is how long the final status of a message is kept.
+	sentMessagesRetention = 24 * time.Hour
+	// sentMessageExpiryDelay is a number of masterchain blocks we wait after valid_until before expiring a message.
+	// valid_until is checked against the time of a shard block, which can be ahead of the masterchain.
+	sentMessageExpiryDelay = 3
+)
+
+func sentMessageDeadlineKey(deadline int64, hash tongo.Bits256) []byte {
+	return append(binary.BigEndian.AppendUint64(nil, uint64(deadline)), hash[:]...)
+}
+
+// deadline returns the time the message expires at if it is pending,
+// or the time its status is removed otherwise.
+func deadline(m core.SentMessage, finishedAt int64) int64 {
+	if m.Final() {
+		return finishedAt + int64(sentMessagesRetention.Seconds())
+	}
+	return m.ValidUntil
+}
+
+func (i *index) putSentMessage(m core.SentMessage) error {
+	value, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return i.store.Put(sentMessagesBucket, m.Hash[:], value)
+}
+
+func (i *index) sentMessage(hash tongo.Bits256) (core.SentMessage, error) {
+	value, err := i.store.Get(sentMessagesBucket, hash[:])
+	if errors.Is(err, kv.ErrNotFound) {
+		return core.SentMessage{}, core.ErrEntityNotFound
+	}
+	if err != nil {
+		return core.SentMessage{}, err
+	}
+	var m core.SentMessage
+	if err := json.Unmarshal(value, &m); err != nil {
+		return core.SentMessage{}, err
+	}
+	return m, nil
+}
+
+// sentMessageDeadlines returns hashes of messages with deadlines up to the given time inclusively
+// together with their keys in sentMessagesDeadlinesBucket, a negative time means all messages.
+func (i *index) sentMessageDeadlines(until int64) ([][]byte, []tongo.Bits256, error) {
+	var keys [][]byte
+	var hashes []tongo.Bits256
+	var to []byte
+	if until >= 0 {
+		to = binary.BigEndian.AppendUint64(nil, uint64(until)+1)
+	}
+	err := i.store.Range(sentMessagesDeadlinesBucket, nil, to, false, func(key, value []byte) bool {
+		if len(key) != 40 {
+			return true
+		}
+		keys = append(keys, append([]byte{}, key...))
+		hashes = append(hashes, tongo.Bits256(key[8:]))
+		return true
+	})
+	return keys, hashes, err
+}
+
+// loadPendingSentMessages restores messages waiting to land in the blockchain after a restart.
+func (s *LiteStorage) loadPendingSentMessages() error {
+	_, hashes, err := s.index.sentMessageDeadlines(-1)
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		m, err := s.index.sentMessage(hash)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !m.Final() {
+			s.pendingSentMessages[hash] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// runSentMessagesTracker records messages sent to the blockchain until the channel is closed.
+func (s *LiteStorage) runSentMessagesTracker(ch <-chan blockchain.ExtInMsgCopy) {
+	if ch == nil {
+		return
+	}
+	for msgCopy := range ch {
+		if msgCopy.IsEmulation() {
+			continue
+		}
+		if err := s.addSentMessage(msgCopy.Payload, time.Now()); err != nil {
+			s.logger.Warn("failed to track sent message", zap.Error(err))
+		}
+	}
+}
+
+func (s *LiteStorage) addSentMessage(payload []byte, now time.Time) error {
+	cells, err := boc.DeserializeBoc(payload)
+	if err != nil {
+		return err
+	}
+	if len(cells) != 1 {
+		return fmt.Errorf("message must contain exactly one root cell")
+	}
+	var message tlb.Message
+	if err := tlb.Unmarshal(cells[0], &message); err != nil {
+		return err
+	}
+	if message.Info.SumType != "ExtInMsgInfo" {
+		return fmt.Errorf("not an external inbound message")
+	}
+	dest, err := tongo.AccountIDFromTlb(message.Info.ExtInMsgInfo.Dest)
+	if err != nil || dest == nil {
+		return fmt.Errorf("invalid destination: %w", err)
+	}
+	m := core.SentMessage{
+		Hash:        tongo.Bits256(message.Hash(true)),
+		Destination: *dest,
+		ValidUntil:  now.Add(defaultSentMessageTTL).Unix(),
+		SentAt:      now.Unix(),
+		Status:      core.SentMessagePending,
+	}
+	cells[0].ResetCounters()
+	if validUntil, ok := blockchain.ValidUntil(cells[0]); ok {
+		m.ValidUntil = int64(validUntil)
+	}
+	m.ValidUntil = min(m.ValidUntil, now.Add(maxSentMessageTTL).Unix())
+
+	s.sentMessagesMu.Lock()
+	defer s.sentMessagesMu.Unlock()
+	// the same message can be sent several times, we keep the first record.
+	if _, err := s.index.sentMessage(m.Hash); err == nil {
+		return nil
+	} else if !errors.Is(err, core.ErrEntityNotFound) {
+		return err
+	}
+	if err := s.index.putSentMessage(m); err != nil {
+		return err
+	}
+	if err := s.index.store.Put(sentMessagesDeadlinesBucket, sentMessageDeadlineKey(deadline(m, 0), m.Hash), nil); err != nil {
+		return err
+	}
+	s.pendingSentMessages[m.Hash] = struct{}{}
+	return nil
+}
+
+// checkSentMessages looks for transactions processing pending messages in the block,
+// transactions contains the block's transactions converted by the caller.
+func (s *LiteStorage) checkSentMessages(block indexer.IDandBlock, transactions map[tongo.Bits256]*core.Transaction) {
+	s.sentMessagesMu.Lock()
+	defer s.sentMessagesMu.Unlock()
+	if len(s.pendingSentMessages) == 0 {
+		return
+	}
+	for _, tx := range block.Block.AllTransactions() {
+		if !tx.Msgs.InMsg.Exists || tx.Msgs.InMsg.Value.Value.Info.SumType != "ExtInMsgInfo" {
+			continue
+		}
+		hash := tongo.Bits256(tx.Msgs.InMsg.Value.Value.Hash(true))
+		if _, ok := s.pendingSentMessages[hash]; !ok {
+			continue
+		}
+		m, err := s.index.sentMessage(hash)
+		if err != nil {
+			s.logger.Error("failed to get sent message", zap.String("hash", hash.Hex()), zap.Error(err))
+			continue
+		}
+		transaction, ok := transactions[tongo.Bits256(tx.Hash())]
+		if !ok {
+			// the transaction couldn't be converted, the error has been logged by the caller.
+			continue
+		}
+		m.Status = core.SentMessageIncluded
+		m.TraceID = &core.TraceID{Hash: transaction.Hash, Lt: transaction.Lt}
+		if phase := transaction.ComputePhase; phase != nil {
+			exitCode := phase.ExitCode
+			m.ExitCode = &exitCode
+			if !phase.Skipped && !phase.Success {
+				m.Status = core.SentMessageFailed
+			}
+		}
+		s.finishSentMessage(m, transaction.Utime)
+	}
+}
+
+// expiryUtime remembers the time of a new masterchain block and returns the time of the block
+// sentMessageExpiryDelay blocks before it, messages are expired by that time.
+// It returns false until enough blocks are seen.
+func (s *LiteStorage) expiryUtime(utime int64) (int64, bool) {
+	s.masterchainUtimes = append(s.masterchainUtimes, utime)
+	if len(s.masterchainUtimes) <= sentMessageExpiryDelay {
+		return 0, false
+	}
+	s.masterchainUtimes = s.masterchainUtimes[len(s.masterchainUtimes)-sentMessageExpiryDelay-1:]
+	return s.masterchainUtimes[0], true
+}
+
+// expireSentMessages marks messages with valid_until not after the given time as expired
+// and removes statuses of messages finished long ago.
+// The time is taken from masterchain blocks, which come after their shard blocks,
+// so a message isn't expired while its transaction is still on its way.
+func (s *LiteStorage) expireSentMessages(utime int64) {
+	s.sentMessagesMu.Lock()
+	defer s.sentMessagesMu.Unlock()
+	keys, hashes, err := s.index.sentMessageDeadlines(utime)
+	if err != nil {
+		s.logger.Error("failed to get sent messages deadlines", zap.Error(err))
+		return
+	}
+	for n, hash := range hashes {
+		if err := s.index.store.Delete(sentMessagesDeadlinesBucket, keys[n]); err != nil {
+			s.logger.Error("failed to delete sent message deadline", zap.Error(err))
+			return
+		}
+		m, err := s.index.sentMessage(hash)
+		if errors.Is(err, core.ErrEntityNotFound) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("failed to get sent message", zap.String("hash", hash.Hex()), zap.Error(err))
+			continue
+		}
+		if m.Final() {
+			if err := s.index.store.Delete(sentMessagesBucket, hash[:]); err != nil {
+				s.logger.Error("failed to delete sent message", zap.String("hash", hash.Hex()), zap.Error(err))
+			}
+			continue
+		}
+		m.Status = core.SentMessageExpired
+		s.finishSentMessage(m, utime)
+	}
+}
+
+// finishSentMessage saves the final status of the message and notifies the receiver, s.sentMessagesMu must be held.
+func (s *LiteStorage) finishSentMessage(m core.SentMessage, finishedAt int64) {
+	delete(s.pendingSentMessages, m.Hash)
+	if err := s.index.putSentMessage(m); err != nil {
+		s.logger.Error("failed to save sent message", zap.String("hash", m.Hash.Hex()), zap.Error(err))
+		return
+	}
+	// the pending deadline is either removed already or replaced by the retention deadline.
+	_ = s.index.store.Delete(sentMessagesDeadlinesBucket, sentMessageDeadlineKey(m.ValidUntil, m.Hash))
+	if err := s.index.store.Put(sentMessagesDeadlinesBucket, sentMessageDeadlineKey(deadline(m, finishedAt), m.Hash), nil); err != nil {
+		s.logger.Error("failed to save sent message deadline", zap.String("hash", m.Hash.Hex()), zap.Error(err))
+	}
+	sentMessagesCounter.WithLabelValues(string(m.Status)).Inc()
+	if s.sentMessageStatuses == nil {
+		return
+	}
+	// every final status is delivered, so the status isn't sent here to avoid blocking block processing.
+	s.sentMessageOutboxMu.Lock()
+	s.sentMessageOutbox = append(s.sentMessageOutbox, m)
+	s.sentMessageOutboxMu.Unlock()
+	select {
+	case s.sentMessageOutboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// runSentMessageStatusSender passes final statuses of sent messages to the receiver in order,
+// waiting for the receiver as long as it takes.
+func (s *LiteStorage) runSentMessageStatusSender() {
+	for range s.sentMessageOutboxWake {
+		s.sentMessageOutboxMu.Lock()
+		statuses := s.sentMessageOutbox
+		s.sentMessageOutbox = nil
+		s.sentMessageOutboxMu.Unlock()
+		for _, m := range statuses {
+			s.sentMessageStatuses <- m
+		}
+	}
+}
+
+// GetSentMessage returns the status of an external message sent through opentonapi by its normalized hash.
+func (s *LiteStorage) GetSentMessage(ctx context.Context, hash tongo.Bits256) (*core.SentMessage, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_sent_message").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	m, err := s.index.sentMessage(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
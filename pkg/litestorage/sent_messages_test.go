@@ -0,0 +1,127 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/tlb"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func extInMessage(t *testing.T, dest tongo.AccountID, body uint32) ([]byte, tongo.Bits256) {
+	msg := tlb.Message{}
+	msg.Info.SumType = "ExtInMsgInfo"
+	msg.Info.ExtInMsgInfo = &struct {
+		Src       tlb.MsgAddress
+		Dest      tlb.MsgAddress
+		ImportFee tlb.VarUInteger16
+	}{
+		Src:  tlb.MsgAddress{SumType: "AddrNone"},
+		Dest: dest.ToMsgAddress(),
+	}
+	cell := boc.NewCell()
+	require.Nil(t, cell.WriteUint(uint64(body), 32))
+	msg.Body.Value = tlb.Any(*cell)
+	root := boc.NewCell()
+	require.Nil(t, tlb.Marshal(root, msg))
+	payload, err := root.ToBoc()
+	require.Nil(t, err)
+	return payload, tongo.Bits256(msg.Hash(true))
+}
+
+func TestLiteStorage_sentMessages(t *testing.T) {
+	wallet := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	store := kv.NewMemoryStore()
+	// the receiver isn't buffered, so statuses wait for it instead of being dropped.
+	statuses := make(chan core.SentMessage)
+	storage := &LiteStorage{
+		logger:                zap.NewNop(),
+		index:                 newIndex(store),
+		pendingSentMessages:   map[tongo.Bits256]struct{}{},
+		sentMessageStatuses:   statuses,
+		sentMessageOutboxWake: make(chan struct{}, 1),
+	}
+	go storage.runSentMessageStatusSender()
+	now := time.Unix(1_700_000_000, 0)
+
+	included, includedHash := extInMessage(t, wallet, 1)
+	expired, expiredHash := extInMessage(t, wallet, 2)
+	require.Nil(t, storage.addSentMessage(included, now))
+	require.Nil(t, storage.addSentMessage(expired, now))
+	// sending the same message again doesn't reset its record.
+	require.Nil(t, storage.addSentMessage(included, now.Add(time.Minute)))
+	require.Len(t, storage.pendingSentMessages, 2)
+
+	m, err := storage.GetSentMessage(context.Background(), includedHash)
+	require.Nil(t, err)
+	require.Equal(t, core.SentMessagePending, m.Status)
+	require.Equal(t, wallet, m.Destination)
+	require.Equal(t, now.Unix(), m.SentAt)
+	require.Equal(t, now.Add(defaultSentMessageTTL).Unix(), m.ValidUntil)
+
+	_, err = storage.GetSentMessage(context.Background(), tongo.Bits256{2})
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+
+	// pending messages survive a restart.
+	restarted := &LiteStorage{
+		logger:              zap.NewNop(),
+		index:               newIndex(store),
+		pendingSentMessages: map[tongo.Bits256]struct{}{},
+	}
+	require.Nil(t, restarted.loadPendingSentMessages())
+	require.Equal(t, storage.pendingSentMessages, restarted.pendingSentMessages)
+
+	exitCode := int32(0)
+	m.Status = core.SentMessageIncluded
+	m.TraceID = &core.TraceID{Hash: tongo.Bits256{3}, Lt: 3}
+	m.ExitCode = &exitCode
+	storage.sentMessagesMu.Lock()
+	storage.finishSentMessage(*m, now.Add(time.Minute).Unix())
+	storage.sentMessagesMu.Unlock()
+	require.Equal(t, core.SentMessageIncluded, (<-statuses).Status)
+
+	// nothing expires early, so the next status is the expired message.
+	storage.expireSentMessages(now.Add(time.Minute).Unix())
+	storage.expireSentMessages(now.Add(defaultSentMessageTTL).Unix())
+	status := <-statuses
+	require.Equal(t, expiredHash, status.Hash)
+	require.Equal(t, core.SentMessageExpired, status.Status)
+	require.Len(t, storage.pendingSentMessages, 0)
+
+	m, err = storage.GetSentMessage(context.Background(), includedHash)
+	require.Nil(t, err)
+	require.Equal(t, core.SentMessageIncluded, m.Status)
+	require.Equal(t, tongo.Bits256{3}, m.TraceID.Hash)
+
+	// final statuses are removed after the retention period.
+	storage.expireSentMessages(now.Add(time.Minute + sentMessagesRetention).Unix())
+	_, err = storage.GetSentMessage(context.Background(), includedHash)
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+	m, err = storage.GetSentMessage(context.Background(), expiredHash)
+	require.Nil(t, err)
+	require.Equal(t, core.SentMessageExpired, m.Status)
+	storage.expireSentMessages(now.Add(defaultSentMessageTTL + sentMessagesRetention).Unix())
+	_, err = storage.GetSentMessage(context.Background(), expiredHash)
+	require.ErrorIs(t, err, core.ErrEntityNotFound)
+}
+
+func TestLiteStorage_expiryUtime(t *testing.T) {
+	storage := &LiteStorage{}
+	for utime := int64(1); utime <= sentMessageExpiryDelay; utime++ {
+		_, ok := storage.expiryUtime(utime * 5)
+		require.False(t, ok)
+	}
+	for utime := int64(sentMessageExpiryDelay + 1); utime < 10; utime++ {
+		expiry, ok := storage.expiryUtime(utime * 5)
+		require.True(t, ok)
+		require.Equal(t, (utime-sentMessageExpiryDelay)*5, expiry)
+	}
+	require.Len(t, storage.masterchainUtimes, sentMessageExpiryDelay+1)
+}
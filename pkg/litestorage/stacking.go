@@ -208,14 +208,6 @@ func (s *LiteStorage) GetLiquidPool(ctx context.Context, pool tongo.AccountID) (
 	}, err
 }
 
-func (s *LiteStorage) GetLiquidPools(ctx context.Context, onlyVerified bool) ([]core.LiquidPool, error) {
-	return nil, nil
-}
-
-func (s *LiteStorage) GetParticipatingInLiquidPools(ctx context.Context, member tongo.AccountID) ([]core.Nominator, error) {
-	return nil, nil
-}
-
 func (s *LiteStorage) GetFfVaultPositionDatas(ctx context.Context, positions []tongo.AccountID) (map[tongo.AccountID]core.VaultPositionData, error) {
 	datas := make(map[tongo.AccountID]core.VaultPositionData)
 	for _, accID := range positions {
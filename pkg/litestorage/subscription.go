@@ -2,13 +2,31 @@ package litestorage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 
-	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tonkeeper/tongo"
 	"github.com/tonkeeper/tongo/abi"
+	"github.com/tonkeeper/tongo/tlb"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
 )
 
+// subscriptionV2Cancelled is a contract state of a cancelled subscription v2.
+const subscriptionV2Cancelled = 2
+
+// storedSubscription is a subscription of a wallet as it is kept in the kv store.
+// Data of the subscription is read when we see it in a trace,
+// so we can show the subscription after it is cancelled and its contract is destroyed.
+type storedSubscription struct {
+	Lt        uint64
+	Cancelled bool
+	V1        *core.SubscriptionV1 `json:",omitempty"`
+	V2        *core.SubscriptionV2 `json:",omitempty"`
+}
+
 func (s *LiteStorage) SubscriptionInfos(ctx context.Context, ids []core.SubscriptionID) (map[tongo.AccountID]core.SubscriptionInfo, error) {
 	res := make(map[tongo.AccountID]core.SubscriptionInfo)
 	for _, id := range ids {
@@ -85,3 +103,260 @@ func (s *LiteStorage) SubscriptionInfos(ctx context.Context, ids []core.Subscrip
 	}
 	return res, nil
 }
+
+func subscriptionKey(subscriber, subscription tongo.AccountID) []byte {
+	return append(accountKey(subscriber), accountKey(subscription)...)
+}
+
+func (i *index) subscription(subscriber, subscription tongo.AccountID) (storedSubscription, error) {
+	value, err := i.store.Get(subscriptionsBucket, subscriptionKey(subscriber, subscription))
+	if err != nil {
+		if errors.Is(err, kv.ErrNotFound) {
+			return storedSubscription{}, core.ErrEntityNotFound
+		}
+		return storedSubscription{}, err
+	}
+	var stored storedSubscription
+	if err := json.Unmarshal(value, &stored); err != nil {
+		return storedSubscription{}, err
+	}
+	return stored, nil
+}
+
+func (i *index) putSubscription(subscriber, subscription tongo.AccountID, stored storedSubscription) error {
+	value, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return i.store.Put(subscriptionsBucket, subscriptionKey(subscriber, subscription), value)
+}
+
+// subscriptions calls fn for every subscription of the subscriber seen in traces until fn returns false.
+func (i *index) subscriptions(subscriber tongo.AccountID, fn func(subscription tongo.AccountID, stored storedSubscription) bool) error {
+	prefix := accountKey(subscriber)
+	var decodeErr error
+	err := i.store.Range(subscriptionsBucket, prefix, kv.PrefixEnd(prefix), false, func(key, value []byte) bool {
+		var stored storedSubscription
+		if decodeErr = json.Unmarshal(value, &stored); decodeErr != nil {
+			return false
+		}
+		return fn(accountFromKey(key[len(prefix):]), stored)
+	})
+	if err != nil {
+		return err
+	}
+	return decodeErr
+}
+
+// indexSubscriptionEvents keeps the latest known state of subscriptions seen in a trace.
+func (s *LiteStorage) indexSubscriptionEvents(ctx context.Context, events []core.SubscriptionEvent) error {
+	for _, event := range events {
+		if event.Subscriber == (tongo.AccountID{}) || event.Subscription == (tongo.AccountID{}) {
+			// the subscription contract didn't provide its data.
+			continue
+		}
+		stored, err := s.index.subscription(event.Subscriber, event.Subscription)
+		if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+			return err
+		}
+		if stored.Lt > event.Lt {
+			continue
+		}
+		stored.Lt, stored.Cancelled = event.Lt, event.Cancelled
+		if !event.Cancelled {
+			v1, v2, err := s.readSubscription(ctx, event.Subscription)
+			if err != nil {
+				return err
+			}
+			if v1 != nil || v2 != nil {
+				stored.V1, stored.V2 = v1, v2
+			}
+		}
+		if err := s.index.putSubscription(event.Subscriber, event.Subscription, stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSubscription reads data of an active subscription contract.
+// It returns nils if the account is not an active subscription v1 or v2.
+func (s *LiteStorage) readSubscription(ctx context.Context, account tongo.AccountID) (*core.SubscriptionV1, *core.SubscriptionV2, error) {
+	raw, err := s.GetRawAccount(ctx, account)
+	if errors.Is(err, core.ErrEntityNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if raw.Status != tlb.AccountActive {
+		return nil, nil, nil
+	}
+	interfaces, err := s.getAccountInterfaces(ctx, account)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, iface := range interfaces {
+		switch iface {
+		case abi.SubscriptionV1:
+			_, value, err := abi.GetSubscriptionData(ctx, s.executor, account)
+			if err != nil && isGetMethodFailure(err) {
+				return nil, nil, nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			data, ok := value.(abi.GetSubscriptionDataResult)
+			if !ok {
+				return nil, nil, nil
+			}
+			return &core.SubscriptionV1{
+				AccountID:            account,
+				WalletAccountID:      tongo.AccountID{Workchain: int32(data.Wallet.Workchain), Address: data.Wallet.Address},
+				BeneficiaryAccountID: tongo.AccountID{Workchain: int32(data.Beneficiary.Workchain), Address: data.Beneficiary.Address},
+				Status:               raw.Status,
+				Amount:               int64(data.Amount),
+				Period:               int64(data.Period),
+				StartTime:            int64(data.StartTime),
+				Timeout:              int64(data.Timeout),
+				LastPaymentTime:      int64(data.LastPaymentTime),
+				LastRequestTime:      int64(data.LastRequestTime),
+				FailedAttempts:       int32(data.FailedAttempts),
+				SubscriptionID:       int64(data.SubscriptionId),
+				IndexerLastUpdateLt:  int64(raw.LastTransactionLt),
+			}, nil, nil
+		case abi.SubscriptionV2:
+			_, value, err := abi.GetSubscriptionInfo(ctx, s.executor, account)
+			if err != nil && isGetMethodFailure(err) {
+				return nil, nil, nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			info, ok := value.(abi.GetSubscriptionInfo_V2Result)
+			if !ok {
+				return nil, nil, nil
+			}
+			_, value, err = abi.GetPaymentInfo(ctx, s.executor, account)
+			if err != nil && isGetMethodFailure(err) {
+				return nil, nil, nil
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			payment, ok := value.(abi.GetPaymentInfo_SubscriptionV2Result)
+			if !ok {
+				return nil, nil, nil
+			}
+			sub := &core.SubscriptionV2{
+				AccountID:        account,
+				SubscriptionID:   int64(info.SubscriptionId),
+				Metadata:         info.Metadata,
+				ContractState:    int(payment.ContractState),
+				PaymentPerPeriod: int64(payment.PaymentPerPeriod),
+				Period:           int64(payment.Period),
+				ChargeDate:       int64(payment.ChargeDate),
+				GracePeriod:      int64(payment.GracePeriod),
+				LastRequestTime:  int64(payment.LastRequestTime),
+				CallerFee:        int64(payment.CallerFee),
+			}
+			for _, addr := range []struct {
+				src tlb.MsgAddress
+				dst *tongo.AccountID
+			}{
+				{info.Wallet, &sub.WalletAccountID},
+				{info.Admin, &sub.AdminAccountID},
+				{info.WithdrawAddress, &sub.WithdrawAccountID},
+			} {
+				id, err := tongo.AccountIDFromTlb(addr.src)
+				if err != nil {
+					return nil, nil, err
+				}
+				if id != nil {
+					*addr.dst = *id
+				}
+			}
+			return nil, sub, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// walletSubscriptions returns subscriptions of the wallet found in its plugin list and in its history.
+// Active subscriptions are read from the blockchain,
+// cancelled ones are returned the way we saw them last time.
+func (s *LiteStorage) walletSubscriptions(ctx context.Context, wallet tongo.AccountID) ([]core.SubscriptionV1, []core.SubscriptionV2, error) {
+	var candidates []tongo.AccountID
+	seen := make(map[tongo.AccountID]storedSubscription)
+	interfaces, err := s.getAccountInterfaces(ctx, wallet)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, iface := range interfaces {
+		if iface != abi.WalletV4R1 && iface != abi.WalletV4R2 && iface != abi.WalletV5Beta && iface != abi.WalletV5R1 {
+			continue
+		}
+		plugins, err := s.GetAccountPlugins(ctx, wallet, iface)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, plugin := range plugins {
+			if plugin.Type == abi.SubscriptionV1.String() || plugin.Type == abi.SubscriptionV2.String() {
+				candidates = append(candidates, plugin.AccountID)
+				seen[plugin.AccountID] = storedSubscription{}
+			}
+		}
+		break
+	}
+	err = s.index.subscriptions(wallet, func(subscription tongo.AccountID, stored storedSubscription) bool {
+		if _, ok := seen[subscription]; !ok {
+			candidates = append(candidates, subscription)
+		}
+		seen[subscription] = stored
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var subscriptionsV1 []core.SubscriptionV1
+	var subscriptionsV2 []core.SubscriptionV2
+	for _, account := range candidates {
+		v1, v2, err := s.readSubscription(ctx, account)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case v1 != nil && v1.WalletAccountID == wallet:
+			subscriptionsV1 = append(subscriptionsV1, *v1)
+		case v2 != nil && v2.WalletAccountID == wallet:
+			subscriptionsV2 = append(subscriptionsV2, *v2)
+		case seen[account].V1 != nil:
+			sub := *seen[account].V1
+			sub.Status = tlb.AccountNone
+			subscriptionsV1 = append(subscriptionsV1, sub)
+		case seen[account].V2 != nil:
+			sub := *seen[account].V2
+			sub.ContractState = subscriptionV2Cancelled
+			subscriptionsV2 = append(subscriptionsV2, sub)
+		}
+	}
+	return subscriptionsV1, subscriptionsV2, nil
+}
+
+func (s *LiteStorage) GetSubscriptionsV2(ctx context.Context, address tongo.AccountID) ([]core.SubscriptionV2, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_subscriptions_v2").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	_, subscriptions, err := s.walletSubscriptions(ctx, address)
+	return subscriptions, err
+}
+
+func (s *LiteStorage) GetSubscriptionsV1(ctx context.Context, address tongo.AccountID) ([]core.SubscriptionV1, error) {
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("get_subscriptions_v1").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	subscriptions, _, err := s.walletSubscriptions(ctx, address)
+	return subscriptions, err
+}
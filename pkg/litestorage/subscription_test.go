@@ -0,0 +1,37 @@
+package litestorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+)
+
+func TestLiteStorage_subscriptions(t *testing.T) {
+	wallet := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	subscription := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	storage := &LiteStorage{index: newIndex(kv.NewMemoryStore())}
+	v1 := &core.SubscriptionV1{AccountID: subscription, WalletAccountID: wallet, Amount: 100, Period: 3600}
+	require.Nil(t, storage.index.putSubscription(wallet, subscription, storedSubscription{Lt: 10, V1: v1}))
+
+	ctx := context.Background()
+	require.Nil(t, storage.indexSubscriptionEvents(ctx, []core.SubscriptionEvent{
+		{Subscription: subscription, Subscriber: wallet, Cancelled: true, Lt: 20},
+		// events older than the stored state are ignored.
+		{Subscription: subscription, Subscriber: wallet, Lt: 5},
+		// the subscription contract didn't provide its wallet.
+		{Subscription: subscription, Lt: 30},
+	}))
+	var subscriptions []tongo.AccountID
+	err := storage.index.subscriptions(wallet, func(account tongo.AccountID, stored storedSubscription) bool {
+		subscriptions = append(subscriptions, account)
+		require.Equal(t, storedSubscription{Lt: 20, Cancelled: true, V1: v1}, stored)
+		return true
+	})
+	require.Nil(t, err)
+	require.Equal(t, []tongo.AccountID{subscription}, subscriptions)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/tonkeeper/tongo"
 	"github.com/tonkeeper/tongo/abi"
 	"github.com/tonkeeper/tongo/boc"
+	"go.uber.org/zap"
 
 	"github.com/tonkeeper/opentonapi/pkg/core"
 )
@@ -28,6 +29,10 @@ func (s *LiteStorage) GetTrace(ctx context.Context, hash tongo.Bits256) (*core.T
 		storageTimeHistogramVec.WithLabelValues("get_trace").Observe(v)
 	}))
 	defer timer.ObserveDuration()
+	if trace, ok := s.assembledTrace(hash); ok {
+		s.setAccountInterfaces(ctx, trace)
+		return trace, nil
+	}
 	tx, err := s.GetTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
@@ -36,16 +41,74 @@ func (s *LiteStorage) GetTrace(ctx context.Context, hash tongo.Bits256) (*core.T
 	if err != nil {
 		return nil, err
 	}
-	trace, err := s.recursiveGetChildren(ctx, *root, 0)
-	return &trace, err
+	return s.recursiveGetChildren(ctx, *root, 0)
+}
+
+// assembledTrace returns a trace built from the block stream,
+// it is either a finished trace from the index or a trace still in progress.
+func (s *LiteStorage) assembledTrace(hash tongo.Bits256) (*core.Trace, bool) {
+	if root, ok := s.index.traceRoot(hash); ok {
+		if trace, err := s.index.getTrace(root.Hash); err == nil {
+			return trace, true
+		}
+	}
+	return s.traceAssembler.Trace(hash)
+}
+
+func (s *LiteStorage) setAccountInterfaces(ctx context.Context, trace *core.Trace) {
+	core.Visit(trace, func(node *core.Trace) {
+		if len(node.AccountInterfaces) > 0 {
+			return
+		}
+		interfaces, err := s.getAccountInterfaces(ctx, node.Account)
+		if err != nil {
+			s.logger.Warn("failed to get account interfaces", zap.String("accountID", node.Account.String()), zap.Error(err))
+			return
+		}
+		node.AccountInterfaces = interfaces
+	})
 }
 
+// SearchTraces returns traces the given account took part in.
+// Only traces in the index are considered, so the account has to be tracked.
+// Roots of traces are resolved when transactions are indexed, so searching doesn't query lite servers.
+// Filters and ordering are applied to the root transaction of each trace.
 func (s *LiteStorage) SearchTraces(ctx context.Context, a tongo.AccountID, limit int, beforeLT, afterLT, startTime, endTime *int64, initiator bool, descendingOrder bool) ([]core.TraceID, error) {
-	return nil, nil
+	timer := prometheus.NewTimer(prometheus.ObserverFunc(func(v float64) {
+		storageTimeHistogramVec.WithLabelValues("search_traces").Observe(v)
+	}))
+	defer timer.ObserveDuration()
+	if limit <= 0 || (beforeLT != nil && *beforeLT <= 0) {
+		return nil, nil
+	}
+	var fromLt, toLt uint64
+	if afterLT != nil {
+		fromLt = uint64(*afterLT) + 1
+	}
+	if beforeLT != nil {
+		toLt = uint64(*beforeLT)
+	}
+	var ids []core.TraceID
+	err := s.index.walkAccountTraces(a, fromLt, toLt, descendingOrder, func(t accountTrace) bool {
+		switch {
+		case startTime != nil && t.ID.UTime < *startTime:
+			return true
+		case endTime != nil && t.ID.UTime > *endTime:
+			return true
+		case initiator && t.Initiator != a:
+			return true
+		}
+		ids = append(ids, t.ID)
+		return len(ids) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
-func (s *LiteStorage) recursiveGetChildren(ctx context.Context, tx core.Transaction, depth int) (core.Trace, error) {
-	trace := core.Trace{Transaction: tx}
+func (s *LiteStorage) recursiveGetChildren(ctx context.Context, tx core.Transaction, depth int) (*core.Trace, error) {
+	trace := &core.Trace{Transaction: tx}
 	externalMessages := make([]core.Message, 0, len(tx.OutMsgs))
 	for _, m := range tx.OutMsgs {
 		if m.Destination == nil {
@@ -54,18 +117,18 @@ func (s *LiteStorage) recursiveGetChildren(ctx context.Context, tx core.Transact
 		}
 		tx, err := s.searchTransactionNearBlock(ctx, *m.Destination, m.CreatedLt, tx.BlockID, false, depth+1)
 		if err != nil {
-			return core.Trace{}, err
+			return nil, err
 		}
 		child, err := s.recursiveGetChildren(ctx, *tx, depth+1)
 		if err != nil {
-			return core.Trace{}, err
+			return nil, err
 		}
-		trace.Children = append(trace.Children, &child)
+		trace.Children = append(trace.Children, child)
 	}
 	var err error
 	trace.AccountInterfaces, err = s.getAccountInterfaces(ctx, tx.Account)
 	if err != nil {
-		return core.Trace{}, nil
+		s.logger.Warn("failed to get account interfaces", zap.String("accountID", tx.Account.String()), zap.Error(err))
 	}
 	trace.OutMsgs = externalMessages
 	return trace, nil
@@ -116,13 +179,13 @@ func (s *LiteStorage) searchTransactionInBlock(ctx context.Context, a tongo.Acco
 	if err != nil {
 		return nil, err
 	}
-	block, prs := s.blockCache.Load(blockIDExt)
+	block, prs := s.blockCache.Get(blockIDExt)
 	if !prs {
 		b, err := s.client.GetBlock(ctx, blockIDExt)
 		if err != nil {
 			return nil, err
 		}
-		s.blockCache.Store(blockIDExt, &b)
+		s.blockCache.Set(blockIDExt, &b)
 		block = &b
 	}
 	for _, tx := range block.AllTransactions() {
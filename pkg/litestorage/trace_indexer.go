@@ -0,0 +1,223 @@
+package litestorage
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+var traceIndexingUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "lite_storage_trace_indexing_total",
+	Help: "Number of transactions whose traces have been checked by the trace indexer",
+}, []string{"result"})
+
+const (
+	// traceIndexingBatchSize is a number of queued transactions read from the kv store at once.
+	traceIndexingBatchSize = 100
+	// traceIndexingRetryInterval defines how often transactions whose traces failed to be indexed are checked again.
+	traceIndexingRetryInterval = 30 * time.Second
+)
+
+// TraceEntity is anything litestorage indexes from traces.
+type TraceEntity interface {
+	core.JettonOperation | core.DomainBid | core.InvoicePayment | core.SubscriptionEvent | core.NftTransfer
+}
+
+// TraceExtractor finds entities of the given type in a trace.
+// Implementations live in the bath package, e.g. bath.FindJettonOperations,
+// litestorage can't call them directly because bath depends on litestorage in tests.
+type TraceExtractor[T TraceEntity] func(ctx context.Context, source core.InformationSource, trace *core.Trace) ([]T, error)
+
+// traceExtractors contains extractors used by the trace indexer, nil extractors are skipped.
+type traceExtractors struct {
+	jettonOperations TraceExtractor[core.JettonOperation]
+	auctionBids      TraceExtractor[core.DomainBid]
+	invoicePayments  TraceExtractor[core.InvoicePayment]
+	subscriptions    TraceExtractor[core.SubscriptionEvent]
+	// nftTransfers is used by the NFT index for traces of all accounts, not only tracked ones.
+	nftTransfers TraceExtractor[core.NftTransfer]
+}
+
+// empty reports whether the trace indexer has nothing to look for in traces of tracked accounts.
+func (e traceExtractors) empty() bool {
+	return e.jettonOperations == nil && e.auctionBids == nil && e.invoicePayments == nil && e.subscriptions == nil
+}
+
+// WithTraceExtractor enables indexing of entities found by the extractor in traces of tracked accounts:
+// jetton operations for the jetton history, auction bids for DNS auctions,
+// invoice payments for the purchase history and subscription events for wallet subscriptions.
+// NFT transfers are looked for in all traces to keep the NFT index up to date.
+func WithTraceExtractor[T TraceEntity](extractor TraceExtractor[T]) Option {
+	return func(o *Options) {
+		switch e := any(extractor).(type) {
+		case TraceExtractor[core.JettonOperation]:
+			o.traceExtractors.jettonOperations = e
+		case TraceExtractor[core.DomainBid]:
+			o.traceExtractors.auctionBids = e
+		case TraceExtractor[core.InvoicePayment]:
+			o.traceExtractors.invoicePayments = e
+		case TraceExtractor[core.SubscriptionEvent]:
+			o.traceExtractors.subscriptions = e
+		case TraceExtractor[core.NftTransfer]:
+			o.traceExtractors.nftTransfers = e
+		}
+	}
+}
+
+// indexEntities runs the extractor, if set, and stores what it has found.
+func indexEntities[T TraceEntity](ctx context.Context, s *LiteStorage, trace *core.Trace, extractor TraceExtractor[T], store func([]T) error) error {
+	if extractor == nil {
+		return nil
+	}
+	entities, err := extractor(ctx, s, trace)
+	if err != nil {
+		return err
+	}
+	return store(entities)
+}
+
+// TraceListener is notified about completed traces.
+// It is called by the block processing loop, so a slow listener delays indexing.
+type TraceListener func(trace *core.Trace)
+
+// SetTraceListener configures a listener of completed traces of tracked accounts,
+// the listener is called once a trace is stored.
+func (s *LiteStorage) SetTraceListener(listener TraceListener) {
+	s.traceListener.Store(&listener)
+}
+
+// SetChainTraceListener configures a listener of all completed traces on chain, tracked or not.
+// Traces of untracked accounts aren't stored, so the listener can't expect GetTrace to return them.
+func (s *LiteStorage) SetChainTraceListener(listener TraceListener) {
+	s.chainTraceListener.Store(&listener)
+}
+
+// enqueueTraceIndexing schedules a trace containing the given transaction to be stored in the index,
+// if it isn't there yet, and checked for jetton operations, auction bids, invoice payments and subscriptions.
+// The queue is kept in the kv store, so neither block processing nor preloading waits for the trace indexer,
+// and nothing is lost when the indexer falls behind or the node restarts.
+func (s *LiteStorage) enqueueTraceIndexing(hash tongo.Bits256) {
+	if err := s.index.store.Put(traceIndexingQueueBucket, hash[:], []byte{1}); err != nil {
+		traceIndexingUpdates.WithLabelValues("error").Inc()
+		s.logger.Error("failed to enqueue trace indexing", zap.String("tx", hash.Hex()), zap.Error(err))
+		return
+	}
+	select {
+	case s.traceIndexingWake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *LiteStorage) runTraceIndexer() {
+	ctx := context.Background()
+	ticker := time.NewTicker(traceIndexingRetryInterval)
+	defer ticker.Stop()
+	var from []byte
+	for {
+		next, more := s.indexQueuedTraces(ctx, from)
+		if more {
+			from = next
+			continue
+		}
+		// transactions that failed are left in the queue and checked again with the next pass.
+		from = nil
+		select {
+		case <-s.traceIndexingWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// indexQueuedTraces indexes traces of a batch of queued transactions starting from the given key.
+// It returns the key to continue from and whether there can be more transactions after the batch.
+func (s *LiteStorage) indexQueuedTraces(ctx context.Context, from []byte) ([]byte, bool) {
+	var hashes []tongo.Bits256
+	err := s.index.store.Range(traceIndexingQueueBucket, from, nil, false, func(key, value []byte) bool {
+		hashes = append(hashes, tongo.Bits256(key))
+		return len(hashes) < traceIndexingBatchSize
+	})
+	if err != nil {
+		s.logger.Error("failed to read trace indexing queue", zap.Error(err))
+		return nil, false
+	}
+	for _, hash := range hashes {
+		trace, err := s.indexTrace(ctx, hash)
+		if err != nil {
+			traceIndexingUpdates.WithLabelValues("error").Inc()
+			s.logger.Warn("failed to index trace", zap.String("tx", hash.Hex()), zap.Error(err))
+			continue
+		}
+		traceIndexingUpdates.WithLabelValues("success").Inc()
+		s.dequeueTraceIndexing(hash)
+		if trace != nil {
+			// preloading queues every transaction of a trace, the trace has to be indexed only once.
+			core.Visit(trace, func(t *core.Trace) {
+				s.dequeueTraceIndexing(t.Hash)
+			})
+		}
+	}
+	if len(hashes) < traceIndexingBatchSize {
+		return nil, false
+	}
+	last := hashes[len(hashes)-1]
+	return append(bytes.Clone(last[:]), 0), true
+}
+
+func (s *LiteStorage) dequeueTraceIndexing(hash tongo.Bits256) {
+	if err := s.index.store.Delete(traceIndexingQueueBucket, hash[:]); err != nil {
+		s.logger.Error("failed to dequeue trace indexing", zap.String("tx", hash.Hex()), zap.Error(err))
+	}
+}
+
+// indexTrace indexes a completed trace containing the given transaction.
+// It returns the indexed trace, or nil if there is nothing to index yet.
+func (s *LiteStorage) indexTrace(ctx context.Context, hash tongo.Bits256) (*core.Trace, error) {
+	if root, ok := s.index.traceRoot(hash); ok {
+		if _, ok := s.indexedTraces.Get(root.Hash); ok || s.traceExtractors.empty() {
+			return nil, nil
+		}
+	}
+	trace, err := s.GetTrace(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if trace.InProgress() {
+		// the trace is indexed once it is completed.
+		return nil, nil
+	}
+	if _, ok := s.index.traceRoot(trace.Hash); !ok {
+		// a trace of a preloaded transaction, SearchTraces finds it by the roots kept along with the trace.
+		if err := s.index.storeTrace(trace); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := s.indexedTraces.Get(trace.Hash); ok {
+		return trace, nil
+	}
+	extractors := s.traceExtractors
+	if err := indexEntities(ctx, s, trace, extractors.jettonOperations, s.index.storeJettonOperations); err != nil {
+		return nil, err
+	}
+	if err := indexEntities(ctx, s, trace, extractors.auctionBids, func(bids []core.DomainBid) error {
+		return s.indexAuctionBids(ctx, bids)
+	}); err != nil {
+		return nil, err
+	}
+	if err := indexEntities(ctx, s, trace, extractors.invoicePayments, s.index.storeInvoicePayments); err != nil {
+		return nil, err
+	}
+	if err := indexEntities(ctx, s, trace, extractors.subscriptions, func(events []core.SubscriptionEvent) error {
+		return s.indexSubscriptionEvents(ctx, events)
+	}); err != nil {
+		return nil, err
+	}
+	s.indexedTraces.Set(trace.Hash, struct{}{})
+	return trace, nil
+}
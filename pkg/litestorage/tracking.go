@@ -0,0 +1,140 @@
+package litestorage
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+var accountPreloads = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "lite_storage_account_preloads_total",
+	Help: "Number of accounts tracked at runtime whose history has been loaded",
+}, []string{"result"})
+
+// preloadQueueSize is a number of accounts tracked at runtime waiting for their history to be loaded.
+const preloadQueueSize = 10_000
+
+// TrackedAccount describes an account tracked by LiteStorage.
+type TrackedAccount struct {
+	AccountID tongo.AccountID
+	// Preloaded is true once the account's history has been loaded into the index.
+	Preloaded bool
+}
+
+// IsTracked reports whether transactions and traces of the account are kept in the index.
+func (s *LiteStorage) IsTracked(a tongo.AccountID) bool {
+	return s.isTracked(a)
+}
+
+func (s *LiteStorage) isTracked(a tongo.AccountID) bool {
+	s.trackingMu.RLock()
+	defer s.trackingMu.RUnlock()
+	_, ok := s.trackingAccounts[a]
+	return ok
+}
+
+func (s *LiteStorage) involvesTrackedAccount(trace *core.Trace) bool {
+	for _, a := range core.DistinctAccounts(trace) {
+		if s.isTracked(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTrackedAccounts starts tracking the given accounts and accounts tracked at runtime before a restart,
+// except for those untracked at runtime. It returns accounts whose history has to be loaded.
+func (s *LiteStorage) loadTrackedAccounts(accounts []tongo.AccountID) ([]tongo.AccountID, error) {
+	tracked, untracked, err := s.index.trackedAccounts()
+	if err != nil {
+		return nil, err
+	}
+	removed := make(map[tongo.AccountID]struct{}, len(untracked))
+	for _, a := range untracked {
+		removed[a] = struct{}{}
+	}
+	var preload []tongo.AccountID
+	for _, a := range slices.Concat(accounts, tracked) {
+		if _, ok := removed[a]; ok {
+			continue
+		}
+		if _, ok := s.trackingAccounts[a]; ok {
+			continue
+		}
+		s.trackingAccounts[a] = struct{}{}
+		preload = append(preload, a)
+	}
+	return preload, nil
+}
+
+// TrackedAccounts returns all accounts tracked by LiteStorage.
+func (s *LiteStorage) TrackedAccounts() []TrackedAccount {
+	s.trackingMu.RLock()
+	accounts := make([]TrackedAccount, 0, len(s.trackingAccounts))
+	for a := range s.trackingAccounts {
+		accounts = append(accounts, TrackedAccount{AccountID: a})
+	}
+	s.trackingMu.RUnlock()
+	for i := range accounts {
+		accounts[i].Preloaded = s.index.isPreloaded(accounts[i].AccountID)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].AccountID.String() < accounts[j].AccountID.String()
+	})
+	return accounts
+}
+
+// TrackAccount starts tracking the given account.
+// The account's history is loaded in the background, so it becomes available a bit later.
+func (s *LiteStorage) TrackAccount(a tongo.AccountID) error {
+	s.trackingMu.Lock()
+	defer s.trackingMu.Unlock()
+	if _, ok := s.trackingAccounts[a]; ok {
+		return nil
+	}
+	if err := s.index.addTrackedAccount(a); err != nil {
+		return err
+	}
+	s.trackingAccounts[a] = struct{}{}
+	select {
+	case s.preloadQueue <- a:
+	default:
+		// the account is tracked anyway, its history is loaded on the next start.
+		accountPreloads.WithLabelValues("dropped").Inc()
+		s.logger.Warn("preload queue is full", zap.String("accountID", a.String()))
+	}
+	return nil
+}
+
+// runPreloader loads the history of accounts tracked at runtime.
+func (s *LiteStorage) runPreloader() {
+	for a := range s.preloadQueue {
+		if err := s.preloadAccount(a); err != nil {
+			accountPreloads.WithLabelValues("error").Inc()
+			s.logger.Error("failed to preload account",
+				zap.String("accountID", a.String()),
+				zap.Error(err))
+			continue
+		}
+		accountPreloads.WithLabelValues("success").Inc()
+	}
+}
+
+// UntrackAccount stops tracking the given account.
+// Already indexed transactions are kept.
+// The account stays untracked after a restart even if it is listed in the ACCOUNTS env variable.
+func (s *LiteStorage) UntrackAccount(a tongo.AccountID) error {
+	s.trackingMu.Lock()
+	defer s.trackingMu.Unlock()
+	if err := s.index.removeTrackedAccount(a); err != nil {
+		return err
+	}
+	delete(s.trackingAccounts, a)
+	return nil
+}
@@ -125,6 +125,12 @@ type Invoker interface {
 	//
 	// GET /v2/accounts/{account_id}
 	GetAccount(ctx context.Context, params GetAccountParams) (*Account, error)
+	// GetAccountBalanceHistory invokes getAccountBalanceHistory operation.
+	//
+	// Get chart of account's balance in TON or in a jetton.
+	//
+	// GET /v2/accounts/{account_id}/balance-history
+	GetAccountBalanceHistory(ctx context.Context, params GetAccountBalanceHistoryParams) (*GetAccountBalanceHistoryOK, error)
 	// GetAccountDefiAssets invokes getAccountDefiAssets operation.
 	//
 	// Return DeFi assets locked in custom smart contracts: currently returns TON Whales staking and EVAA
@@ -322,6 +328,12 @@ type Invoker interface {
 	//
 	// GET /v2/blockchain/masterchain/{masterchain_seqno}/transactions
 	GetBlockchainMasterchainTransactions(ctx context.Context, params GetBlockchainMasterchainTransactionsParams) (*Transactions, error)
+	// GetBlockchainMessageStatus invokes getBlockchainMessageStatus operation.
+	//
+	// Get the status of an external message sent with sendBlockchainMessage by its normalized hash.
+	//
+	// GET /v2/blockchain/messages/{msg_id}/status
+	GetBlockchainMessageStatus(ctx context.Context, params GetBlockchainMessageStatusParams) (*SentMessageStatus, error)
 	// GetBlockchainRawAccount invokes getBlockchainRawAccount operation.
 	//
 	// Get low-level information about an account taken directly from the blockchain.
@@ -2437,6 +2449,165 @@ func (c *Client) sendGetAccount(ctx context.Context, params GetAccountParams) (r
 	return result, nil
 }
 
+// GetAccountBalanceHistory invokes getAccountBalanceHistory operation.
+//
+// Get chart of account's balance in TON or in a jetton.
+//
+// GET /v2/accounts/{account_id}/balance-history
+func (c *Client) GetAccountBalanceHistory(ctx context.Context, params GetAccountBalanceHistoryParams) (*GetAccountBalanceHistoryOK, error) {
+	res, err := c.sendGetAccountBalanceHistory(ctx, params)
+	return res, err
+}
+
+func (c *Client) sendGetAccountBalanceHistory(ctx context.Context, params GetAccountBalanceHistoryParams) (res *GetAccountBalanceHistoryOK, err error) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID("getAccountBalanceHistory"),
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.URLTemplateKey.String("/v2/accounts/{account_id}/balance-history"),
+	}
+	otelAttrs = append(otelAttrs, c.cfg.Attributes...)
+
+	// Run stopwatch.
+	startTime := time.Now()
+	defer func() {
+		// Use floating point division here for higher precision (instead of Millisecond method).
+		elapsedDuration := time.Since(startTime)
+		c.duration.Record(ctx, float64(elapsedDuration)/float64(time.Millisecond), metric.WithAttributes(otelAttrs...))
+	}()
+
+	// Increment request counter.
+	c.requests.Add(ctx, 1, metric.WithAttributes(otelAttrs...))
+
+	// Start a span for this request.
+	ctx, span := c.cfg.Tracer.Start(ctx, GetAccountBalanceHistoryOperation,
+		trace.WithAttributes(otelAttrs...),
+		clientSpanKind,
+	)
+	// Track stage for error reporting.
+	var stage string
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, stage)
+			c.errors.Add(ctx, 1, metric.WithAttributes(otelAttrs...))
+		}
+		span.End()
+	}()
+
+	stage = "BuildURL"
+	u := uri.Clone(c.requestURL(ctx))
+	var pathParts [3]string
+	pathParts[0] = "/v2/accounts/"
+	{
+		// Encode "account_id" parameter.
+		e := uri.NewPathEncoder(uri.PathEncoderConfig{
+			Param:   "account_id",
+			Style:   uri.PathStyleSimple,
+			Explode: false,
+		})
+		if err := func() error {
+			return e.EncodeValue(conv.StringToString(params.AccountID))
+		}(); err != nil {
+			return res, errors.Wrap(err, "encode path")
+		}
+		encoded, err := e.Result()
+		if err != nil {
+			return res, errors.Wrap(err, "encode path")
+		}
+		pathParts[1] = encoded
+	}
+	pathParts[2] = "/balance-history"
+	uri.AddPathParts(u, pathParts[:]...)
+
+	stage = "EncodeQueryParams"
+	q := uri.NewQueryEncoder()
+	{
+		// Encode "start_date" parameter.
+		cfg := uri.QueryParameterEncodingConfig{
+			Name:    "start_date",
+			Style:   uri.QueryStyleForm,
+			Explode: true,
+		}
+
+		if err := q.EncodeParam(cfg, func(e uri.Encoder) error {
+			return e.EncodeValue(conv.Int64ToString(params.StartDate))
+		}); err != nil {
+			return res, errors.Wrap(err, "encode query")
+		}
+	}
+	{
+		// Encode "end_date" parameter.
+		cfg := uri.QueryParameterEncodingConfig{
+			Name:    "end_date",
+			Style:   uri.QueryStyleForm,
+			Explode: true,
+		}
+
+		if err := q.EncodeParam(cfg, func(e uri.Encoder) error {
+			return e.EncodeValue(conv.Int64ToString(params.EndDate))
+		}); err != nil {
+			return res, errors.Wrap(err, "encode query")
+		}
+	}
+	{
+		// Encode "resolution" parameter.
+		cfg := uri.QueryParameterEncodingConfig{
+			Name:    "resolution",
+			Style:   uri.QueryStyleForm,
+			Explode: true,
+		}
+
+		if err := q.EncodeParam(cfg, func(e uri.Encoder) error {
+			if val, ok := params.Resolution.Get(); ok {
+				return e.EncodeValue(conv.Int64ToString(val))
+			}
+			return nil
+		}); err != nil {
+			return res, errors.Wrap(err, "encode query")
+		}
+	}
+	{
+		// Encode "jetton" parameter.
+		cfg := uri.QueryParameterEncodingConfig{
+			Name:    "jetton",
+			Style:   uri.QueryStyleForm,
+			Explode: true,
+		}
+
+		if err := q.EncodeParam(cfg, func(e uri.Encoder) error {
+			if val, ok := params.Jetton.Get(); ok {
+				return e.EncodeValue(conv.StringToString(val))
+			}
+			return nil
+		}); err != nil {
+			return res, errors.Wrap(err, "encode query")
+		}
+	}
+	u.RawQuery = q.Values().Encode()
+
+	stage = "EncodeRequest"
+	r, err := ht.NewRequest(ctx, "GET", u)
+	if err != nil {
+		return res, errors.Wrap(err, "create request")
+	}
+
+	stage = "SendRequest"
+	resp, err := c.cfg.Client.Do(r)
+	if err != nil {
+		return res, errors.Wrap(err, "do request")
+	}
+	body := resp.Body
+	defer body.Close()
+
+	stage = "DecodeResponse"
+	result, err := decodeGetAccountBalanceHistoryResponse(resp)
+	if err != nil {
+		return res, errors.Wrap(err, "decode response")
+	}
+
+	return result, nil
+}
+
 // GetAccountDefiAssets invokes getAccountDefiAssets operation.
 //
 // Return DeFi assets locked in custom smart contracts: currently returns TON Whales staking and EVAA
@@ -6226,6 +6397,99 @@ func (c *Client) sendGetBlockchainMasterchainTransactions(ctx context.Context, p
 	return result, nil
 }
 
+// GetBlockchainMessageStatus invokes getBlockchainMessageStatus operation.
+//
+// Get the status of an external message sent with sendBlockchainMessage by its normalized hash.
+//
+// GET /v2/blockchain/messages/{msg_id}/status
+func (c *Client) GetBlockchainMessageStatus(ctx context.Context, params GetBlockchainMessageStatusParams) (*SentMessageStatus, error) {
+	res, err := c.sendGetBlockchainMessageStatus(ctx, params)
+	return res, err
+}
+
+func (c *Client) sendGetBlockchainMessageStatus(ctx context.Context, params GetBlockchainMessageStatusParams) (res *SentMessageStatus, err error) {
+	otelAttrs := []attribute.KeyValue{
+		otelogen.OperationID("getBlockchainMessageStatus"),
+		semconv.HTTPRequestMethodKey.String("GET"),
+		semconv.URLTemplateKey.String("/v2/blockchain/messages/{msg_id}/status"),
+	}
+	otelAttrs = append(otelAttrs, c.cfg.Attributes...)
+
+	// Run stopwatch.
+	startTime := time.Now()
+	defer func() {
+		// Use floating point division here for higher precision (instead of Millisecond method).
+		elapsedDuration := time.Since(startTime)
+		c.duration.Record(ctx, float64(elapsedDuration)/float64(time.Millisecond), metric.WithAttributes(otelAttrs...))
+	}()
+
+	// Increment request counter.
+	c.requests.Add(ctx, 1, metric.WithAttributes(otelAttrs...))
+
+	// Start a span for this request.
+	ctx, span := c.cfg.Tracer.Start(ctx, GetBlockchainMessageStatusOperation,
+		trace.WithAttributes(otelAttrs...),
+		clientSpanKind,
+	)
+	// Track stage for error reporting.
+	var stage string
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, stage)
+			c.errors.Add(ctx, 1, metric.WithAttributes(otelAttrs...))
+		}
+		span.End()
+	}()
+
+	stage = "BuildURL"
+	u := uri.Clone(c.requestURL(ctx))
+	var pathParts [3]string
+	pathParts[0] = "/v2/blockchain/messages/"
+	{
+		// Encode "msg_id" parameter.
+		e := uri.NewPathEncoder(uri.PathEncoderConfig{
+			Param:   "msg_id",
+			Style:   uri.PathStyleSimple,
+			Explode: false,
+		})
+		if err := func() error {
+			return e.EncodeValue(conv.StringToString(params.MsgID))
+		}(); err != nil {
+			return res, errors.Wrap(err, "encode path")
+		}
+		encoded, err := e.Result()
+		if err != nil {
+			return res, errors.Wrap(err, "encode path")
+		}
+		pathParts[1] = encoded
+	}
+	pathParts[2] = "/status"
+	uri.AddPathParts(u, pathParts[:]...)
+
+	stage = "EncodeRequest"
+	r, err := ht.NewRequest(ctx, "GET", u)
+	if err != nil {
+		return res, errors.Wrap(err, "create request")
+	}
+
+	stage = "SendRequest"
+	resp, err := c.cfg.Client.Do(r)
+	if err != nil {
+		return res, errors.Wrap(err, "do request")
+	}
+	body := resp.Body
+	defer body.Close()
+
+	stage = "DecodeResponse"
+	result, err := decodeGetBlockchainMessageStatusResponse(resp)
+	if err != nil {
+		return res, errors.Wrap(err, "decode response")
+	}
+
+	return result, nil
+}
+
 // GetBlockchainRawAccount invokes getBlockchainRawAccount operation.
 //
 // Get low-level information about an account taken directly from the blockchain.
@@ -22,6 +22,7 @@ const (
 	GaslessEstimateOperation                           OperationName = "GaslessEstimate"
 	GaslessSendOperation                               OperationName = "GaslessSend"
 	GetAccountOperation                                OperationName = "GetAccount"
+	GetAccountBalanceHistoryOperation                  OperationName = "GetAccountBalanceHistory"
 	GetAccountDefiAssetsOperation                      OperationName = "GetAccountDefiAssets"
 	GetAccountDiffOperation                            OperationName = "GetAccountDiff"
 	GetAccountDnsExpiringOperation                     OperationName = "GetAccountDnsExpiring"
@@ -53,6 +54,7 @@ const (
 	GetBlockchainMasterchainHeadOperation              OperationName = "GetBlockchainMasterchainHead"
 	GetBlockchainMasterchainShardsOperation            OperationName = "GetBlockchainMasterchainShards"
 	GetBlockchainMasterchainTransactionsOperation      OperationName = "GetBlockchainMasterchainTransactions"
+	GetBlockchainMessageStatusOperation                OperationName = "GetBlockchainMessageStatus"
 	GetBlockchainRawAccountOperation                   OperationName = "GetBlockchainRawAccount"
 	GetBlockchainRawAccountsOperation                  OperationName = "GetBlockchainRawAccounts"
 	GetBlockchainTransactionOperation                  OperationName = "GetBlockchainTransaction"
@@ -1931,6 +1931,32 @@ func (s *Auctions) SetTotal(val int64) {
 	s.Total = val
 }
 
+// Ref: #/components/schemas/BalanceHistoryPoint
+type BalanceHistoryPoint struct {
+	Utime   int64  `json:"utime"`
+	Balance string `json:"balance"`
+}
+
+// GetUtime returns the value of Utime.
+func (s *BalanceHistoryPoint) GetUtime() int64 {
+	return s.Utime
+}
+
+// GetBalance returns the value of Balance.
+func (s *BalanceHistoryPoint) GetBalance() string {
+	return s.Balance
+}
+
+// SetUtime sets the value of Utime.
+func (s *BalanceHistoryPoint) SetUtime(val int64) {
+	s.Utime = val
+}
+
+// SetBalance sets the value of Balance.
+func (s *BalanceHistoryPoint) SetBalance(val string) {
+	s.Balance = val
+}
+
 // Ref: #/components/schemas/BlockCurrencyCollection
 type BlockCurrencyCollection struct {
 	Grams int64                              `json:"grams"`
@@ -7164,6 +7190,20 @@ func (s *GaslessTx) SetExternal(val OptString) {
 	s.External = val
 }
 
+type GetAccountBalanceHistoryOK struct {
+	Points []BalanceHistoryPoint `json:"points"`
+}
+
+// GetPoints returns the value of Points.
+func (s *GetAccountBalanceHistoryOK) GetPoints() []BalanceHistoryPoint {
+	return s.Points
+}
+
+// SetPoints sets the value of Points.
+func (s *GetAccountBalanceHistoryOK) SetPoints(val []BalanceHistoryPoint) {
+	s.Points = val
+}
+
 type GetAccountDiffOK struct {
 	BalanceChange int64 `json:"balance_change"`
 }
@@ -10887,7 +10927,9 @@ type MigrationTransaction struct {
 	// body — sign and wrap it for /v2/gasless/send as in the gasless flow.
 	Boc string `json:"boc"`
 	// True — the Battery relay pays gas for this transaction; submit it via /v2/gasless/send. false
-	// — self-paid; sign and broadcast via /v2/blockchain/message as usual (e.g. the final TON sweep).
+	// — self-paid; sign and broadcast via /v2/blockchain/message as usual. The final TON sweep is
+	// always self-paid, whatever gas_payer says: the relay does not sponsor a TON-only batch, and
+	// gasless has no jetton balance left to bill a commission against.
 	Sponsored OptBool `json:"sponsored"`
 	// Gasless only; the relay commission in indivisible gas-jetton units, embedded in the boc as a
 	// jetton transfer to the relay. Exact for the first transaction; an estimate for later ones
@@ -20083,6 +20125,148 @@ func (s *SendRawMessageReq) SetBody(val string) {
 	s.Body = val
 }
 
+// Ref: #/components/schemas/SentMessageStatus
+type SentMessageStatus struct {
+	// Normalized hash of the message.
+	Hash string `json:"hash"`
+	// Included means the message has been processed, failed means it has been processed but the compute
+	// phase failed.
+	Status      SentMessageStatusStatus `json:"status"`
+	Destination string                  `json:"destination"`
+	ValidUntil  int64                   `json:"valid_until"`
+	SentAt      int64                   `json:"sent_at"`
+	// Hash of the transaction that processed the message, it is the ID of the resulting trace.
+	TraceID  OptString `json:"trace_id"`
+	ExitCode OptInt32  `json:"exit_code"`
+}
+
+// GetHash returns the value of Hash.
+func (s *SentMessageStatus) GetHash() string {
+	return s.Hash
+}
+
+// GetStatus returns the value of Status.
+func (s *SentMessageStatus) GetStatus() SentMessageStatusStatus {
+	return s.Status
+}
+
+// GetDestination returns the value of Destination.
+func (s *SentMessageStatus) GetDestination() string {
+	return s.Destination
+}
+
+// GetValidUntil returns the value of ValidUntil.
+func (s *SentMessageStatus) GetValidUntil() int64 {
+	return s.ValidUntil
+}
+
+// GetSentAt returns the value of SentAt.
+func (s *SentMessageStatus) GetSentAt() int64 {
+	return s.SentAt
+}
+
+// GetTraceID returns the value of TraceID.
+func (s *SentMessageStatus) GetTraceID() OptString {
+	return s.TraceID
+}
+
+// GetExitCode returns the value of ExitCode.
+func (s *SentMessageStatus) GetExitCode() OptInt32 {
+	return s.ExitCode
+}
+
+// SetHash sets the value of Hash.
+func (s *SentMessageStatus) SetHash(val string) {
+	s.Hash = val
+}
+
+// SetStatus sets the value of Status.
+func (s *SentMessageStatus) SetStatus(val SentMessageStatusStatus) {
+	s.Status = val
+}
+
+// SetDestination sets the value of Destination.
+func (s *SentMessageStatus) SetDestination(val string) {
+	s.Destination = val
+}
+
+// SetValidUntil sets the value of ValidUntil.
+func (s *SentMessageStatus) SetValidUntil(val int64) {
+	s.ValidUntil = val
+}
+
+// SetSentAt sets the value of SentAt.
+func (s *SentMessageStatus) SetSentAt(val int64) {
+	s.SentAt = val
+}
+
+// SetTraceID sets the value of TraceID.
+func (s *SentMessageStatus) SetTraceID(val OptString) {
+	s.TraceID = val
+}
+
+// SetExitCode sets the value of ExitCode.
+func (s *SentMessageStatus) SetExitCode(val OptInt32) {
+	s.ExitCode = val
+}
+
+// Included means the message has been processed, failed means it has been processed but the compute
+// phase failed.
+type SentMessageStatusStatus string
+
+const (
+	SentMessageStatusStatusPending  SentMessageStatusStatus = "pending"
+	SentMessageStatusStatusIncluded SentMessageStatusStatus = "included"
+	SentMessageStatusStatusFailed   SentMessageStatusStatus = "failed"
+	SentMessageStatusStatusExpired  SentMessageStatusStatus = "expired"
+)
+
+// AllValues returns all SentMessageStatusStatus values.
+func (SentMessageStatusStatus) AllValues() []SentMessageStatusStatus {
+	return []SentMessageStatusStatus{
+		SentMessageStatusStatusPending,
+		SentMessageStatusStatusIncluded,
+		SentMessageStatusStatusFailed,
+		SentMessageStatusStatusExpired,
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s SentMessageStatusStatus) MarshalText() ([]byte, error) {
+	switch s {
+	case SentMessageStatusStatusPending:
+		return []byte(s), nil
+	case SentMessageStatusStatusIncluded:
+		return []byte(s), nil
+	case SentMessageStatusStatusFailed:
+		return []byte(s), nil
+	case SentMessageStatusStatusExpired:
+		return []byte(s), nil
+	default:
+		return nil, errors.Errorf("invalid value: %q", s)
+	}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *SentMessageStatusStatus) UnmarshalText(data []byte) error {
+	switch SentMessageStatusStatus(data) {
+	case SentMessageStatusStatusPending:
+		*s = SentMessageStatusStatusPending
+		return nil
+	case SentMessageStatusStatusIncluded:
+		*s = SentMessageStatusStatusIncluded
+		return nil
+	case SentMessageStatusStatusFailed:
+		*s = SentMessageStatusStatusFailed
+		return nil
+	case SentMessageStatusStatusExpired:
+		*s = SentMessageStatusStatusExpired
+		return nil
+	default:
+		return errors.Errorf("invalid value: %q", data)
+	}
+}
+
 // Ref: #/components/schemas/Seqno
 type Seqno struct {
 	Seqno int32 `json:"seqno"`
@@ -23060,6 +23244,12 @@ type WalletStats struct {
 	JettonsCount  int32 `json:"jettons_count"`
 	MultisigCount int32 `json:"multisig_count"`
 	StakingCount  int32 `json:"staking_count"`
+	// Number of transactions of the account known to the node.
+	TxCount OptInt64 `json:"tx_count"`
+	// Unix time of the first transaction of the account known to the node.
+	FirstActivity OptInt64 `json:"first_activity"`
+	// Unix time of the last transaction of the account known to the node.
+	LastActivity OptInt64 `json:"last_activity"`
 }
 
 // GetNftsCount returns the value of NftsCount.
@@ -23082,6 +23272,21 @@ func (s *WalletStats) GetStakingCount() int32 {
 	return s.StakingCount
 }
 
+// GetTxCount returns the value of TxCount.
+func (s *WalletStats) GetTxCount() OptInt64 {
+	return s.TxCount
+}
+
+// GetFirstActivity returns the value of FirstActivity.
+func (s *WalletStats) GetFirstActivity() OptInt64 {
+	return s.FirstActivity
+}
+
+// GetLastActivity returns the value of LastActivity.
+func (s *WalletStats) GetLastActivity() OptInt64 {
+	return s.LastActivity
+}
+
 // SetNftsCount sets the value of NftsCount.
 func (s *WalletStats) SetNftsCount(val int32) {
 	s.NftsCount = val
@@ -23102,6 +23307,21 @@ func (s *WalletStats) SetStakingCount(val int32) {
 	s.StakingCount = val
 }
 
+// SetTxCount sets the value of TxCount.
+func (s *WalletStats) SetTxCount(val OptInt64) {
+	s.TxCount = val
+}
+
+// SetFirstActivity sets the value of FirstActivity.
+func (s *WalletStats) SetFirstActivity(val OptInt64) {
+	s.FirstActivity = val
+}
+
+// SetLastActivity sets the value of LastActivity.
+func (s *WalletStats) SetLastActivity(val OptInt64) {
+	s.LastActivity = val
+}
+
 // Ref: #/components/schemas/Wallets
 type Wallets struct {
 	Accounts []Wallet `json:"accounts"`
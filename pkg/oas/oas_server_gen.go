@@ -107,6 +107,12 @@ type Handler interface {
 	//
 	// GET /v2/accounts/{account_id}
 	GetAccount(ctx context.Context, params GetAccountParams) (*Account, error)
+	// GetAccountBalanceHistory implements getAccountBalanceHistory operation.
+	//
+	// Get chart of account's balance in TON or in a jetton.
+	//
+	// GET /v2/accounts/{account_id}/balance-history
+	GetAccountBalanceHistory(ctx context.Context, params GetAccountBalanceHistoryParams) (*GetAccountBalanceHistoryOK, error)
 	// GetAccountDefiAssets implements getAccountDefiAssets operation.
 	//
 	// Return DeFi assets locked in custom smart contracts: currently returns TON Whales staking and EVAA
@@ -304,6 +310,12 @@ type Handler interface {
 	//
 	// GET /v2/blockchain/masterchain/{masterchain_seqno}/transactions
 	GetBlockchainMasterchainTransactions(ctx context.Context, params GetBlockchainMasterchainTransactionsParams) (*Transactions, error)
+	// GetBlockchainMessageStatus implements getBlockchainMessageStatus operation.
+	//
+	// Get the status of an external message sent with sendBlockchainMessage by its normalized hash.
+	//
+	// GET /v2/blockchain/messages/{msg_id}/status
+	GetBlockchainMessageStatus(ctx context.Context, params GetBlockchainMessageStatusParams) (*SentMessageStatus, error)
 	// GetBlockchainRawAccount implements getBlockchainRawAccount operation.
 	//
 	// Get low-level information about an account taken directly from the blockchain.
@@ -159,6 +159,15 @@ func (UnimplementedHandler) GetAccount(ctx context.Context, params GetAccountPar
 	return r, ht.ErrNotImplemented
 }
 
+// GetAccountBalanceHistory implements getAccountBalanceHistory operation.
+//
+// Get chart of account's balance in TON or in a jetton.
+//
+// GET /v2/accounts/{account_id}/balance-history
+func (UnimplementedHandler) GetAccountBalanceHistory(ctx context.Context, params GetAccountBalanceHistoryParams) (r *GetAccountBalanceHistoryOK, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
 // GetAccountDefiAssets implements getAccountDefiAssets operation.
 //
 // Return DeFi assets locked in custom smart contracts: currently returns TON Whales staking and EVAA
@@ -449,6 +458,15 @@ func (UnimplementedHandler) GetBlockchainMasterchainTransactions(ctx context.Con
 	return r, ht.ErrNotImplemented
 }
 
+// GetBlockchainMessageStatus implements getBlockchainMessageStatus operation.
+//
+// Get the status of an external message sent with sendBlockchainMessage by its normalized hash.
+//
+// GET /v2/blockchain/messages/{msg_id}/status
+func (UnimplementedHandler) GetBlockchainMessageStatus(ctx context.Context, params GetBlockchainMessageStatusParams) (r *SentMessageStatus, _ error) {
+	return r, ht.ErrNotImplemented
+}
+
 // GetBlockchainRawAccount implements getBlockchainRawAccount operation.
 //
 // Get low-level information about an account taken directly from the blockchain.
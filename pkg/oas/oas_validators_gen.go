@@ -3145,6 +3145,29 @@ func (s *GaslessEstimateReq) Validate() error {
 	return nil
 }
 
+func (s *GetAccountBalanceHistoryOK) Validate() error {
+	if s == nil {
+		return validate.ErrNilPointer
+	}
+
+	var failures []validate.FieldError
+	if err := func() error {
+		if s.Points == nil {
+			return errors.New("nil is invalid value")
+		}
+		return nil
+	}(); err != nil {
+		failures = append(failures, validate.FieldError{
+			Name:  "points",
+			Error: err,
+		})
+	}
+	if len(failures) > 0 {
+		return &validate.Error{Fields: failures}
+	}
+	return nil
+}
+
 func (s GetAccountEventsSortOrder) Validate() error {
 	switch s {
 	case "desc":
@@ -6066,6 +6089,44 @@ func (s *SendBlockchainMessageReq) Validate() error {
 	return nil
 }
 
+func (s *SentMessageStatus) Validate() error {
+	if s == nil {
+		return validate.ErrNilPointer
+	}
+
+	var failures []validate.FieldError
+	if err := func() error {
+		if err := s.Status.Validate(); err != nil {
+			return err
+		}
+		return nil
+	}(); err != nil {
+		failures = append(failures, validate.FieldError{
+			Name:  "status",
+			Error: err,
+		})
+	}
+	if len(failures) > 0 {
+		return &validate.Error{Fields: failures}
+	}
+	return nil
+}
+
+func (s SentMessageStatusStatus) Validate() error {
+	switch s {
+	case "pending":
+		return nil
+	case "included":
+		return nil
+	case "failed":
+		return nil
+	case "expired":
+		return nil
+	default:
+		return errors.Errorf("invalid value: %v", s)
+	}
+}
+
 func (s *SignRawParams) Validate() error {
 	if s == nil {
 		return validate.ErrNilPointer
@@ -0,0 +1,489 @@
+package sources
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/ton"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/blockchain/indexer"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/litestorage"
+)
+
+var (
+	activeSubscriptions = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pusher_active_subscriptions",
+		Help: "Number of active subscriptions to blockchain events",
+	}, []string{"kind"})
+	droppedAccountEvents = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pusher_dropped_account_events_total",
+		Help: "Number of account events dropped because the queue of events to build was full",
+	})
+)
+
+const (
+	// accountEventsQueueSize is a number of account events waiting to be built.
+	accountEventsQueueSize = 1_000
+	// replayAccountEventsLimit is a max number of account events built on resume,
+	// a client that has missed more gets a reset event.
+	replayAccountEventsLimit = 1_000
+	// replayIndexedTracesLimit is a max number of traces of an account read from the indexed history on resume,
+	// a client that has missed more gets a reset event.
+	replayIndexedTracesLimit = 1_000
+)
+
+type subscriber struct {
+	deliver DeliveryFn
+}
+
+// subscribers maps an account to subscribers interested in it.
+type subscribers map[tongo.AccountID]map[*subscriber]struct{}
+
+func (s subscribers) add(accounts []tongo.AccountID, sub *subscriber) {
+	for _, a := range accounts {
+		if s[a] == nil {
+			s[a] = map[*subscriber]struct{}{}
+		}
+		s[a][sub] = struct{}{}
+	}
+}
+
+// all returns subscribers of all accounts.
+func (s subscribers) all() map[*subscriber]struct{} {
+	result := map[*subscriber]struct{}{}
+	for _, subs := range s {
+		for sub := range subs {
+			result[sub] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (s subscribers) remove(accounts []tongo.AccountID, sub *subscriber) {
+	for _, a := range accounts {
+		delete(s[a], sub)
+		if len(s[a]) == 0 {
+			delete(s, a)
+		}
+	}
+}
+
+// pendingAccountEvent is an event of the account to build from a completed trace.
+type pendingAccountEvent struct {
+	account tongo.AccountID
+	traceID core.TraceID
+	seq     uint64
+}
+
+// BlockchainSource reads blocks from the indexer and notifies subscribers
+// about new transactions, completed traces and events of their accounts.
+// Completed traces are assembled by LiteStorage and passed to OnTrace.
+type BlockchainSource struct {
+	logger       *zap.Logger
+	eventBuilder litestorage.AccountEventBuilder
+	traceHistory TraceHistory
+	eventsQueue  chan pendingAccountEvent
+	// eventsLost is set once an account event is dropped,
+	// subscribers of account events get a reset event before the next one.
+	eventsLost atomic.Bool
+	// transactions and traces keep recent events to resume streams,
+	// events of accounts are built from traces and share their sequence.
+	transactions *history
+	traces       *history
+
+	mu               sync.RWMutex
+	txSubscribers    subscribers
+	traceSubscribers subscribers
+	eventSubscribers subscribers
+	blockSubscribers map[*subscriber]struct{}
+}
+
+type Options struct {
+	eventBuilder litestorage.AccountEventBuilder
+	traceHistory TraceHistory
+}
+
+type Option func(o *Options)
+
+// WithAccountEventBuilder configures a builder of account events,
+// subscribers of account events get nothing without it.
+func WithAccountEventBuilder(builder litestorage.AccountEventBuilder) Option {
+	return func(o *Options) {
+		o.eventBuilder = builder
+	}
+}
+
+// WithTraceHistory lets clients resume streams of traces and account events of tracked accounts
+// from positions that aren't among recent events anymore.
+func WithTraceHistory(history TraceHistory) Option {
+	return func(o *Options) {
+		o.traceHistory = history
+	}
+}
+
+func NewBlockchainSource(logger *zap.Logger, opts ...Option) *BlockchainSource {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &BlockchainSource{
+		logger:           logger,
+		eventBuilder:     o.eventBuilder,
+		traceHistory:     o.traceHistory,
+		eventsQueue:      make(chan pendingAccountEvent, accountEventsQueueSize),
+		transactions:     newHistory(),
+		traces:           newHistory(),
+		txSubscribers:    subscribers{},
+		traceSubscribers: subscribers{},
+		eventSubscribers: subscribers{},
+		blockSubscribers: map[*subscriber]struct{}{},
+	}
+}
+
+// Run processes blocks until the subscription is closed.
+// The subscription can drop blocks, subscribers of transactions and blocks get a reset event once it happens.
+func (b *BlockchainSource) Run(ctx context.Context, sub *indexer.Subscription) {
+	go b.buildAccountEvents(ctx)
+	defer close(b.eventsQueue)
+	var dropped uint64
+	for block := range sub.C() {
+		if n := sub.Dropped(); n != dropped {
+			b.dispatchReset(fmt.Sprintf("%d blocks were dropped", n-dropped))
+			dropped = n
+		}
+		if block.ID.Workchain == -1 {
+			b.dispatchBlock(block)
+		}
+		b.dispatchTransactions(block)
+	}
+}
+
+// OnTrace is a litestorage.TraceListener of all completed traces,
+// it delivers the trace and events of its accounts to subscribers.
+func (b *BlockchainSource) OnTrace(trace *core.Trace) {
+	b.dispatchTrace(trace)
+}
+
+func (b *BlockchainSource) subscribe(kind string, subs subscribers, accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn {
+	sub := &subscriber{deliver: deliveryFn}
+	b.mu.Lock()
+	subs.add(accounts, sub)
+	b.mu.Unlock()
+	activeSubscriptions.WithLabelValues(kind).Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			subs.remove(accounts, sub)
+			b.mu.Unlock()
+			activeSubscriptions.WithLabelValues(kind).Dec()
+		})
+	}
+}
+
+func (b *BlockchainSource) SubscribeToTransactions(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn {
+	return b.subscribe("transactions", b.txSubscribers, accounts, deliveryFn)
+}
+
+func (b *BlockchainSource) SubscribeToTraces(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn {
+	return b.subscribe("traces", b.traceSubscribers, accounts, deliveryFn)
+}
+
+func (b *BlockchainSource) SubscribeToAccountEvents(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn {
+	return b.subscribe("account_events", b.eventSubscribers, accounts, deliveryFn)
+}
+
+func (b *BlockchainSource) SubscribeToBlocks(deliveryFn DeliveryFn) CancelFn {
+	sub := &subscriber{deliver: deliveryFn}
+	b.mu.Lock()
+	b.blockSubscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	activeSubscriptions.WithLabelValues("blocks").Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.blockSubscribers, sub)
+			b.mu.Unlock()
+			activeSubscriptions.WithLabelValues("blocks").Dec()
+		})
+	}
+}
+
+// deliver sends the event to the given subscribers, b.mu must be held.
+func deliver(subs map[*subscriber]struct{}, event Event) {
+	for sub := range subs {
+		sub.deliver(event)
+	}
+}
+
+func (b *BlockchainSource) dispatchBlock(block indexer.IDandBlock) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.blockSubscribers) == 0 {
+		return
+	}
+	data := BlockEventData{
+		Workchain: block.ID.Workchain,
+		Shard:     fmt.Sprintf("%016x", block.ID.Shard),
+		Seqno:     block.ID.Seqno,
+		RootHash:  block.ID.RootHash.Hex(),
+		FileHash:  block.ID.FileHash.Hex(),
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		b.logger.Error("failed to marshal block event", zap.Error(err))
+		return
+	}
+	// the seqno is a position in the stream of masterchain blocks.
+	deliver(b.blockSubscribers, Event{Seq: uint64(data.Seqno), Key: data.RootHash, Data: value})
+}
+
+// dispatchTransactions records all transactions of the block, so a client can resume the stream,
+// and delivers them to subscribers.
+func (b *BlockchainSource) dispatchTransactions(block indexer.IDandBlock) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, tx := range block.Block.AllTransactions() {
+		account := *ton.NewAccountID(block.ID.Workchain, tx.AccountAddr)
+		data := TransactionEventData{
+			AccountID: account.ToRaw(),
+			Lt:        tx.Lt,
+			TxHash:    tongo.Bits256(tx.Hash()).Hex(),
+		}
+		value, err := json.Marshal(data)
+		if err != nil {
+			b.logger.Error("failed to marshal transaction event", zap.Error(err))
+			continue
+		}
+		event := b.transactions.add(historyEntry{
+			accounts: []tongo.AccountID{account},
+			event:    Event{Key: data.TxHash, Data: value},
+		})
+		deliver(b.txSubscribers[account], event)
+	}
+}
+
+func (b *BlockchainSource) dispatchTrace(trace *core.Trace) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	// a subscriber of several accounts involved in the trace gets the trace once.
+	traceSubs := map[*subscriber]struct{}{}
+	var eventAccounts []tongo.AccountID
+	seen := map[tongo.AccountID]struct{}{}
+	core.Visit(trace, func(node *core.Trace) {
+		if _, ok := seen[node.Account]; ok {
+			return
+		}
+		seen[node.Account] = struct{}{}
+		for sub := range b.traceSubscribers[node.Account] {
+			traceSubs[sub] = struct{}{}
+		}
+		if _, ok := b.eventSubscribers[node.Account]; ok {
+			eventAccounts = append(eventAccounts, node.Account)
+		}
+	})
+	data := TraceEventData{
+		AccountIDs: TraceAccounts(trace),
+		Lt:         trace.Lt,
+		Hash:       trace.Hash.Hex(),
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		b.logger.Error("failed to marshal trace event", zap.Error(err))
+		return
+	}
+	traceID := core.TraceID{Hash: trace.Hash, Lt: trace.Lt, UTime: trace.Utime}
+	// traces are delivered in the order they complete, which isn't the order of their lt.
+	event := b.traces.add(historyEntry{
+		accounts: slices.Collect(maps.Keys(seen)),
+		traceID:  traceID,
+		event:    Event{Key: data.Hash, Lt: trace.Lt, Data: value},
+	})
+	deliver(traceSubs, event)
+	if b.eventBuilder == nil {
+		return
+	}
+	for _, account := range eventAccounts {
+		b.enqueueAccountEvent(pendingAccountEvent{account: account, traceID: traceID, seq: event.Seq})
+	}
+}
+
+func (b *BlockchainSource) enqueueAccountEvent(pending pendingAccountEvent) {
+	select {
+	case b.eventsQueue <- pending:
+	default:
+		// building an event can be slow, we don't want to slow down block processing.
+		droppedAccountEvents.Inc()
+		b.eventsLost.Store(true)
+	}
+}
+
+// dispatchReset notifies subscribers of transactions and blocks that some events are lost.
+// Traces don't depend on blocks read by the source, so their subscribers aren't affected.
+func (b *BlockchainSource) dispatchReset(reason string) {
+	b.logger.Warn("pusher lost blockchain events", zap.String("reason", reason))
+	txReset := b.transactions.recordReset(reason)
+	b.mu.RLock()
+	deliver(b.txSubscribers.all(), txReset)
+	deliver(b.blockSubscribers, resetEvent(reason))
+	b.mu.RUnlock()
+}
+
+// buildAccountEvents builds events of completed traces in the background
+// and delivers them to subscribers of the accounts.
+func (b *BlockchainSource) buildAccountEvents(ctx context.Context) {
+	for pending := range b.eventsQueue {
+		if b.eventsLost.Swap(false) {
+			b.mu.RLock()
+			deliver(b.eventSubscribers.all(), resetEvent("account events were dropped"))
+			b.mu.RUnlock()
+		}
+		event, ok := b.buildAccountEvent(ctx, pending.account, pending.traceID, pending.seq)
+		if !ok {
+			continue
+		}
+		b.mu.RLock()
+		deliver(b.eventSubscribers[pending.account], event)
+		b.mu.RUnlock()
+	}
+}
+
+// Replay returns events of the given accounts after the given position.
+// Recent events are kept in memory, events of accounts are built again from recorded traces.
+// Older traces and events of tracked accounts are read from the indexed history if the position has an lt.
+func (b *BlockchainSource) Replay(ctx context.Context, stream Stream, accounts []tongo.AccountID, from Position) []Event {
+	h := b.transactions
+	if stream != StreamTransactions {
+		h = b.traces
+	}
+	entries, current, ok := h.since(from.Seq, accounts)
+	if ok && stream == StreamAccountEvents && (b.eventBuilder == nil || len(entries) > replayAccountEventsLimit) {
+		ok = false
+	}
+	if !ok {
+		if events, ok := b.replayIndexed(ctx, stream, accounts, from.Lt, current); ok {
+			return events
+		}
+		reset := resetEvent("events are not available anymore")
+		reset.Seq = current
+		reset.Key = strconv.FormatUint(current, 10)
+		return []Event{reset}
+	}
+	var events []Event
+	for _, entry := range entries {
+		if stream != StreamAccountEvents || entry.event.Name == ResetEvent {
+			events = append(events, entry.event)
+			continue
+		}
+		for _, account := range entry.accounts {
+			if !slices.Contains(accounts, account) {
+				continue
+			}
+			if event, ok := b.buildAccountEvent(ctx, account, entry.traceID, entry.event.Seq); ok {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+// replayIndexed returns traces or events of the given accounts started after the given lt from the indexed history.
+// Traces recorded in memory up to the current position are in the index already,
+// so the returned events get the current position and a client resuming from them continues with recent events.
+// It returns false if some of the events can't be found in the index.
+func (b *BlockchainSource) replayIndexed(ctx context.Context, stream Stream, accounts []tongo.AccountID, lt, current uint64) ([]Event, bool) {
+	if lt == 0 || stream == StreamTransactions || b.traceHistory == nil {
+		return nil, false
+	}
+	if stream == StreamAccountEvents && b.eventBuilder == nil {
+		return nil, false
+	}
+	afterLT := int64(lt)
+	traceIDs := map[tongo.Bits256]core.TraceID{}
+	traceAccounts := map[tongo.Bits256][]tongo.AccountID{}
+	for _, account := range accounts {
+		if !b.traceHistory.IsTracked(account) {
+			return nil, false
+		}
+		ids, err := b.traceHistory.SearchTraces(ctx, account, replayIndexedTracesLimit+1, nil, &afterLT, nil, nil, false, false)
+		if err != nil {
+			b.logger.Warn("failed to search traces", zap.String("accountID", account.ToRaw()), zap.Error(err))
+			return nil, false
+		}
+		if len(ids) > replayIndexedTracesLimit {
+			return nil, false
+		}
+		for _, id := range ids {
+			traceIDs[id.Hash] = id
+			traceAccounts[id.Hash] = append(traceAccounts[id.Hash], account)
+		}
+	}
+	if stream == StreamAccountEvents {
+		count := 0
+		for _, accounts := range traceAccounts {
+			count += len(accounts)
+		}
+		if count > replayAccountEventsLimit {
+			return nil, false
+		}
+	}
+	ids := slices.Collect(maps.Values(traceIDs))
+	slices.SortFunc(ids, func(x, y core.TraceID) int {
+		return cmp.Compare(x.Lt, y.Lt)
+	})
+	var events []Event
+	for _, id := range ids {
+		if stream == StreamAccountEvents {
+			for _, account := range traceAccounts[id.Hash] {
+				if event, ok := b.buildAccountEvent(ctx, account, id, current); ok {
+					events = append(events, event)
+				}
+			}
+			continue
+		}
+		trace, err := b.traceHistory.GetTrace(ctx, id.Hash)
+		if err != nil {
+			b.logger.Warn("failed to get trace", zap.String("hash", id.Hash.Hex()), zap.Error(err))
+			return nil, false
+		}
+		value, err := json.Marshal(TraceEventData{
+			AccountIDs: TraceAccounts(trace),
+			Lt:         trace.Lt,
+			Hash:       trace.Hash.Hex(),
+		})
+		if err != nil {
+			b.logger.Error("failed to marshal trace event", zap.Error(err))
+			continue
+		}
+		events = append(events, Event{Seq: current, Lt: id.Lt, Key: id.Hash.Hex(), Data: value})
+	}
+	return events, true
+}
+
+func (b *BlockchainSource) buildAccountEvent(ctx context.Context, account tongo.AccountID, traceID core.TraceID, seq uint64) (Event, bool) {
+	event := b.eventBuilder(ctx, account, traceID)
+	value, err := event.MarshalJSON()
+	if err != nil {
+		b.logger.Error("failed to marshal account event", zap.Error(err))
+		return Event{}, false
+	}
+	return Event{
+		Seq:  seq,
+		Lt:   traceID.Lt,
+		Key:  AccountEventKey(account, traceID.Hash),
+		Data: value,
+	}, true
+}
@@ -0,0 +1,167 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/oas"
+)
+
+func TestBlockchainSource_dispatchTrace(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	source := NewBlockchainSource(zap.NewNop(), WithAccountEventBuilder(func(ctx context.Context, account tongo.AccountID, traceID core.TraceID) oas.AccountEvent {
+		return oas.AccountEvent{EventID: traceID.Hash.Hex(), Account: oas.AccountAddress{Address: account.ToRaw()}, Lt: int64(traceID.Lt)}
+	}))
+	go source.buildAccountEvents(context.Background())
+	defer close(source.eventsQueue)
+
+	var traces []Event
+	cancel := source.SubscribeToTraces([]tongo.AccountID{a, b}, func(event Event) {
+		traces = append(traces, event)
+	})
+	events := make(chan Event, 10)
+	source.SubscribeToAccountEvents([]tongo.AccountID{b}, func(event Event) {
+		events <- event
+	})
+
+	trace := &core.Trace{
+		Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{10}, Lt: 10, Account: a}},
+		Children: []*core.Trace{
+			{Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{11}, Lt: 11, Account: b}}},
+		},
+	}
+	source.dispatchTrace(trace)
+	// the subscriber of both accounts gets the trace once.
+	require.Len(t, traces, 1)
+	var data TraceEventData
+	require.Nil(t, json.Unmarshal(traces[0].Data, &data))
+	require.Equal(t, []string{a.ToRaw(), b.ToRaw()}, data.AccountIDs)
+	seq := traces[0].Seq
+	require.NotZero(t, seq)
+
+	select {
+	case event := <-events:
+		require.Equal(t, AccountEventKey(b, trace.Hash), event.Key)
+		// an event of the account shares the position of its trace.
+		require.Equal(t, seq, event.Seq)
+	case <-time.After(time.Second):
+		t.Fatal("account event wasn't delivered")
+	}
+
+	cancel()
+	// a trace with a lower lt completes later and still gets a higher position.
+	older := &core.Trace{
+		Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{5}, Lt: 5, Account: a}},
+	}
+	source.dispatchTrace(older)
+	require.Len(t, traces, 1)
+
+	replayed := source.Replay(context.Background(), StreamTraces, []tongo.AccountID{a}, Position{Seq: seq})
+	require.Len(t, replayed, 1)
+	require.Equal(t, older.Hash.Hex(), replayed[0].Key)
+	require.Equal(t, seq+1, replayed[0].Seq)
+	replayed = source.Replay(context.Background(), StreamAccountEvents, []tongo.AccountID{b}, Position{Seq: seq - 1})
+	require.Len(t, replayed, 1)
+	require.Equal(t, AccountEventKey(b, trace.Hash), replayed[0].Key)
+	require.Equal(t, seq, replayed[0].Seq)
+	require.Empty(t, source.Replay(context.Background(), StreamTraces, []tongo.AccountID{b}, Position{Seq: seq}))
+
+	// positions of a previous run aren't in the history.
+	replayed = source.Replay(context.Background(), StreamTraces, []tongo.AccountID{a}, Position{Seq: 1})
+	require.Len(t, replayed, 1)
+	require.Equal(t, ResetEvent, replayed[0].Name)
+	require.Equal(t, seq+1, replayed[0].Seq)
+}
+
+func TestBlockchainSource_dispatchReset(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	source := NewBlockchainSource(zap.NewNop())
+	var txs []Event
+	source.SubscribeToTransactions([]tongo.AccountID{a}, func(event Event) {
+		txs = append(txs, event)
+	})
+	source.dispatchReset("2 blocks were dropped")
+	require.Len(t, txs, 1)
+	require.Equal(t, ResetEvent, txs[0].Name)
+	var data ResetEventData
+	require.Nil(t, json.Unmarshal(txs[0].Data, &data))
+	require.Equal(t, "2 blocks were dropped", data.Reason)
+
+	// a client resuming from an earlier position gets the reset as well.
+	replayed := source.Replay(context.Background(), StreamTransactions, []tongo.AccountID{a}, Position{Seq: txs[0].Seq - 1})
+	require.Equal(t, txs, replayed)
+}
+
+type mockTraceHistory struct {
+	tracked map[tongo.AccountID]struct{}
+	traces  []*core.Trace
+}
+
+func (m *mockTraceHistory) IsTracked(a tongo.AccountID) bool {
+	_, ok := m.tracked[a]
+	return ok
+}
+
+func (m *mockTraceHistory) SearchTraces(ctx context.Context, a tongo.AccountID, limit int, beforeLT, afterLT, startTime, endTime *int64, initiator bool, descendingOrder bool) ([]core.TraceID, error) {
+	var ids []core.TraceID
+	for _, trace := range m.traces {
+		if trace.Lt > uint64(*afterLT) && slices.Contains(core.DistinctAccounts(trace), a) {
+			ids = append(ids, core.TraceID{Hash: trace.Hash, Lt: trace.Lt})
+		}
+	}
+	return ids, nil
+}
+
+func (m *mockTraceHistory) GetTrace(ctx context.Context, hash tongo.Bits256) (*core.Trace, error) {
+	for _, trace := range m.traces {
+		if trace.Hash == hash {
+			return trace, nil
+		}
+	}
+	return nil, core.ErrEntityNotFound
+}
+
+func TestBlockchainSource_replayIndexed(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	untracked := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	trace := func(lt uint64) *core.Trace {
+		return &core.Trace{Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{byte(lt)}, Lt: lt, Account: a}}}
+	}
+	history := &mockTraceHistory{
+		tracked: map[tongo.AccountID]struct{}{a: {}},
+		traces:  []*core.Trace{trace(10), trace(30), trace(20)},
+	}
+	source := NewBlockchainSource(zap.NewNop(), WithTraceHistory(history), WithAccountEventBuilder(func(ctx context.Context, account tongo.AccountID, traceID core.TraceID) oas.AccountEvent {
+		return oas.AccountEvent{EventID: traceID.Hash.Hex(), Lt: int64(traceID.Lt)}
+	}))
+	source.dispatchTrace(trace(30))
+	current := source.traces.seq
+
+	// a position of a previous run is resumed from the indexed history by its lt.
+	replayed := source.Replay(context.Background(), StreamTraces, []tongo.AccountID{a}, Position{Seq: 1, Lt: 10})
+	require.Len(t, replayed, 2)
+	require.Equal(t, tongo.Bits256{20}.Hex(), replayed[0].Key)
+	require.Equal(t, tongo.Bits256{30}.Hex(), replayed[1].Key)
+	require.Equal(t, Position{Seq: current, Lt: 30}, Position{Seq: replayed[1].Seq, Lt: replayed[1].Lt})
+
+	replayed = source.Replay(context.Background(), StreamAccountEvents, []tongo.AccountID{a}, Position{Seq: 1, Lt: 20})
+	require.Len(t, replayed, 1)
+	require.Equal(t, AccountEventKey(a, tongo.Bits256{30}), replayed[0].Key)
+
+	// events of untracked accounts aren't in the index.
+	replayed = source.Replay(context.Background(), StreamTraces, []tongo.AccountID{a, untracked}, Position{Seq: 1, Lt: 10})
+	require.Len(t, replayed, 1)
+	require.Equal(t, ResetEvent, replayed[0].Name)
+	// a position without lt can't be found in the index.
+	replayed = source.Replay(context.Background(), StreamTraces, []tongo.AccountID{a}, Position{Seq: 1})
+	require.Equal(t, ResetEvent, replayed[0].Name)
+}
@@ -0,0 +1,104 @@
+package sources
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// historySize is a number of recent events of every stream kept to resume streams.
+const historySize = 10_000
+
+// historyEntry is an event recorded in a stream's history.
+type historyEntry struct {
+	// accounts the event is about, a reset event has none and is replayed to everyone.
+	accounts []tongo.AccountID
+	// traceID is set for traces, events of accounts are built from them on replay.
+	traceID core.TraceID
+	event   Event
+}
+
+func (e historyEntry) involves(accounts map[tongo.AccountID]struct{}) bool {
+	if e.event.Name == ResetEvent {
+		return true
+	}
+	for _, a := range e.accounts {
+		if _, ok := accounts[a]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// history is a ring of the latest events of a stream.
+// It assigns every event a sequence number in the order events are sent to subscribers.
+type history struct {
+	mu sync.Mutex
+	// first is the sequence number of the first event of this run.
+	first   uint64
+	seq     uint64
+	entries []historyEntry
+}
+
+func newHistory() *history {
+	// the sequence starts at the current time,
+	// so ids received from a previous run are never mistaken for new ones.
+	start := uint64(time.Now().UnixMicro())
+	return &history{
+		first:   start + 1,
+		seq:     start,
+		entries: make([]historyEntry, historySize),
+	}
+}
+
+// add assigns the next sequence number to the entry's event and records it.
+func (h *history) add(entry historyEntry) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	entry.event.Seq = h.seq
+	if entry.event.Key == "" {
+		entry.event.Key = strconv.FormatUint(h.seq, 10)
+	}
+	h.entries[h.seq%historySize] = entry
+	return entry.event
+}
+
+// since returns entries involving the given accounts with sequence numbers above the given one.
+// It returns false if some of those entries aren't in the history anymore,
+// along with the current sequence number to resume from.
+func (h *history) since(seq uint64, accounts []tongo.AccountID) ([]historyEntry, uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if seq+1 < h.first || seq > h.seq || h.seq-seq > historySize {
+		return nil, h.seq, false
+	}
+	set := make(map[tongo.AccountID]struct{}, len(accounts))
+	for _, a := range accounts {
+		set[a] = struct{}{}
+	}
+	var entries []historyEntry
+	for s := seq + 1; s <= h.seq; s++ {
+		if entry := h.entries[s%historySize]; entry.involves(set) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, h.seq, true
+}
+
+// resetEvent tells a subscriber that some events of the stream are lost,
+// so the subscriber has to fetch the current state with the REST API.
+func resetEvent(reason string) Event {
+	value, _ := json.Marshal(ResetEventData{Reason: reason})
+	return Event{Name: ResetEvent, Data: value}
+}
+
+// recordReset adds a reset event to the history, so clients resuming from an earlier position get it too.
+func (h *history) recordReset(reason string) Event {
+	return h.add(historyEntry{event: resetEvent(reason)})
+}
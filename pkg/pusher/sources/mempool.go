@@ -0,0 +1,344 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/tlb"
+	"github.com/tonkeeper/tongo/ton"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/blockchain"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/oas"
+)
+
+var pendingMessagesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "pusher_mempool_pending_messages",
+	Help: "Number of sent messages waiting to land in the blockchain",
+})
+
+const (
+	// maxPendingTTL is how long we keep a message without a status from the tracker.
+	// The tracker reports every message it knows about in an hour at most,
+	// so it only cleans up emulated messages that were never sent and messages sent before a restart.
+	maxPendingTTL = 2 * time.Hour
+	// maxPendingMessages limits the number of messages waiting to land in the blockchain.
+	maxPendingMessages = 100_000
+	// cleanupInterval defines how often we look for messages without a status.
+	cleanupInterval = time.Minute
+)
+
+// Types of mempool events.
+const (
+	// MemPoolMessage is sent when a message is accepted by opentonapi
+	// and again with IsEmulation set once the message is emulated.
+	MemPoolMessage = "message"
+	// MemPoolPreview contains an emulated event of a subscribed account.
+	MemPoolPreview = "preview"
+	// MemPoolConfirmed is sent once a transaction processing the message appears in the blockchain.
+	MemPoolConfirmed = "confirmed"
+	// MemPoolFailed is sent if the compute phase of the transaction processing the message has failed.
+	MemPoolFailed = "failed"
+	// MemPoolExpired is sent if the message hasn't landed in the blockchain in time.
+	MemPoolExpired = "expired"
+)
+
+// MemPoolEventData is sent to subscribers of the mempool.
+type MemPoolEventData struct {
+	Type string `json:"type"`
+	// Hash is a normalized hash of the message.
+	Hash string `json:"hash"`
+	// BOC is a base64 encoded message boc, it is set for MemPoolMessage.
+	BOC string `json:"boc,omitempty"`
+	// IsEmulation is set if InvolvedAccounts were found by emulation.
+	IsEmulation bool `json:"is_emulation"`
+	// InvolvedAccounts contains the destination wallet and, if the message was emulated, all accounts of its trace.
+	InvolvedAccounts []string `json:"involved_accounts,omitempty"`
+	// Account and Event are set for MemPoolPreview.
+	Account string          `json:"account,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
+	// TraceID is a hash of the transaction that processed the message, it is set for MemPoolConfirmed and MemPoolFailed.
+	TraceID string `json:"trace_id,omitempty"`
+	// ExitCode is an exit code of the compute phase, it is set for MemPoolConfirmed and MemPoolFailed.
+	ExitCode *int32 `json:"exit_code,omitempty"`
+}
+
+// MemPoolSource notifies subscribers about external messages sent to the blockchain.
+type MemPoolSource interface {
+	// SubscribeToMessages notifies the subscriber about messages involving the given accounts,
+	// or about all messages if no accounts are given.
+	SubscribeToMessages(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn
+}
+
+// PreviewBuilder returns an emulated event of the account for a pending message with the given normalized hash.
+type PreviewBuilder func(ctx context.Context, account tongo.AccountID, msgHash string) (oas.AccountEvent, error)
+
+// pendingMessage is a message waiting to land in the blockchain.
+type pendingMessage struct {
+	accounts  []tongo.AccountID
+	expiresAt time.Time
+}
+
+// MemPool reads copies of external messages sent by blockchain.MsgSender or emulated by the api,
+// and notifies subscribers about them until the sent messages tracker reports their final statuses.
+type MemPool struct {
+	logger         *zap.Logger
+	previewBuilder PreviewBuilder
+	// pending is accessed by the Run goroutine only.
+	pending map[string]pendingMessage
+
+	mu sync.RWMutex
+	// all contains subscribers of all messages.
+	all         map[*subscriber]struct{}
+	subscribers subscribers
+}
+
+type MemPoolOptions struct {
+	previewBuilder PreviewBuilder
+}
+
+type MemPoolOption func(o *MemPoolOptions)
+
+// WithPreviewBuilder configures a builder of emulated events,
+// subscribers of accounts get no previews without it.
+func WithPreviewBuilder(builder PreviewBuilder) MemPoolOption {
+	return func(o *MemPoolOptions) {
+		o.previewBuilder = builder
+	}
+}
+
+func NewMemPool(logger *zap.Logger, opts ...MemPoolOption) *MemPool {
+	o := &MemPoolOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &MemPool{
+		logger:         logger,
+		previewBuilder: o.previewBuilder,
+		pending:        map[string]pendingMessage{},
+		all:            map[*subscriber]struct{}{},
+		subscribers:    subscribers{},
+	}
+}
+
+// Run processes messages and blocks until the context is cancelled.
+// Statuses of sent messages come from the tracker and finish pending messages.
+func (m *MemPool) Run(ctx context.Context, msgs <-chan blockchain.ExtInMsgCopy, statuses <-chan core.SentMessage) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msgCopy := <-msgs:
+			m.addMessage(ctx, msgCopy, time.Now())
+		case status, ok := <-statuses:
+			if !ok {
+				statuses = nil
+				continue
+			}
+			m.finishMessage(status)
+		case now := <-ticker.C:
+			m.cleanup(now)
+		}
+		pendingMessagesGauge.Set(float64(len(m.pending)))
+	}
+}
+
+func (m *MemPool) SubscribeToMessages(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn {
+	sub := &subscriber{deliver: deliveryFn}
+	m.mu.Lock()
+	if len(accounts) == 0 {
+		m.all[sub] = struct{}{}
+	} else {
+		m.subscribers.add(accounts, sub)
+	}
+	m.mu.Unlock()
+	activeSubscriptions.WithLabelValues("mempool").Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.all, sub)
+			m.subscribers.remove(accounts, sub)
+			m.mu.Unlock()
+			activeSubscriptions.WithLabelValues("mempool").Dec()
+		})
+	}
+}
+
+// decodeMessage returns a normalized hash of the message and its destination wallet.
+func decodeMessage(payload []byte) (string, *tongo.AccountID, bool) {
+	cells, err := boc.DeserializeBoc(payload)
+	if err != nil || len(cells) != 1 {
+		return "", nil, false
+	}
+	var message tlb.Message
+	if err := tlb.Unmarshal(cells[0], &message); err != nil || message.Info.SumType != "ExtInMsgInfo" {
+		return "", nil, false
+	}
+	dest, err := tongo.AccountIDFromTlb(message.Info.ExtInMsgInfo.Dest)
+	if err != nil {
+		return "", nil, false
+	}
+	return ton.Bits256(message.Hash(true)).Hex(), dest, true
+}
+
+func (m *MemPool) addMessage(ctx context.Context, msgCopy blockchain.ExtInMsgCopy, now time.Time) {
+	hash, dest, ok := decodeMessage(msgCopy.Payload)
+	if !ok {
+		return
+	}
+	pending, known := m.pending[hash]
+	if !known {
+		if len(m.pending) >= maxPendingMessages {
+			m.logger.Warn("too many pending messages, ignoring a new one", zap.String("hash", hash))
+			return
+		}
+		pending.expiresAt = now.Add(maxPendingTTL)
+	}
+	seen := map[tongo.AccountID]struct{}{}
+	for _, a := range pending.accounts {
+		seen[a] = struct{}{}
+	}
+	add := func(a tongo.AccountID) {
+		if _, ok := seen[a]; !ok {
+			seen[a] = struct{}{}
+			pending.accounts = append(pending.accounts, a)
+		}
+	}
+	if dest != nil {
+		add(*dest)
+	}
+	for a := range msgCopy.Accounts {
+		add(a)
+	}
+	m.pending[hash] = pending
+
+	data := MemPoolEventData{
+		Type:        MemPoolMessage,
+		Hash:        hash,
+		BOC:         msgCopy.MsgBoc,
+		IsEmulation: msgCopy.IsEmulation(),
+	}
+	for _, a := range pending.accounts {
+		data.InvolvedAccounts = append(data.InvolvedAccounts, a.ToRaw())
+	}
+	m.dispatch(pending.accounts, data)
+	if msgCopy.IsEmulation() && m.previewBuilder != nil {
+		if accounts := m.subscribedAccounts(pending.accounts); len(accounts) > 0 {
+			// building a preview can be slow, so it doesn't block the mempool.
+			go m.sendPreviews(ctx, hash, accounts)
+		}
+	}
+}
+
+func (m *MemPool) subscribedAccounts(accounts []tongo.AccountID) []tongo.AccountID {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []tongo.AccountID
+	for _, a := range accounts {
+		if _, ok := m.subscribers[a]; ok {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func (m *MemPool) sendPreviews(ctx context.Context, hash string, accounts []tongo.AccountID) {
+	for _, account := range accounts {
+		event, err := m.previewBuilder(ctx, account, hash)
+		if err != nil {
+			m.logger.Debug("failed to build mempool preview", zap.String("hash", hash), zap.Error(err))
+			continue
+		}
+		value, err := event.MarshalJSON()
+		if err != nil {
+			m.logger.Error("failed to marshal mempool preview", zap.Error(err))
+			continue
+		}
+		data := MemPoolEventData{
+			Type:        MemPoolPreview,
+			Hash:        hash,
+			IsEmulation: true,
+			Account:     account.ToRaw(),
+			Event:       value,
+		}
+		m.mu.RLock()
+		m.deliver(m.subscribers[account], data)
+		m.mu.RUnlock()
+	}
+}
+
+// finishMessage notifies subscribers about the final status of a sent message.
+func (m *MemPool) finishMessage(status core.SentMessage) {
+	data := MemPoolEventData{Hash: status.Hash.Hex(), ExitCode: status.ExitCode}
+	switch status.Status {
+	case core.SentMessageIncluded:
+		data.Type = MemPoolConfirmed
+	case core.SentMessageFailed:
+		data.Type = MemPoolFailed
+	case core.SentMessageExpired:
+		data.Type = MemPoolExpired
+	default:
+		return
+	}
+	if status.TraceID != nil {
+		data.TraceID = status.TraceID.Hash.Hex()
+	}
+	accounts := []tongo.AccountID{status.Destination}
+	if pending, ok := m.pending[data.Hash]; ok {
+		accounts = pending.accounts
+		delete(m.pending, data.Hash)
+	}
+	m.dispatch(accounts, data)
+}
+
+// cleanup removes messages the tracker hasn't reported a status for.
+func (m *MemPool) cleanup(now time.Time) {
+	for hash, pending := range m.pending {
+		if now.After(pending.expiresAt) {
+			delete(m.pending, hash)
+		}
+	}
+}
+
+// dispatch sends the event to subscribers of all messages and subscribers of the given accounts.
+func (m *MemPool) dispatch(accounts []tongo.AccountID, data MemPoolEventData) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subs := make(map[*subscriber]struct{}, len(m.all))
+	for sub := range m.all {
+		subs[sub] = struct{}{}
+	}
+	for _, a := range accounts {
+		for sub := range m.subscribers[a] {
+			subs[sub] = struct{}{}
+		}
+	}
+	m.deliver(subs, data)
+}
+
+// deliver sends the event to the given subscribers, m.mu must be held.
+func (m *MemPool) deliver(subs map[*subscriber]struct{}, data MemPoolEventData) {
+	if len(subs) == 0 {
+		return
+	}
+	value, err := json.Marshal(data)
+	if err != nil {
+		m.logger.Error("failed to marshal mempool event", zap.Error(err))
+		return
+	}
+	deliver(subs, Event{
+		Name: data.Type,
+		Key:  data.Type + ":" + data.Hash + ":" + data.Account,
+		Data: value,
+	})
+}
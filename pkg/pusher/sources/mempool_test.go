@@ -0,0 +1,116 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"github.com/tonkeeper/tongo/boc"
+	"github.com/tonkeeper/tongo/tlb"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/blockchain"
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/oas"
+)
+
+func extInMessage(t *testing.T, dest tongo.AccountID) []byte {
+	msg := tlb.Message{}
+	msg.Info.SumType = "ExtInMsgInfo"
+	msg.Info.ExtInMsgInfo = &struct {
+		Src       tlb.MsgAddress
+		Dest      tlb.MsgAddress
+		ImportFee tlb.VarUInteger16
+	}{
+		Src:  tlb.MsgAddress{SumType: "AddrNone"},
+		Dest: dest.ToMsgAddress(),
+	}
+	cell := boc.NewCell()
+	require.Nil(t, tlb.Marshal(cell, msg))
+	payload, err := cell.ToBoc()
+	require.Nil(t, err)
+	return payload
+}
+
+func TestMemPool(t *testing.T) {
+	wallet := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	jetton := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	previews := make(chan string, 1)
+	mempool := NewMemPool(zap.NewNop(), WithPreviewBuilder(func(ctx context.Context, account tongo.AccountID, msgHash string) (oas.AccountEvent, error) {
+		previews <- account.ToRaw()
+		return oas.AccountEvent{EventID: msgHash, InProgress: true}, nil
+	}))
+
+	var all []MemPoolEventData
+	mempool.SubscribeToMessages(nil, func(event Event) {
+		var data MemPoolEventData
+		require.Nil(t, json.Unmarshal(event.Data, &data))
+		require.Equal(t, data.Type, event.Name)
+		all = append(all, data)
+	})
+	jettonEvents := make(chan MemPoolEventData, 10)
+	mempool.SubscribeToMessages([]tongo.AccountID{jetton}, func(event Event) {
+		var data MemPoolEventData
+		require.Nil(t, json.Unmarshal(event.Data, &data))
+		jettonEvents <- data
+	})
+
+	now := time.Now()
+	payload := extInMessage(t, wallet)
+	mempool.addMessage(context.Background(), blockchain.ExtInMsgCopy{MsgBoc: "boc", Payload: payload}, now)
+	require.Len(t, all, 1)
+	require.Equal(t, MemPoolMessage, all[0].Type)
+	require.False(t, all[0].IsEmulation)
+	require.Equal(t, []string{wallet.ToRaw()}, all[0].InvolvedAccounts)
+	require.Len(t, jettonEvents, 0)
+
+	// emulation finds the jetton account, so its subscriber gets the message and a preview.
+	mempool.addMessage(context.Background(), blockchain.ExtInMsgCopy{
+		MsgBoc:   "boc",
+		Payload:  payload,
+		Accounts: map[tongo.AccountID]struct{}{jetton: {}},
+	}, now)
+	require.Len(t, all, 2)
+	require.True(t, all[1].IsEmulation)
+	require.Equal(t, []string{wallet.ToRaw(), jetton.ToRaw()}, all[1].InvolvedAccounts)
+	require.Equal(t, MemPoolMessage, (<-jettonEvents).Type)
+	require.Equal(t, jetton.ToRaw(), <-previews)
+	select {
+	case data := <-jettonEvents:
+		require.Equal(t, MemPoolPreview, data.Type)
+		require.Equal(t, jetton.ToRaw(), data.Account)
+		require.Equal(t, all[0].Hash, data.Hash)
+	case <-time.After(time.Second):
+		t.Fatal("preview wasn't delivered")
+	}
+
+	hash, err := tongo.ParseHash(all[0].Hash)
+	require.Nil(t, err)
+	exitCode := int32(33)
+	mempool.finishMessage(core.SentMessage{
+		Hash:        hash,
+		Destination: wallet,
+		Status:      core.SentMessageFailed,
+		TraceID:     &core.TraceID{Hash: tongo.Bits256{3}, Lt: 3},
+		ExitCode:    &exitCode,
+	})
+	require.Len(t, all, 3)
+	require.Equal(t, MemPoolFailed, all[2].Type)
+	require.Equal(t, tongo.Bits256{3}.Hex(), all[2].TraceID)
+	require.Equal(t, exitCode, *all[2].ExitCode)
+	require.Equal(t, MemPoolFailed, (<-jettonEvents).Type)
+	require.Len(t, mempool.pending, 0)
+
+	// messages without a status from the tracker are dropped silently.
+	mempool.addMessage(context.Background(), blockchain.ExtInMsgCopy{MsgBoc: "boc", Payload: extInMessage(t, jetton)}, now)
+	require.Len(t, all, 4)
+	require.Equal(t, MemPoolMessage, (<-jettonEvents).Type)
+	mempool.cleanup(now.Add(time.Minute))
+	require.Len(t, mempool.pending, 1)
+	mempool.cleanup(now.Add(maxPendingTTL + time.Second))
+	require.Len(t, mempool.pending, 0)
+	require.Len(t, all, 4)
+}
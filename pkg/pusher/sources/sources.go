@@ -0,0 +1,125 @@
+// Package sources provides streams of blockchain events that can be pushed to clients.
+package sources
+
+import (
+	"context"
+
+	"github.com/tonkeeper/tongo"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+)
+
+// Event is a json-encoded notification delivered to a subscriber.
+type Event struct {
+	// Seq is a position of the event in its stream, events are sent in the order of Seq.
+	// It is used by clients to resume a stream, events without Seq can't be resumed.
+	Seq uint64
+	// Lt is the lt of the trace's root for traces and events of accounts.
+	// A stream is resumed from it with the indexed history once Seq isn't known anymore, e.g. after a restart.
+	Lt uint64
+	// Key is unique among events of the same stream, it is used to drop duplicates.
+	Key string
+	// Name optionally overrides the name of the stream the event is sent to.
+	Name string
+	Data []byte
+}
+
+// DeliveryFn is called for every event a subscriber is interested in.
+// It must not block.
+type DeliveryFn func(event Event)
+
+// CancelFn cancels a subscription.
+type CancelFn func()
+
+// Stream identifies a kind of events that can be resumed.
+type Stream int
+
+const (
+	StreamTransactions Stream = iota
+	StreamTraces
+	StreamAccountEvents
+)
+
+// ResetEvent is sent instead of events that were lost,
+// a subscriber has to fetch the current state of its accounts with the REST API.
+const ResetEvent = "reset"
+
+// ResetEventData explains why events were lost.
+type ResetEventData struct {
+	Reason string `json:"reason"`
+}
+
+// TransactionEventData is sent to subscribers of transactions.
+type TransactionEventData struct {
+	AccountID string `json:"account_id"`
+	Lt        uint64 `json:"lt"`
+	TxHash    string `json:"tx_hash"`
+}
+
+// TraceEventData is sent to subscribers of traces once a trace is complete.
+type TraceEventData struct {
+	// AccountIDs contains all accounts involved in the trace.
+	AccountIDs []string `json:"accounts"`
+	Lt         uint64   `json:"lt"`
+	Hash       string   `json:"hash"`
+}
+
+// BlockEventData is sent to subscribers of masterchain blocks.
+type BlockEventData struct {
+	Workchain int32  `json:"workchain"`
+	Shard     string `json:"shard"`
+	Seqno     uint32 `json:"seqno"`
+	RootHash  string `json:"root_hash"`
+	FileHash  string `json:"file_hash"`
+}
+
+// Source notifies subscribers about new transactions, traces and events of the given accounts.
+type Source interface {
+	SubscribeToTransactions(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn
+	SubscribeToTraces(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn
+	SubscribeToAccountEvents(accounts []tongo.AccountID, deliveryFn DeliveryFn) CancelFn
+	// SubscribeToBlocks notifies the subscriber about new masterchain blocks.
+	SubscribeToBlocks(deliveryFn DeliveryFn) CancelFn
+}
+
+// Position is the position of the last event a subscriber has received.
+type Position struct {
+	Seq uint64
+	Lt  uint64
+}
+
+// ResumableSource keeps recent events, so a subscriber can resume a stream after reconnecting.
+type ResumableSource interface {
+	Source
+	// Replay returns events of the given accounts after the given position.
+	// If some of them are lost, a reset event is returned instead.
+	Replay(ctx context.Context, stream Stream, accounts []tongo.AccountID, from Position) []Event
+}
+
+// TraceHistory looks for traces of tracked accounts in the index,
+// so a stream of traces can be resumed from a position older than the recent events kept in memory.
+// It is implemented by litestorage.LiteStorage.
+type TraceHistory interface {
+	IsTracked(a tongo.AccountID) bool
+	SearchTraces(ctx context.Context, a tongo.AccountID, limit int, beforeLT, afterLT, startTime, endTime *int64, initiator bool, descendingOrder bool) ([]core.TraceID, error)
+	GetTrace(ctx context.Context, hash tongo.Bits256) (*core.Trace, error)
+}
+
+// TraceAccounts returns accounts involved in the trace in raw form, without duplicates.
+func TraceAccounts(trace *core.Trace) []string {
+	seen := map[tongo.AccountID]struct{}{}
+	var accounts []string
+	core.Visit(trace, func(node *core.Trace) {
+		if _, ok := seen[node.Account]; ok {
+			return
+		}
+		seen[node.Account] = struct{}{}
+		accounts = append(accounts, node.Account.ToRaw())
+	})
+	return accounts
+}
+
+// AccountEventKey identifies an event of the account, the same trace is a different event for every account.
+func AccountEventKey(account tongo.AccountID, traceID tongo.Bits256) string {
+	return account.ToRaw() + ":" + traceID.Hex()
+}
@@ -0,0 +1,224 @@
+// Package sse streams blockchain events of accounts to clients with Server-Sent Events.
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	pushErrors "github.com/tonkeeper/opentonapi/pkg/pusher/errors"
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sources"
+)
+
+const (
+	// DefaultHeartbeatInterval is how often a heartbeat is written to an idle connection.
+	DefaultHeartbeatInterval = 15 * time.Second
+	// DefaultMaxAccounts limits the number of accounts a single connection can subscribe to.
+	DefaultMaxAccounts = 100
+)
+
+// Handler serves SSE endpoints streaming transactions, traces and events of the given accounts.
+//
+// Every event has its position in the stream as an id. A client reconnecting with the Last-Event-ID header
+// first gets recent events it has missed, and then live events.
+// Ids of traces and account events also contain the lt of the trace,
+// so events of tracked accounts are found in the indexed history even after a restart.
+// If the missed events aren't available anymore, the client gets a reset event instead.
+type Handler struct {
+	logger            *zap.Logger
+	source            sources.ResumableSource
+	mempool           sources.MemPoolSource
+	heartbeatInterval time.Duration
+	maxAccounts       int
+}
+
+type Options struct {
+	heartbeatInterval time.Duration
+	maxAccounts       int
+	mempool           sources.MemPoolSource
+}
+
+type Option func(o *Options)
+
+// WithHeartbeatInterval configures how often a heartbeat is written to an idle connection.
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.heartbeatInterval = interval
+	}
+}
+
+// WithMaxAccounts configures how many accounts a single connection can subscribe to.
+func WithMaxAccounts(n int) Option {
+	return func(o *Options) {
+		o.maxAccounts = n
+	}
+}
+
+// WithMemPool enables the stream of pending messages.
+func WithMemPool(mempool sources.MemPoolSource) Option {
+	return func(o *Options) {
+		o.mempool = mempool
+	}
+}
+
+func NewHandler(logger *zap.Logger, source sources.ResumableSource, opts ...Option) *Handler {
+	o := &Options{
+		heartbeatInterval: DefaultHeartbeatInterval,
+		maxAccounts:       DefaultMaxAccounts,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Handler{
+		logger:            logger,
+		source:            source,
+		mempool:           o.mempool,
+		heartbeatInterval: o.heartbeatInterval,
+		maxAccounts:       o.maxAccounts,
+	}
+}
+
+// stream describes one kind of events.
+type stream struct {
+	name      string
+	subscribe func(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn
+	// resumable is set if the stream can be resumed from the given position.
+	resumable bool
+	stream    sources.Stream
+	// allAccounts allows a client to subscribe to events of all accounts by omitting the accounts parameter.
+	allAccounts bool
+}
+
+func (h *Handler) SubscribeToTransactions(w http.ResponseWriter, r *http.Request, connectionType int, allowTokenInQuery bool) error {
+	return h.serve(w, r, stream{
+		name:      "transaction",
+		subscribe: h.source.SubscribeToTransactions,
+		resumable: true,
+		stream:    sources.StreamTransactions,
+	})
+}
+
+func (h *Handler) SubscribeToTraces(w http.ResponseWriter, r *http.Request, connectionType int, allowTokenInQuery bool) error {
+	return h.serve(w, r, stream{
+		name:      "trace",
+		subscribe: h.source.SubscribeToTraces,
+		resumable: true,
+		stream:    sources.StreamTraces,
+	})
+}
+
+func (h *Handler) SubscribeToAccountEvents(w http.ResponseWriter, r *http.Request, connectionType int, allowTokenInQuery bool) error {
+	return h.serve(w, r, stream{
+		name:      "account_event",
+		subscribe: h.source.SubscribeToAccountEvents,
+		resumable: true,
+		stream:    sources.StreamAccountEvents,
+	})
+}
+
+// SubscribeToMempool streams messages sent to the blockchain until they are confirmed or expired.
+// For subscribed accounts emulated events are sent as previews.
+func (h *Handler) SubscribeToMempool(w http.ResponseWriter, r *http.Request, connectionType int, allowTokenInQuery bool) error {
+	if h.mempool == nil {
+		return writeError(w, pushErrors.NotImplemented())
+	}
+	return h.serve(w, r, stream{
+		name:        "mempool_message",
+		subscribe:   h.mempool.SubscribeToMessages,
+		allAccounts: true,
+	})
+}
+
+func writeError(w http.ResponseWriter, err pushErrors.HTTPError) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Code)
+	_ = json.NewEncoder(w).Encode(err)
+	return err
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, st stream) error {
+	var accounts []tongo.AccountID
+	if value := r.URL.Query().Get("accounts"); value != "" || !st.allAccounts {
+		var err error
+		accounts, err = h.parseAccounts(value)
+		if err != nil {
+			return writeError(w, pushErrors.BadRequest(err.Error()))
+		}
+	}
+	last, resume, err := parseLastEventID(r)
+	if err != nil {
+		return writeError(w, pushErrors.BadRequest(err.Error()))
+	}
+	resume = resume && st.resumable
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return writeError(w, pushErrors.InternalServerError("streaming is not supported"))
+	}
+	// we subscribe before replaying the history, so no event falls between the two.
+	s := newSession(st.name, resume)
+	cancel := st.subscribe(accounts, s.send)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var events []sources.Event
+	if resume {
+		events = h.source.Replay(r.Context(), st.stream, accounts, last)
+		events = s.release(events)
+	}
+	return s.stream(r.Context(), w, flusher, events, h.heartbeatInterval)
+}
+
+func (h *Handler) parseAccounts(value string) ([]tongo.AccountID, error) {
+	if value == "" {
+		return nil, fmt.Errorf("accounts are required")
+	}
+	var accounts []tongo.AccountID
+	seen := map[tongo.AccountID]struct{}{}
+	for _, s := range strings.Split(value, ",") {
+		account, err := tongo.ParseAddress(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid account %q: %w", s, err)
+		}
+		if _, ok := seen[account.ID]; ok {
+			continue
+		}
+		seen[account.ID] = struct{}{}
+		accounts = append(accounts, account.ID)
+	}
+	if len(accounts) > h.maxAccounts {
+		return nil, fmt.Errorf("too many accounts, max is %d", h.maxAccounts)
+	}
+	return accounts, nil
+}
+
+// parseLastEventID returns the position of the last event a client has received, if the client resumes a stream.
+// The id is either seq or seq:lt, see writeEvent.
+func parseLastEventID(r *http.Request) (sources.Position, bool, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		return sources.Position{}, false, nil
+	}
+	var pos sources.Position
+	seq, lt, hasLt := strings.Cut(value, ":")
+	var err error
+	if pos.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+		return sources.Position{}, false, fmt.Errorf("invalid Last-Event-ID: %w", err)
+	}
+	if hasLt {
+		if pos.Lt, err = strconv.ParseUint(lt, 10, 64); err != nil {
+			return sources.Position{}, false, fmt.Errorf("invalid Last-Event-ID: %w", err)
+		}
+	}
+	return pos, true, nil
+}
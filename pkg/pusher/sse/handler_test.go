@@ -0,0 +1,156 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sources"
+)
+
+type mockSource struct {
+	// live is delivered right after a client subscribes, while the history is being replayed.
+	live    []sources.Event
+	history []sources.Event
+}
+
+func (m *mockSource) subscribe(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn {
+	for _, event := range m.live {
+		deliveryFn(event)
+	}
+	return func() {}
+}
+
+func (m *mockSource) SubscribeToTransactions(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn {
+	return m.subscribe(accounts, deliveryFn)
+}
+
+func (m *mockSource) SubscribeToTraces(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn {
+	return m.subscribe(accounts, deliveryFn)
+}
+
+func (m *mockSource) SubscribeToAccountEvents(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn {
+	return m.subscribe(accounts, deliveryFn)
+}
+
+func (m *mockSource) SubscribeToBlocks(deliveryFn sources.DeliveryFn) sources.CancelFn {
+	return func() {}
+}
+
+func (m *mockSource) Replay(ctx context.Context, stream sources.Stream, accounts []tongo.AccountID, from sources.Position) []sources.Event {
+	seq := from.Seq
+	last := m.history[len(m.history)-1].Seq
+	if seq+1 < m.history[0].Seq || seq > last {
+		return []sources.Event{{Seq: last, Name: sources.ResetEvent, Key: "reset", Data: []byte(`{}`)}}
+	}
+	var events []sources.Event
+	for _, event := range m.history {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestHandler_SubscribeToTransactions(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	source := &mockSource{
+		live: []sources.Event{
+			// the first one is replayed as well, so it must be sent once.
+			{Seq: 3, Key: tongo.Bits256{30}.Hex(), Data: []byte(`{"lt":30}`)},
+			{Seq: 4, Key: tongo.Bits256{40}.Hex(), Data: []byte(`{"lt":40}`)},
+		},
+		history: []sources.Event{
+			{Seq: 1, Key: tongo.Bits256{10}.Hex(), Data: []byte(`{"lt":10}`)},
+			{Seq: 2, Key: tongo.Bits256{20}.Hex(), Data: []byte(`{"lt":20}`)},
+			{Seq: 3, Key: tongo.Bits256{30}.Hex(), Data: []byte(`{"lt":30}`)},
+		},
+	}
+	h := NewHandler(zap.NewNop(), source, WithMaxAccounts(2), WithHeartbeatInterval(time.Hour))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = h.SubscribeToTransactions(w, r, 0, true)
+	}))
+	defer server.Close()
+
+	get := func(accounts string, lastEventID string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"?accounts="+accounts, nil)
+		require.Nil(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		return resp
+	}
+
+	resp := get("", "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+	resp = get(strings.Join([]string{a.ToRaw(), b.ToRaw(), tongo.AccountID{Address: tongo.Bits256{3}}.ToRaw()}, ","), "")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = get(a.ToRaw()+","+b.ToRaw(), "1")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for len(ids) < 3 && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	require.Equal(t, []string{"2", "3", "4"}, ids)
+
+	// a client resuming from an unknown position gets a reset event and then live events.
+	resp = get(a.ToRaw(), "100")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var names []string
+	scanner = bufio.NewScanner(resp.Body)
+	for len(names) < 3 && scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			names = append(names, name)
+		}
+	}
+	require.Equal(t, []string{sources.ResetEvent, "transaction", "transaction"}, names)
+}
+
+func Test_parseLastEventID(t *testing.T) {
+	tests := []struct {
+		id      string
+		want    sources.Position
+		resume  bool
+		wantErr bool
+	}{
+		{id: ""},
+		{id: "12", want: sources.Position{Seq: 12}, resume: true},
+		{id: "12:500", want: sources.Position{Seq: 12, Lt: 500}, resume: true},
+		{id: "12:lt", wantErr: true},
+		{id: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Last-Event-ID", tt.id)
+			pos, resume, err := parseLastEventID(r)
+			if tt.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.want, pos)
+			require.Equal(t, tt.resume, resume)
+		})
+	}
+}
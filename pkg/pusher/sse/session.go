@@ -0,0 +1,142 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sources"
+)
+
+// sessionBufferSize is a number of events waiting to be written to a client.
+// A client that falls behind is disconnected and is expected to reconnect with Last-Event-ID.
+const sessionBufferSize = 1_000
+
+// session is a single SSE connection streaming events of one kind.
+type session struct {
+	name string
+
+	mu sync.Mutex
+	// holding is set while we replay the history,
+	// live events received meanwhile are kept in held and written after the replayed ones.
+	holding bool
+	held    []sources.Event
+	eventCh chan sources.Event
+	// overflow is closed once the client falls behind.
+	overflow     chan struct{}
+	overflowOnce sync.Once
+}
+
+func newSession(name string, holding bool) *session {
+	return &session{
+		name:     name,
+		holding:  holding,
+		eventCh:  make(chan sources.Event, sessionBufferSize),
+		overflow: make(chan struct{}),
+	}
+}
+
+// send is a sources.DeliveryFn, it never blocks.
+func (s *session) send(event sources.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holding {
+		if len(s.held) < sessionBufferSize {
+			s.held = append(s.held, event)
+			return
+		}
+		s.disconnect()
+		return
+	}
+	select {
+	case s.eventCh <- event:
+	default:
+		s.disconnect()
+	}
+}
+
+func (s *session) disconnect() {
+	s.overflowOnce.Do(func() {
+		close(s.overflow)
+	})
+}
+
+// release stops holding live events and returns the replayed events followed by the held ones
+// without those already replayed.
+func (s *session) release(replayed []sources.Event) []sources.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make(map[string]struct{}, len(replayed))
+	for _, event := range replayed {
+		keys[event.Key] = struct{}{}
+	}
+	events := replayed
+	for _, event := range s.held {
+		if _, ok := keys[event.Key]; !ok {
+			events = append(events, event)
+		}
+	}
+	s.holding = false
+	s.held = nil
+	return events
+}
+
+// writeEvent writes the event in the text/event-stream format,
+// the event's position in the stream is its id, so a client can resume the stream with Last-Event-ID.
+// The id is seq, or seq:lt if the event has an lt. Events without a position have no id and can't be resumed.
+func writeEvent(w io.Writer, name string, event sources.Event) error {
+	if event.Name != "" {
+		name = event.Name
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "event: %s\n", name)
+	switch {
+	case event.Seq > 0 && event.Lt > 0:
+		fmt.Fprintf(&buf, "id: %d:%d\n", event.Seq, event.Lt)
+	case event.Seq > 0:
+		fmt.Fprintf(&buf, "id: %d\n", event.Seq)
+	}
+	for _, line := range bytes.Split(event.Data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// stream writes the given events and then live events until the client goes away or falls behind.
+// A comment line is written every heartbeatInterval to keep the connection open.
+func (s *session) stream(ctx context.Context, w io.Writer, flusher http.Flusher, events []sources.Event, heartbeatInterval time.Duration) error {
+	for _, event := range events {
+		if err := writeEvent(w, s.name, event); err != nil {
+			return err
+		}
+	}
+	flusher.Flush()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.overflow:
+			return nil
+		case event := <-s.eventCh:
+			if err := writeEvent(w, s.name, event); err != nil {
+				return err
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,408 @@
+// Package websocket implements a JSON-RPC API over a single WebSocket connection,
+// a client subscribes to and unsubscribes from several streams of blockchain events on the fly.
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sources"
+	"github.com/tonkeeper/opentonapi/pkg/pusher/utils"
+)
+
+var (
+	activeConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "websocket_active_connections",
+		Help: "Number of open websocket connections",
+	})
+	slowClientDisconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "websocket_slow_client_disconnects_total",
+		Help: "Number of websocket connections closed because a client didn't read its notifications in time",
+	})
+)
+
+const (
+	// DefaultMaxSubscriptions limits the number of subscriptions of a single connection.
+	// Every account of an account-based stream counts as a subscription.
+	DefaultMaxSubscriptions = 1_000
+	// DefaultBufferSize is a number of messages waiting to be written to a client.
+	// A client that falls behind is disconnected, it has to reconnect and subscribe again.
+	DefaultBufferSize = 1_000
+	// writeTimeout limits the time to write a single message.
+	writeTimeout = 10 * time.Second
+	// maxRequestSize limits the size of a single request.
+	maxRequestSize = 64 << 10
+	// recentTracesSize is a number of latest traces a session remembers to deliver every trace once.
+	recentTracesSize = 1_000
+)
+
+// Handler serves the websocket endpoint.
+type Handler struct {
+	logger           *zap.Logger
+	source           sources.Source
+	mempool          sources.MemPoolSource
+	maxSubscriptions int
+	bufferSize       int
+}
+
+type Options struct {
+	maxSubscriptions int
+	bufferSize       int
+}
+
+type Option func(o *Options)
+
+// WithMaxSubscriptions configures how many subscriptions a single connection can have.
+func WithMaxSubscriptions(n int) Option {
+	return func(o *Options) {
+		o.maxSubscriptions = n
+	}
+}
+
+// WithBufferSize configures how many messages can wait to be written to a client before it is disconnected.
+func WithBufferSize(n int) Option {
+	return func(o *Options) {
+		o.bufferSize = n
+	}
+}
+
+func NewHandler(logger *zap.Logger, source sources.Source, mempool sources.MemPoolSource, opts ...Option) *Handler {
+	o := &Options{
+		maxSubscriptions: DefaultMaxSubscriptions,
+		bufferSize:       DefaultBufferSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Handler{
+		logger:           logger,
+		source:           source,
+		mempool:          mempool,
+		maxSubscriptions: o.maxSubscriptions,
+		bufferSize:       o.bufferSize,
+	}
+}
+
+// Handle is an api.AsyncHandler, authentication is done by async middlewares before the connection is upgraded.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request, connectionType int, allowTokenInQuery bool) error {
+	logger := h.logger
+	if name := utils.TokenNameFromContext(r.Context()); name != "" {
+		logger = logger.With(zap.String("token", name))
+	}
+	server := websocket.Server{
+		// we serve public data, so a connection from any origin is accepted.
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			activeConnections.Inc()
+			defer activeConnections.Dec()
+			conn.MaxPayloadBytes = maxRequestSize
+			s := newSession(logger, conn, h.bufferSize)
+			s.run(r.Context(), h)
+		},
+	}
+	server.ServeHTTP(w, r)
+	return nil
+}
+
+// session is a single websocket connection with its subscriptions.
+type session struct {
+	logger *zap.Logger
+	conn   *websocket.Conn
+	// outCh contains responses and notifications waiting to be written to the client.
+	outCh chan any
+	// done is closed when the session is over, either the client has gone away or fallen behind.
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// traceDelivery delivers every trace once, while a trace involving several accounts
+	// is delivered by the subscription of each of them.
+	traceDelivery sources.DeliveryFn
+
+	mu       sync.Mutex
+	accounts map[tongo.AccountID]sources.CancelFn
+	traces   map[tongo.AccountID]sources.CancelFn
+	block    sources.CancelFn
+	mempool  sources.CancelFn
+	// mempoolAccounts is a number of accounts of the mempool subscription, it counts towards the limit.
+	mempoolAccounts int
+}
+
+func newSession(logger *zap.Logger, conn *websocket.Conn, bufferSize int) *session {
+	s := &session{
+		logger:   logger,
+		conn:     conn,
+		outCh:    make(chan any, bufferSize),
+		done:     make(chan struct{}),
+		accounts: map[tongo.AccountID]sources.CancelFn{},
+		traces:   map[tongo.AccountID]sources.CancelFn{},
+	}
+	s.traceDelivery = dedupe(s.deliveryFn(traceNotification), recentTracesSize)
+	return s
+}
+
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.conn.Close()
+	})
+}
+
+func (s *session) run(ctx context.Context, h *Handler) {
+	defer s.unsubscribeAll()
+	defer s.close()
+	go s.writeLoop(ctx)
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(s.conn, &data); err != nil {
+			return
+		}
+		resp := h.process(s, data)
+		select {
+		case s.outCh <- resp:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *session) writeLoop(ctx context.Context) {
+	defer s.close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		case msg := <-s.outCh:
+			if err := s.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+			if err := websocket.JSON.Send(s.conn, msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// deliveryFn returns a sources.DeliveryFn that sends notifications with the given method.
+// Notifications are never delayed: if the client falls behind, the connection is closed.
+func (s *session) deliveryFn(method string) sources.DeliveryFn {
+	return func(event sources.Event) {
+		msg := notification{JSONRPC: "2.0", Method: method, Params: event.Data}
+		select {
+		case s.outCh <- msg:
+		case <-s.done:
+		default:
+			slowClientDisconnects.Inc()
+			s.logger.Info("disconnecting slow websocket client")
+			s.close()
+		}
+	}
+}
+
+// dedupe returns a sources.DeliveryFn that drops events with the same Key as one of the latest n events.
+func dedupe(deliver sources.DeliveryFn, n int) sources.DeliveryFn {
+	var mu sync.Mutex
+	seen := make(map[string]struct{}, n)
+	recent := make([]string, n)
+	next := 0
+	return func(event sources.Event) {
+		mu.Lock()
+		if _, ok := seen[event.Key]; ok {
+			mu.Unlock()
+			return
+		}
+		delete(seen, recent[next])
+		seen[event.Key] = struct{}{}
+		recent[next] = event.Key
+		next = (next + 1) % n
+		mu.Unlock()
+		deliver(event)
+	}
+}
+
+func (s *session) subscriptionsNumber() int {
+	n := len(s.accounts) + len(s.traces) + s.mempoolAccounts
+	if s.block != nil {
+		n++
+	}
+	if s.mempool != nil && s.mempoolAccounts == 0 {
+		n++
+	}
+	return n
+}
+
+func (s *session) unsubscribeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, subs := range []map[tongo.AccountID]sources.CancelFn{s.accounts, s.traces} {
+		for a, cancel := range subs {
+			cancel()
+			delete(subs, a)
+		}
+	}
+	for _, cancel := range []sources.CancelFn{s.block, s.mempool} {
+		if cancel != nil {
+			cancel()
+		}
+	}
+	s.block, s.mempool = nil, nil
+}
+
+var errTooManySubscriptions = errors.New("too many subscriptions")
+
+func parseAccounts(params []string) ([]tongo.AccountID, error) {
+	accounts := make([]tongo.AccountID, 0, len(params))
+	for _, param := range params {
+		account, err := tongo.ParseAddress(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account %q: %w", param, err)
+		}
+		accounts = append(accounts, account.ID)
+	}
+	return accounts, nil
+}
+
+// process handles a single request and returns a response to it.
+func (h *Handler) process(s *session, data []byte) response {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return response{JSONRPC: "2.0", Error: &rpcError{Code: parseErrorCode, Message: err.Error()}}
+	}
+	resp := response{ID: req.ID, JSONRPC: "2.0", Method: req.Method}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: invalidRequestCode, Message: "invalid request"}
+		return resp
+	}
+	result, err := h.call(s, req)
+	var rpcErr *rpcError
+	switch {
+	case errors.As(err, &rpcErr):
+		resp.Error = rpcErr
+	case err != nil:
+		resp.Error = &rpcError{Code: invalidParamsCode, Message: err.Error()}
+	default:
+		resp.Result = result
+	}
+	return resp
+}
+
+func (h *Handler) call(s *session, req request) (string, error) {
+	switch req.Method {
+	case subscribeAccountMethod, unsubscribeAccountMethod, subscribeTraceMethod, unsubscribeTraceMethod:
+		accounts, err := parseAccounts(req.Params)
+		if err != nil {
+			return "", err
+		}
+		if len(accounts) == 0 {
+			return "", fmt.Errorf("accounts are required")
+		}
+		switch req.Method {
+		case subscribeAccountMethod:
+			return h.subscribeAccounts(s, s.accounts, accounts, h.source.SubscribeToTransactions, s.deliveryFn(accountTransactionNotification))
+		case unsubscribeAccountMethod:
+			return unsubscribeAccounts(s, s.accounts, accounts), nil
+		case subscribeTraceMethod:
+			return h.subscribeAccounts(s, s.traces, accounts, h.source.SubscribeToTraces, s.traceDelivery)
+		default:
+			return unsubscribeAccounts(s, s.traces, accounts), nil
+		}
+	case subscribeBlockMethod:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.block != nil {
+			return "already subscribed to blocks", nil
+		}
+		if s.subscriptionsNumber()+1 > h.maxSubscriptions {
+			return "", errTooManySubscriptions
+		}
+		s.block = h.source.SubscribeToBlocks(s.deliveryFn(blockNotification))
+		return "success! subscribed to blocks", nil
+	case unsubscribeBlockMethod:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.block != nil {
+			s.block()
+			s.block = nil
+		}
+		return "success! unsubscribed from blocks", nil
+	case subscribeMempoolMethod:
+		if h.mempool == nil {
+			return "", &rpcError{Code: methodNotFoundCode, Message: "mempool is not available"}
+		}
+		// a client can change the list of accounts by subscribing again.
+		accounts, err := parseAccounts(req.Params)
+		if err != nil {
+			return "", err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		previous, previousAccounts := s.mempool, s.mempoolAccounts
+		s.mempool, s.mempoolAccounts = nil, 0
+		if s.subscriptionsNumber()+max(len(accounts), 1) > h.maxSubscriptions {
+			s.mempool, s.mempoolAccounts = previous, previousAccounts
+			return "", errTooManySubscriptions
+		}
+		if previous != nil {
+			previous()
+		}
+		s.mempool = h.mempool.SubscribeToMessages(accounts, s.deliveryFn(mempoolMessageNotification))
+		s.mempoolAccounts = len(accounts)
+		return "success! subscribed to mempool", nil
+	case unsubscribeMempoolMethod:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.mempool != nil {
+			s.mempool()
+			s.mempool, s.mempoolAccounts = nil, 0
+		}
+		return "success! unsubscribed from mempool", nil
+	}
+	return "", &rpcError{Code: methodNotFoundCode, Message: fmt.Sprintf("method %q not found", req.Method)}
+}
+
+func (h *Handler) subscribeAccounts(s *session, subs map[tongo.AccountID]sources.CancelFn, accounts []tongo.AccountID, subscribe func([]tongo.AccountID, sources.DeliveryFn) sources.CancelFn, deliveryFn sources.DeliveryFn) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var newAccounts []tongo.AccountID
+	for _, a := range accounts {
+		if _, ok := subs[a]; !ok && !slices.Contains(newAccounts, a) {
+			newAccounts = append(newAccounts, a)
+		}
+	}
+	if s.subscriptionsNumber()+len(newAccounts) > h.maxSubscriptions {
+		return "", errTooManySubscriptions
+	}
+	for _, a := range newAccounts {
+		subs[a] = subscribe([]tongo.AccountID{a}, deliveryFn)
+	}
+	return fmt.Sprintf("success! %d new subscriptions created", len(newAccounts)), nil
+}
+
+func unsubscribeAccounts(s *session, subs map[tongo.AccountID]sources.CancelFn, accounts []tongo.AccountID) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, a := range accounts {
+		if cancel, ok := subs[a]; ok {
+			cancel()
+			delete(subs, a)
+			n++
+		}
+	}
+	return fmt.Sprintf("success! %d subscriptions removed", n)
+}
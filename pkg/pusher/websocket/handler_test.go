@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/tonkeeper/opentonapi/pkg/pusher/sources"
+)
+
+type mockSource struct {
+	mu  sync.Mutex
+	txs map[tongo.AccountID]sources.DeliveryFn
+}
+
+func (m *mockSource) SubscribeToTransactions(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range accounts {
+		m.txs[a] = deliveryFn
+	}
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, a := range accounts {
+			delete(m.txs, a)
+		}
+	}
+}
+
+func (m *mockSource) deliver(a tongo.AccountID, event sources.Event) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn, ok := m.txs[a]
+	if ok {
+		fn(event)
+	}
+	return ok
+}
+
+func (m *mockSource) SubscribeToTraces(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn {
+	return func() {}
+}
+
+func (m *mockSource) SubscribeToAccountEvents(accounts []tongo.AccountID, deliveryFn sources.DeliveryFn) sources.CancelFn {
+	return func() {}
+}
+
+func (m *mockSource) SubscribeToBlocks(deliveryFn sources.DeliveryFn) sources.CancelFn {
+	return func() {}
+}
+
+func TestHandler(t *testing.T) {
+	source := &mockSource{txs: map[tongo.AccountID]sources.DeliveryFn{}}
+	h := NewHandler(zap.NewNop(), source, nil, WithMaxSubscriptions(2))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = h.Handle(w, r, 0, true)
+	}))
+	defer server.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	require.Nil(t, err)
+	defer conn.Close()
+	require.Nil(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	call := func(req string) map[string]any {
+		require.Nil(t, websocket.Message.Send(conn, req))
+		var resp map[string]any
+		require.Nil(t, websocket.JSON.Receive(conn, &resp))
+		return resp
+	}
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	c := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+
+	resp := call(`{"id":1,"jsonrpc":"2.0","method":"subscribe_account","params":["` + a.ToRaw() + `","` + b.ToRaw() + `"]}`)
+	require.Equal(t, float64(1), resp["id"])
+	require.Equal(t, "success! 2 new subscriptions created", resp["result"])
+
+	resp = call(`{"id":2,"jsonrpc":"2.0","method":"subscribe_account","params":["` + c.ToRaw() + `"]}`)
+	require.Equal(t, float64(invalidParamsCode), resp["error"].(map[string]any)["code"])
+
+	resp = call(`{"id":3,"jsonrpc":"2.0","method":"subscribe_mempool"}`)
+	require.Equal(t, float64(methodNotFoundCode), resp["error"].(map[string]any)["code"])
+
+	resp = call(`{"id":4,"jsonrpc":"2.0","method":"unsubscribe_account","params":["` + b.ToRaw() + `"]}`)
+	require.Equal(t, "success! 1 subscriptions removed", resp["result"])
+	require.False(t, source.deliver(b, sources.Event{}))
+
+	require.True(t, source.deliver(a, sources.Event{Seq: 10, Data: []byte(`{"lt":10}`)}))
+	var msg notification
+	require.Nil(t, websocket.JSON.Receive(conn, &msg))
+	require.Equal(t, accountTransactionNotification, msg.Method)
+	require.JSONEq(t, `{"lt":10}`, string(msg.Params))
+
+	resp = call(`not json`)
+	require.Equal(t, float64(parseErrorCode), resp["error"].(map[string]any)["code"])
+}
+
+func Test_dedupe(t *testing.T) {
+	var keys []string
+	deliver := dedupe(func(event sources.Event) {
+		keys = append(keys, event.Key)
+	}, 2)
+	// a trace of two subscribed accounts is delivered by both subscriptions.
+	for _, key := range []string{"a", "a", "b", "a", "c", "a"} {
+		deliver(sources.Event{Key: key})
+	}
+	require.Equal(t, []string{"a", "b", "c", "a"}, keys)
+}
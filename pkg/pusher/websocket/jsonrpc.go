@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"encoding/json"
+)
+
+// Methods a client can call.
+const (
+	subscribeAccountMethod   = "subscribe_account"
+	unsubscribeAccountMethod = "unsubscribe_account"
+	subscribeTraceMethod     = "subscribe_trace"
+	unsubscribeTraceMethod   = "unsubscribe_trace"
+	subscribeBlockMethod     = "subscribe_block"
+	unsubscribeBlockMethod   = "unsubscribe_block"
+	subscribeMempoolMethod   = "subscribe_mempool"
+	unsubscribeMempoolMethod = "unsubscribe_mempool"
+)
+
+// Methods of notifications sent to a client.
+const (
+	accountTransactionNotification = "account_transaction"
+	traceNotification              = "trace"
+	blockNotification              = "block"
+	mempoolMessageNotification     = "mempool_message"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	parseErrorCode     = -32700
+	invalidRequestCode = -32600
+	methodNotFoundCode = -32601
+	invalidParamsCode  = -32602
+)
+
+// request is a JSON-RPC request sent by a client.
+// Params are a list of raw or user-friendly addresses for account-based subscriptions.
+type request struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  []string        `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// response is a reply to a request, it is sent in the same order requests are received.
+type response struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// notification is an event pushed to a client, it has no id.
+type notification struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
@@ -37,4 +37,9 @@ var TonstakersSocialLinks = []string{"https://t.me/thetonstakers", "https://twit
 var TonstakersAccountPool = ton.MustParseAccountID("EQCkWxfyhAkim3g2DjKQQg8T5P4g-Q1-K_jErGcDJZ4i-vqR")
 
 var TFLiquidPoolCodeHash = tongo.MustParseHash("192535677eed65c20ac387efe4dd7415ad9ebb9349103e87c60e592538c9dcf3")
+
+// LiquidPoolCodeHashes contains code hashes of known liquid staking pools.
+// Pools with other code are discovered by their get-methods and reported as unverified.
+var LiquidPoolCodeHashes = []tongo.Bits256{TFLiquidPoolCodeHash}
+
 var TFLiquidPool = ton.MustParseAccountID("0:a45b17f28409229b78360e3290420f13e4fe20f90d7e2bf8c4ac6703259e22fa")
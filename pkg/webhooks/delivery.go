@@ -0,0 +1,236 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var deliveryAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_delivery_attempts_total",
+	Help: "Number of attempts to deliver events to webhooks",
+}, []string{"result"})
+
+// Headers of a delivery request.
+const (
+	// SignatureHeader contains "sha256=" followed by a hex-encoded HMAC-SHA256 of TimestampHeader+"."+body
+	// keyed with the webhook's secret, see Sign.
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader contains the unix time of the attempt, a receiver should reject stale requests.
+	TimestampHeader = "X-Webhook-Timestamp"
+	// IdempotencyKeyHeader is the same for every attempt and replay of a delivery,
+	// a receiver should use it to drop duplicates.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// EventHeader contains the kind of the event, AccountEvent or Transaction.
+	EventHeader = "X-Webhook-Event"
+	// DeliveryHeader contains the ID of the delivery.
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Delivery is an event to be sent to a webhook.
+type Delivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	Event     string `json:"event"`
+	// IdempotencyKey is the event ID: a transaction hash or account+":"+trace hash for an account event.
+	IdempotencyKey string          `json:"idempotency_key"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+}
+
+// Sign returns a value of SignatureHeader for the given body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func deliveryID(webhookID, idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(webhookID + "/" + idempotencyKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+func queueKey(delivery Delivery) []byte {
+	key := binary.BigEndian.AppendUint64(nil, uint64(delivery.NextAttemptAt.UnixNano()))
+	return append(key, delivery.ID...)
+}
+
+func deadLetterKey(delivery Delivery) []byte {
+	return []byte(delivery.WebhookID + "/" + delivery.ID)
+}
+
+func (d *Dispatcher) enqueue(webhookID, event, idempotencyKey string, payload []byte) {
+	now := time.Now()
+	delivery := Delivery{
+		ID:             deliveryID(webhookID, idempotencyKey),
+		WebhookID:      webhookID,
+		Event:          event,
+		IdempotencyKey: idempotencyKey,
+		Payload:        payload,
+		CreatedAt:      now,
+		NextAttemptAt:  now,
+	}
+	if err := d.push(delivery); err != nil {
+		d.logger.Error("failed to queue webhook delivery", zap.String("webhook", webhookID), zap.Error(err))
+	}
+}
+
+// push adds the delivery to the queue.
+func (d *Dispatcher) push(delivery Delivery) error {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	if err := d.store.Put(queueBucket, queueKey(delivery), value); err != nil {
+		return err
+	}
+	select {
+	case d.wakeCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// schedule passes due deliveries to workers until the context is cancelled.
+func (d *Dispatcher) schedule(ctx context.Context, jobs chan<- Delivery) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		for _, delivery := range d.dueDeliveries(time.Now()) {
+			select {
+			case jobs <- delivery:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wakeCh:
+		}
+	}
+}
+
+// dueDeliveries returns deliveries to be sent by now and marks them as being sent.
+func (d *Dispatcher) dueDeliveries(now time.Time) []Delivery {
+	d.queueMu.Lock()
+	defer d.queueMu.Unlock()
+	var deliveries []Delivery
+	to := binary.BigEndian.AppendUint64(nil, uint64(now.UnixNano())+1)
+	err := d.store.Range(queueBucket, nil, to, false, func(key, value []byte) bool {
+		var delivery Delivery
+		if err := json.Unmarshal(value, &delivery); err != nil {
+			d.logger.Error("failed to decode webhook delivery", zap.Error(err))
+			return true
+		}
+		if _, ok := d.inFlight[delivery.ID]; ok {
+			return true
+		}
+		d.inFlight[delivery.ID] = struct{}{}
+		deliveries = append(deliveries, delivery)
+		return len(deliveries) < workers*10
+	})
+	if err != nil {
+		d.logger.Error("failed to read webhook queue", zap.Error(err))
+	}
+	return deliveries
+}
+
+// retryDelayAfter returns a delay after the given number of failed attempts.
+func (d *Dispatcher) retryDelayAfter(attempts int) time.Duration {
+	delay := d.retryDelay
+	for i := 1; i < attempts && delay < d.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxRetryDelay)
+}
+
+// send makes an attempt to deliver the event and then removes it from the queue,
+// reschedules it or moves it to the dead-letter log.
+func (d *Dispatcher) send(ctx context.Context, delivery Delivery) {
+	hook, ok := d.webhook(delivery.WebhookID)
+	var err error
+	if ok {
+		err = d.post(ctx, hook, delivery)
+	}
+
+	d.queueMu.Lock()
+	defer d.queueMu.Unlock()
+	defer delete(d.inFlight, delivery.ID)
+	if storeErr := d.store.Delete(queueBucket, queueKey(delivery)); storeErr != nil {
+		d.logger.Error("failed to remove webhook delivery", zap.String("delivery", delivery.ID), zap.Error(storeErr))
+		return
+	}
+	if !ok {
+		// the webhook has been removed.
+		return
+	}
+	if err == nil {
+		deliveryAttempts.WithLabelValues("success").Inc()
+		return
+	}
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	if delivery.Attempts < d.maxAttempts {
+		deliveryAttempts.WithLabelValues("retry").Inc()
+		delivery.NextAttemptAt = time.Now().Add(d.retryDelayAfter(delivery.Attempts))
+		value, _ := json.Marshal(delivery)
+		if err := d.store.Put(queueBucket, queueKey(delivery), value); err != nil {
+			d.logger.Error("failed to reschedule webhook delivery", zap.String("delivery", delivery.ID), zap.Error(err))
+		}
+		return
+	}
+	deliveryAttempts.WithLabelValues("dead_letter").Inc()
+	d.logger.Warn("webhook delivery failed",
+		zap.String("webhook", delivery.WebhookID),
+		zap.String("delivery", delivery.ID),
+		zap.Int("attempts", delivery.Attempts),
+		zap.Error(err))
+	value, _ := json.Marshal(delivery)
+	if err := d.store.Put(deadLettersBucket, deadLetterKey(delivery), value); err != nil {
+		d.logger.Error("failed to save failed webhook delivery", zap.String("delivery", delivery.ID), zap.Error(err))
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook *webhook, delivery Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, delivery.Payload))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(IdempotencyKeyHeader, delivery.IdempotencyKey)
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,603 @@
+// Package webhooks delivers events of tracked accounts to HTTP callbacks registered by operators.
+//
+// A webhook is registered for a list of accounts and tracking rules. Accounts are tracked by LiteStorage
+// as soon as the webhook is registered, and every completed trace of a tracked account
+// produces an account event and transactions that are POSTed to the webhook's URL.
+//
+// Completed traces are saved to the kv store as soon as they are received and converted into deliveries later.
+// Deliveries are kept in the kv store until the callback responds with 2xx,
+// failed attempts are retried with an exponential backoff, and a delivery that runs out of attempts
+// goes to a dead-letter log from where it can be replayed.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+	"github.com/tonkeeper/opentonapi/pkg/litestorage"
+)
+
+// Buckets of the kv store used by Dispatcher.
+const (
+	// webhooksBucket maps a webhook ID to a json-encoded Webhook.
+	webhooksBucket = "webhooks"
+	// queueBucket maps next_attempt_time+delivery_id to a json-encoded Delivery waiting to be sent.
+	queueBucket = "webhook_queue"
+	// deadLettersBucket maps webhook_id+"/"+delivery_id to a json-encoded Delivery that ran out of attempts.
+	deadLettersBucket = "webhook_dead_letters"
+	// tracesBucket contains received_at+trace_hash keys of completed traces waiting to be converted into deliveries.
+	tracesBucket = "webhook_traces"
+)
+
+// Kinds of events a webhook can receive.
+const (
+	// AccountEvent is an oas.AccountEvent of an account built from a completed trace.
+	AccountEvent = "account_event"
+	// Transaction is a TransactionPayload of a transaction of an account.
+	Transaction = "transaction"
+)
+
+const (
+	// DefaultMaxAttempts is a number of attempts to deliver an event before it goes to the dead-letter log.
+	DefaultMaxAttempts = 10
+	// DefaultRetryDelay is a delay before the second attempt, every next delay is twice as long.
+	DefaultRetryDelay = 10 * time.Second
+	// DefaultMaxRetryDelay limits a delay between attempts.
+	DefaultMaxRetryDelay = time.Hour
+	// DefaultTimeout limits the time a callback has to respond.
+	DefaultTimeout = 10 * time.Second
+
+	// tracesBatchSize is a number of traces converted into deliveries at once.
+	tracesBatchSize = 100
+	// workers is a number of deliveries sent concurrently.
+	workers = 8
+	// pollInterval defines how often the queue is checked for deliveries to retry.
+	pollInterval = time.Second
+)
+
+// ErrInvalidWebhook is returned when a webhook can't be registered because of its parameters.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// Webhook is an HTTP callback receiving events of the given accounts
+// and of accounts that matched the given tracking rules.
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret is a key to sign deliveries with, it is generated if not given.
+	// Secret is returned only when the webhook is registered.
+	Secret   string   `json:"secret,omitempty"`
+	Accounts []string `json:"accounts,omitempty"`
+	// Rules are tracking rules in the format of litestorage.ParseTrackingRule.
+	Rules []string `json:"rules,omitempty"`
+	// Events are kinds of events the webhook receives, all kinds if empty.
+	Events []string `json:"events,omitempty"`
+}
+
+// webhook is a registered Webhook prepared for matching.
+type webhook struct {
+	Webhook
+	accounts map[tongo.AccountID]struct{}
+	rules    map[string]litestorage.TrackingRule
+	events   map[string]struct{}
+}
+
+func (w *webhook) receives(event string) bool {
+	_, ok := w.events[event]
+	return ok
+}
+
+// TransactionPayload is sent to webhooks receiving transactions.
+type TransactionPayload struct {
+	AccountID string `json:"account_id"`
+	Lt        uint64 `json:"lt"`
+	TxHash    string `json:"tx_hash"`
+	TraceID   string `json:"trace_id"`
+	Utime     int64  `json:"utime"`
+	Success   bool   `json:"success"`
+}
+
+// tracker makes LiteStorage index accounts of webhooks and provides their traces.
+type tracker interface {
+	TrackAccount(a tongo.AccountID) error
+	AddTrackingRule(rule litestorage.TrackingRule) error
+	MatchesTrackingRule(ctx context.Context, a tongo.AccountID, rule litestorage.TrackingRule) (bool, error)
+	GetTrace(ctx context.Context, hash tongo.Bits256) (*core.Trace, error)
+}
+
+// Dispatcher keeps registered webhooks and delivers events to them.
+type Dispatcher struct {
+	logger        *zap.Logger
+	store         kv.Store
+	tracker       tracker
+	client        *http.Client
+	eventBuilder  litestorage.AccountEventBuilder
+	maxAttempts   int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+
+	// tracesWakeCh is signalled when a trace is added to tracesBucket.
+	tracesWakeCh chan struct{}
+	// wakeCh is signalled when a delivery is added to the queue.
+	wakeCh chan struct{}
+
+	mu       sync.RWMutex
+	webhooks map[string]*webhook
+
+	// queueMu protects inFlight and serializes changes of the queue.
+	queueMu sync.Mutex
+	// inFlight contains IDs of deliveries being sent.
+	inFlight map[string]struct{}
+}
+
+type Options struct {
+	client        *http.Client
+	eventBuilder  litestorage.AccountEventBuilder
+	maxAttempts   int
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+}
+
+type Option func(o *Options)
+
+// WithAccountEventBuilder configures a builder of account events, webhooks receive only transactions without it.
+func WithAccountEventBuilder(builder litestorage.AccountEventBuilder) Option {
+	return func(o *Options) {
+		o.eventBuilder = builder
+	}
+}
+
+// WithHTTPClient configures a client used to send deliveries.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.client = client
+	}
+}
+
+// WithMaxAttempts configures a number of attempts to deliver an event before it goes to the dead-letter log.
+func WithMaxAttempts(n int) Option {
+	return func(o *Options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithRetryDelay configures a delay before the second attempt and a limit of delays between attempts.
+func WithRetryDelay(delay, maxDelay time.Duration) Option {
+	return func(o *Options) {
+		o.retryDelay = delay
+		o.maxRetryDelay = maxDelay
+	}
+}
+
+// New creates a dispatcher and loads webhooks registered earlier from the store.
+func New(logger *zap.Logger, store kv.Store, tracker tracker, opts ...Option) (*Dispatcher, error) {
+	o := &Options{
+		client:        &http.Client{Timeout: DefaultTimeout},
+		maxAttempts:   DefaultMaxAttempts,
+		retryDelay:    DefaultRetryDelay,
+		maxRetryDelay: DefaultMaxRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	d := &Dispatcher{
+		logger:        logger,
+		store:         store,
+		tracker:       tracker,
+		client:        o.client,
+		eventBuilder:  o.eventBuilder,
+		maxAttempts:   o.maxAttempts,
+		retryDelay:    o.retryDelay,
+		maxRetryDelay: o.maxRetryDelay,
+		tracesWakeCh:  make(chan struct{}, 1),
+		wakeCh:        make(chan struct{}, 1),
+		webhooks:      map[string]*webhook{},
+		inFlight:      map[string]struct{}{},
+	}
+	var decodeErr error
+	err := store.Range(webhooksBucket, nil, nil, false, func(key, value []byte) bool {
+		var w Webhook
+		if decodeErr = json.Unmarshal(value, &w); decodeErr != nil {
+			return false
+		}
+		hook, err := prepare(w)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		d.webhooks[w.ID] = hook
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", decodeErr)
+	}
+	return d, nil
+}
+
+// prepare validates the webhook and converts its accounts and rules to the canonical form.
+func prepare(w Webhook) (*webhook, error) {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https url", ErrInvalidWebhook)
+	}
+	if len(w.Accounts) == 0 && len(w.Rules) == 0 {
+		return nil, fmt.Errorf("%w: accounts or rules are required", ErrInvalidWebhook)
+	}
+	hook := &webhook{
+		accounts: map[tongo.AccountID]struct{}{},
+		rules:    map[string]litestorage.TrackingRule{},
+		events:   map[string]struct{}{},
+	}
+	hook.ID, hook.URL, hook.Secret = w.ID, w.URL, w.Secret
+	for _, s := range w.Accounts {
+		account, err := tongo.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid account %q: %v", ErrInvalidWebhook, s, err)
+		}
+		if _, ok := hook.accounts[account.ID]; !ok {
+			hook.accounts[account.ID] = struct{}{}
+			hook.Accounts = append(hook.Accounts, account.ID.ToRaw())
+		}
+	}
+	for _, s := range w.Rules {
+		rule, err := litestorage.ParseTrackingRule(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+		}
+		if _, ok := hook.rules[rule.String()]; !ok {
+			hook.rules[rule.String()] = rule
+			hook.Rules = append(hook.Rules, rule.String())
+		}
+	}
+	events := w.Events
+	if len(events) == 0 {
+		events = []string{AccountEvent, Transaction}
+	}
+	for _, event := range events {
+		if event != AccountEvent && event != Transaction {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+		if _, ok := hook.events[event]; !ok {
+			hook.events[event] = struct{}{}
+			hook.Events = append(hook.Events, event)
+		}
+	}
+	return hook, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Webhooks returns registered webhooks sorted by ID, without their secrets.
+func (d *Dispatcher) Webhooks() []Webhook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	result := make([]Webhook, 0, len(d.webhooks))
+	for _, hook := range d.webhooks {
+		w := hook.Webhook
+		w.Secret = ""
+		result = append(result, w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// AddWebhook registers a new webhook and starts tracking its accounts and rules.
+// It returns the webhook with its ID and secret.
+func (d *Dispatcher) AddWebhook(w Webhook) (Webhook, error) {
+	w.ID = randomHex(16)
+	if w.Secret == "" {
+		w.Secret = randomHex(32)
+	}
+	hook, err := prepare(w)
+	if err != nil {
+		return Webhook{}, err
+	}
+	for account := range hook.accounts {
+		if err := d.tracker.TrackAccount(account); err != nil {
+			return Webhook{}, err
+		}
+	}
+	for _, rule := range hook.rules {
+		if err := d.tracker.AddTrackingRule(rule); err != nil {
+			return Webhook{}, err
+		}
+	}
+	value, err := json.Marshal(hook.Webhook)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if err := d.store.Put(webhooksBucket, []byte(hook.ID), value); err != nil {
+		return Webhook{}, err
+	}
+	d.mu.Lock()
+	d.webhooks[hook.ID] = hook
+	d.mu.Unlock()
+	return hook.Webhook, nil
+}
+
+// RemoveWebhook removes the webhook together with its dead-letter log, its pending deliveries are dropped.
+// Accounts and rules of the webhook stay tracked.
+func (d *Dispatcher) RemoveWebhook(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.webhooks[id]; !ok {
+		return fmt.Errorf("webhook %v: %w", id, core.ErrEntityNotFound)
+	}
+	if err := d.store.Delete(webhooksBucket, []byte(id)); err != nil {
+		return err
+	}
+	delete(d.webhooks, id)
+	var keys [][]byte
+	err := kv.Prefix(d.store, deadLettersBucket, []byte(id+"/"), false, func(key, value []byte) bool {
+		keys = append(keys, bytes.Clone(key))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := d.store.Delete(deadLettersBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) webhook(id string) (*webhook, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	hook, ok := d.webhooks[id]
+	return hook, ok
+}
+
+// OnTrace is a litestorage.TraceListener, it saves the trace to be delivered to webhooks of its accounts.
+// Only the trace hash is saved, so the block processing loop isn't slowed down by building deliveries,
+// and traces received before a restart are delivered after it.
+func (d *Dispatcher) OnTrace(trace *core.Trace) {
+	d.mu.RLock()
+	noWebhooks := len(d.webhooks) == 0
+	d.mu.RUnlock()
+	if noWebhooks {
+		return
+	}
+	key := binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+	key = append(key, trace.Hash[:]...)
+	if err := d.store.Put(tracesBucket, key, []byte{1}); err != nil {
+		d.logger.Error("failed to save trace for webhooks", zap.String("hash", trace.Hash.Hex()), zap.Error(err))
+		return
+	}
+	select {
+	case d.tracesWakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run converts traces into deliveries and sends them until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	jobs := make(chan Delivery)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for delivery := range jobs {
+				d.send(ctx, delivery)
+			}
+		}()
+	}
+	go d.processTraces(ctx)
+	d.schedule(ctx, jobs)
+	close(jobs)
+}
+
+func (d *Dispatcher) processTraces(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if d.processSavedTraces(ctx) == tracesBatchSize {
+			// there can be more saved traces.
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.tracesWakeCh:
+		}
+	}
+}
+
+// processSavedTraces converts a batch of saved traces into deliveries and returns the number of processed traces.
+// A trace that can't be read from the storage stays saved and is processed again later.
+func (d *Dispatcher) processSavedTraces(ctx context.Context) int {
+	var keys [][]byte
+	err := d.store.Range(tracesBucket, nil, nil, false, func(key, value []byte) bool {
+		keys = append(keys, bytes.Clone(key))
+		return len(keys) < tracesBatchSize
+	})
+	if err != nil {
+		d.logger.Error("failed to read saved traces", zap.Error(err))
+		return 0
+	}
+	for i, key := range keys {
+		if ctx.Err() != nil {
+			return i
+		}
+		hash := tongo.Bits256(key[8:])
+		trace, err := d.tracker.GetTrace(ctx, hash)
+		if err != nil && !errors.Is(err, core.ErrEntityNotFound) {
+			d.logger.Warn("failed to get trace for webhooks", zap.String("hash", hash.Hex()), zap.Error(err))
+			return i
+		}
+		if trace != nil {
+			d.processTrace(ctx, trace)
+		}
+		if err := d.store.Delete(tracesBucket, key); err != nil {
+			d.logger.Error("failed to remove saved trace", zap.String("hash", hash.Hex()), zap.Error(err))
+			return i
+		}
+	}
+	return len(keys)
+}
+
+// matchingWebhooks returns webhooks interested in the account.
+func (d *Dispatcher) matchingWebhooks(ctx context.Context, account tongo.AccountID) []*webhook {
+	d.mu.RLock()
+	hooks := make([]*webhook, 0, len(d.webhooks))
+	for _, hook := range d.webhooks {
+		hooks = append(hooks, hook)
+	}
+	d.mu.RUnlock()
+	var result []*webhook
+	for _, hook := range hooks {
+		if _, ok := hook.accounts[account]; ok {
+			result = append(result, hook)
+			continue
+		}
+		for _, rule := range hook.rules {
+			matches, err := d.tracker.MatchesTrackingRule(ctx, account, rule)
+			if err != nil {
+				d.logger.Warn("failed to check tracking rule",
+					zap.String("accountID", account.ToRaw()),
+					zap.Stringer("rule", rule),
+					zap.Error(err))
+				continue
+			}
+			if matches {
+				result = append(result, hook)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (d *Dispatcher) processTrace(ctx context.Context, trace *core.Trace) {
+	traceID := core.TraceID{Hash: trace.Hash, Lt: trace.Lt}
+	for _, account := range core.DistinctAccounts(trace) {
+		hooks := d.matchingWebhooks(ctx, account)
+		if len(hooks) == 0 {
+			continue
+		}
+		var eventPayload []byte
+		for _, hook := range hooks {
+			if hook.receives(Transaction) {
+				core.Visit(trace, func(t *core.Trace) {
+					if t.Account != account {
+						return
+					}
+					payload, err := json.Marshal(TransactionPayload{
+						AccountID: account.ToRaw(),
+						Lt:        t.Lt,
+						TxHash:    t.Hash.Hex(),
+						TraceID:   trace.Hash.Hex(),
+						Utime:     t.Utime,
+						Success:   t.Success,
+					})
+					if err != nil {
+						d.logger.Error("failed to marshal transaction", zap.Error(err))
+						return
+					}
+					d.enqueue(hook.ID, Transaction, t.Hash.Hex(), payload)
+				})
+			}
+			if hook.receives(AccountEvent) && d.eventBuilder != nil {
+				if eventPayload == nil {
+					event := d.eventBuilder(ctx, account, traceID)
+					value, err := event.MarshalJSON()
+					if err != nil {
+						d.logger.Error("failed to marshal account event", zap.Error(err))
+						continue
+					}
+					eventPayload = value
+				}
+				// an event of a trace has the same ID for every account, so the key includes the account.
+				d.enqueue(hook.ID, AccountEvent, account.ToRaw()+":"+trace.Hash.Hex(), eventPayload)
+			}
+		}
+	}
+}
+
+// FailedDeliveries returns at most limit deliveries of the webhook from the dead-letter log.
+func (d *Dispatcher) FailedDeliveries(webhookID string, limit int) ([]Delivery, error) {
+	if _, ok := d.webhook(webhookID); !ok {
+		return nil, fmt.Errorf("webhook %v: %w", webhookID, core.ErrEntityNotFound)
+	}
+	deliveries := []Delivery{}
+	var decodeErr error
+	err := kv.Prefix(d.store, deadLettersBucket, []byte(webhookID+"/"), false, func(key, value []byte) bool {
+		var delivery Delivery
+		if decodeErr = json.Unmarshal(value, &delivery); decodeErr != nil {
+			return false
+		}
+		deliveries = append(deliveries, delivery)
+		return len(deliveries) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, decodeErr
+}
+
+// ReplayDelivery moves the delivery from the dead-letter log back to the queue with a fresh set of attempts.
+func (d *Dispatcher) ReplayDelivery(webhookID, deliveryID string) error {
+	if strings.Contains(deliveryID, "/") {
+		return fmt.Errorf("delivery %v: %w", deliveryID, core.ErrEntityNotFound)
+	}
+	key := []byte(webhookID + "/" + deliveryID)
+	value, err := d.store.Get(deadLettersBucket, key)
+	if errors.Is(err, kv.ErrNotFound) {
+		return fmt.Errorf("delivery %v: %w", deliveryID, core.ErrEntityNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	var delivery Delivery
+	if err := json.Unmarshal(value, &delivery); err != nil {
+		return err
+	}
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	delivery.NextAttemptAt = time.Now()
+	if err := d.push(delivery); err != nil {
+		return err
+	}
+	return d.store.Delete(deadLettersBucket, key)
+}
+
+// ReplayFailedDeliveries replays all deliveries of the webhook from the dead-letter log
+// and returns the number of replayed deliveries.
+func (d *Dispatcher) ReplayFailedDeliveries(webhookID string) (int, error) {
+	deliveries, err := d.FailedDeliveries(webhookID, int(^uint(0)>>1))
+	if err != nil {
+		return 0, err
+	}
+	for i, delivery := range deliveries {
+		if err := d.ReplayDelivery(webhookID, delivery.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(deliveries), nil
+}
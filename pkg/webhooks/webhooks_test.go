@@ -0,0 +1,172 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonkeeper/tongo"
+	"go.uber.org/zap"
+
+	"github.com/tonkeeper/opentonapi/pkg/core"
+	"github.com/tonkeeper/opentonapi/pkg/kv"
+	"github.com/tonkeeper/opentonapi/pkg/litestorage"
+	"github.com/tonkeeper/opentonapi/pkg/oas"
+)
+
+type mockTracker struct {
+	tracked map[tongo.AccountID]struct{}
+	rules   []string
+	matched map[tongo.AccountID][]string
+	traces  map[tongo.Bits256]*core.Trace
+}
+
+func (m *mockTracker) TrackAccount(a tongo.AccountID) error {
+	m.tracked[a] = struct{}{}
+	return nil
+}
+
+func (m *mockTracker) AddTrackingRule(rule litestorage.TrackingRule) error {
+	m.rules = append(m.rules, rule.String())
+	return nil
+}
+
+func (m *mockTracker) MatchesTrackingRule(ctx context.Context, a tongo.AccountID, rule litestorage.TrackingRule) (bool, error) {
+	return slices.Contains(m.matched[a], rule.String()), nil
+}
+
+func (m *mockTracker) GetTrace(ctx context.Context, hash tongo.Bits256) (*core.Trace, error) {
+	trace, ok := m.traces[hash]
+	if !ok {
+		return nil, core.ErrEntityNotFound
+	}
+	return trace, nil
+}
+
+func TestDispatcher(t *testing.T) {
+	a := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{1}}
+	b := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{2}}
+	c := tongo.AccountID{Workchain: 0, Address: tongo.Bits256{3}}
+	rule := "code_hash:" + tongo.Bits256{7}.Hex()
+
+	var failing atomic.Bool
+	failing.Store(true)
+	var mu sync.Mutex
+	received := map[string]string{}
+	// the handler runs in the server's goroutine, so its errors are checked by the test.
+	var handlerErrors []error
+	var secret string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if err != nil {
+			handlerErrors = append(handlerErrors, err)
+		} else if Sign(secret, timestamp, body) != r.Header.Get(SignatureHeader) {
+			handlerErrors = append(handlerErrors, fmt.Errorf("invalid signature of %v", r.Header.Get(DeliveryHeader)))
+		}
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received[r.Header.Get(IdempotencyKeyHeader)] = r.Header.Get(EventHeader)
+	}))
+	defer server.Close()
+
+	store := kv.NewMemoryStore()
+	tracker := &mockTracker{
+		tracked: map[tongo.AccountID]struct{}{},
+		matched: map[tongo.AccountID][]string{b: {rule}},
+	}
+	d, err := New(zap.NewNop(), store, tracker,
+		WithMaxAttempts(2),
+		WithRetryDelay(0, 0),
+		WithAccountEventBuilder(func(ctx context.Context, account tongo.AccountID, traceID core.TraceID) oas.AccountEvent {
+			return oas.AccountEvent{EventID: traceID.Hash.Hex(), Lt: int64(traceID.Lt)}
+		}),
+	)
+	require.Nil(t, err)
+
+	_, err = d.AddWebhook(Webhook{URL: "ftp://example.com", Accounts: []string{a.ToRaw()}})
+	require.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = d.AddWebhook(Webhook{URL: server.URL})
+	require.ErrorIs(t, err, ErrInvalidWebhook)
+
+	hook, err := d.AddWebhook(Webhook{URL: server.URL, Accounts: []string{a.ToRaw()}, Rules: []string{rule}})
+	require.Nil(t, err)
+	require.NotEmpty(t, hook.Secret)
+	secret = hook.Secret
+	require.Contains(t, tracker.tracked, a)
+	require.Equal(t, []string{rule}, tracker.rules)
+	require.Empty(t, d.Webhooks()[0].Secret)
+
+	trace := &core.Trace{
+		Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{10}, Lt: 10, Account: a}},
+		Children: []*core.Trace{
+			{Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{11}, Lt: 11, Account: b}}},
+			{Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{12}, Lt: 12, Account: c}}},
+		},
+	}
+	tracker.traces = map[tongo.Bits256]*core.Trace{trace.Hash: trace}
+	d.OnTrace(trace)
+	// a trace that isn't in the storage anymore is skipped.
+	d.OnTrace(&core.Trace{Transaction: core.Transaction{TransactionID: core.TransactionID{Hash: tongo.Bits256{20}}}})
+	require.Equal(t, 2, d.processSavedTraces(context.Background()))
+	require.Equal(t, 0, d.processSavedTraces(context.Background()))
+
+	sendDue := func() int {
+		deliveries := d.dueDeliveries(time.Now())
+		for _, delivery := range deliveries {
+			d.send(context.Background(), delivery)
+		}
+		return len(deliveries)
+	}
+	// a transaction and an event for both a and b, c isn't watched.
+	require.Equal(t, 4, sendDue())
+	require.Equal(t, 4, sendDue())
+	require.Equal(t, 0, sendDue())
+
+	failed, err := d.FailedDeliveries(hook.ID, 10)
+	require.Nil(t, err)
+	require.Len(t, failed, 4)
+	require.Equal(t, 2, failed[0].Attempts)
+	require.NotEmpty(t, failed[0].LastError)
+
+	failing.Store(false)
+	require.Nil(t, d.ReplayDelivery(hook.ID, failed[0].ID))
+	n, err := d.ReplayFailedDeliveries(hook.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, 4, sendDue())
+	require.Equal(t, 0, sendDue())
+	require.Equal(t, map[string]string{
+		tongo.Bits256{10}.Hex():                   Transaction,
+		tongo.Bits256{11}.Hex():                   Transaction,
+		a.ToRaw() + ":" + tongo.Bits256{10}.Hex(): AccountEvent,
+		b.ToRaw() + ":" + tongo.Bits256{10}.Hex(): AccountEvent,
+	}, received)
+
+	failed, err = d.FailedDeliveries(hook.ID, 10)
+	require.Nil(t, err)
+	require.Len(t, failed, 0)
+	mu.Lock()
+	require.Empty(t, handlerErrors)
+	mu.Unlock()
+
+	// webhooks survive a restart.
+	d, err = New(zap.NewNop(), store, tracker)
+	require.Nil(t, err)
+	require.Equal(t, []Webhook{{ID: hook.ID, URL: server.URL, Accounts: []string{a.ToRaw()}, Rules: []string{rule}, Events: []string{AccountEvent, Transaction}}}, d.Webhooks())
+	require.Nil(t, d.RemoveWebhook(hook.ID))
+	require.Len(t, d.Webhooks(), 0)
+}